@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/oidc"
+)
+
+const clientsUsage = `Usage: auth-service clients <command>
+
+Commands:
+  add <name> <redirect-uri>...        Register an OpenID Connect client and print its client_id
+  add-service <name> <scope>...       Register a machine client for the client credentials
+                                      grant and print its client_id and client_secret
+  list                                List registered clients
+  delete <client_id>                  Delete a client; its client tokens stop working
+                                      and its unused authorization codes are discarded`
+
+// runClients implements the "clients" subcommand
+func runClients(store database.OAuthStore, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing clients command\n\n%s", clientsUsage)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return fmt.Errorf("clients add needs a name and at least one redirect URI\n\n%s", clientsUsage)
+		}
+		client, err := oidc.NewClient(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		if err := store.CreateOAuthClient(ctx, client); err != nil {
+			return err
+		}
+		fmt.Printf("Registered %s\nclient_id: %s\n", client.Name, client.ID)
+
+	case "add-service":
+		if len(args) < 3 {
+			return fmt.Errorf("clients add-service needs a name and at least one scope\n\n%s", clientsUsage)
+		}
+		client, secret, err := oidc.NewServiceClient(args[1], args[2:])
+		if err != nil {
+			return err
+		}
+		if err := store.CreateOAuthClient(ctx, client); err != nil {
+			return err
+		}
+		fmt.Printf("Registered %s\nclient_id: %s\nclient_secret: %s\n", client.Name, client.ID, secret)
+		fmt.Println("Store the secret now; it cannot be shown again.")
+
+	case "list":
+		clients, err := store.ListOAuthClients(ctx)
+		if err != nil {
+			return err
+		}
+		printOAuthClients(clients)
+
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("clients delete needs a client_id\n\n%s", clientsUsage)
+		}
+		deleted, err := store.DeleteOAuthClient(ctx, args[1])
+		if err != nil {
+			return err
+		}
+		if !deleted {
+			return fmt.Errorf("client %q not found", args[1])
+		}
+		fmt.Printf("Deleted client %s\n", args[1])
+
+	default:
+		return fmt.Errorf("unknown clients command %q\n\n%s", args[0], clientsUsage)
+	}
+
+	return nil
+}
+
+// printOAuthClients writes a table of clients to stdout
+func printOAuthClients(clients []models.OAuthClient) {
+	fmt.Fprintf(os.Stdout, "%-45s %-25s %-12s %s\n", "CLIENT ID", "NAME", "TYPE", "REDIRECT URIS / SCOPES")
+	for _, client := range clients {
+		if client.IsConfidential() {
+			fmt.Fprintf(os.Stdout, "%-45s %-25s %-12s %s\n", client.ID, client.Name, "service", strings.Join(client.Scopes, " "))
+		} else {
+			fmt.Fprintf(os.Stdout, "%-45s %-25s %-12s %s\n", client.ID, client.Name, "web", strings.Join(client.RedirectURIs, " "))
+		}
+	}
+}
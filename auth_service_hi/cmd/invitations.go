@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+const invitationsUsage = `Usage: auth-service invitations <command>
+
+Commands:
+  create <email> [role] [hours]       Invite someone to sign up, as an admin by default,
+                                      and print the single-use link (valid 72 hours by default)
+  list                                List invitations and their status
+  revoke <id>                         Withdraw an unused invitation`
+
+// runInvitations implements the "invitations" subcommand. It is how the first admin
+// account is created.
+func runInvitations(authService *auth.AuthService, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing invitations command\n\n%s", invitationsUsage)
+	}
+	ctx := context.Background()
+
+	switch args[0] {
+	case "create":
+		if len(args) < 2 || len(args) > 4 {
+			return fmt.Errorf("invitations create needs an email address\n\n%s", invitationsUsage)
+		}
+		req := models.CreateInvitationRequest{Email: args[1], Role: models.RoleAdmin}
+		if len(args) > 2 {
+			req.Role = models.UserRole(args[2])
+		}
+		if len(args) > 3 {
+			hours, err := strconv.Atoi(args[3])
+			if err != nil {
+				return fmt.Errorf("invalid number of hours %q", args[3])
+			}
+			req.ExpiresInHours = hours
+		}
+
+		invitation, err := authService.CreateInvitation(ctx, req, nil)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Invited %s as %s until %s\nid: %s\nlink: %s\n",
+			invitation.Email, invitation.Role, invitation.ExpiresAt.Format("2006-01-02 15:04:05"), invitation.ID, invitation.Link)
+
+	case "list":
+		invitations, err := authService.ListInvitations(ctx)
+		if err != nil {
+			return err
+		}
+		printInvitations(invitations)
+
+	case "revoke":
+		if len(args) != 2 {
+			return fmt.Errorf("invitations revoke needs an invitation ID\n\n%s", invitationsUsage)
+		}
+		if err := authService.RevokeInvitation(ctx, args[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Revoked invitation %s\n", args[1])
+
+	default:
+		return fmt.Errorf("unknown invitations command %q\n\n%s", args[0], invitationsUsage)
+	}
+
+	return nil
+}
+
+// printInvitations writes a table of invitations to stdout
+func printInvitations(invitations []models.Invitation) {
+	fmt.Fprintf(os.Stdout, "%-32s %-30s %-12s %-10s %s\n", "ID", "EMAIL", "ROLE", "STATUS", "EXPIRES")
+	for _, invitation := range invitations {
+		status := "pending"
+		switch {
+		case invitation.AcceptedAt != nil:
+			status = "accepted"
+		case invitation.RevokedAt != nil:
+			status = "revoked"
+		case !invitation.IsUsable(time.Now()):
+			status = "expired"
+		}
+		fmt.Fprintf(os.Stdout, "%-32s %-30s %-12s %-10s %s\n",
+			invitation.ID, invitation.Email, invitation.Role, status, invitation.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/keys"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+const keysUsage = `Usage: auth-service keys <command>
+
+Commands:
+  list        List stored signing keys and their status
+  rotate      Publish a new signing key; it replaces the active key once
+              jwt.key_activation_delay has passed`
+
+// runKeys implements the "keys" subcommand
+func runKeys(manager *keys.Manager, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing keys command\n\n%s", keysUsage)
+	}
+
+	switch args[0] {
+	case "list":
+		records, err := manager.Keys()
+		if err != nil {
+			return err
+		}
+		printSigningKeys(records)
+
+	case "rotate":
+		if _, err := manager.Load(); err != nil {
+			return err
+		}
+		key, activatesAt, err := manager.Rotate()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Published %s key %s; it starts signing at %s\n", key.Method.Alg(), key.ID, activatesAt.Format("2006-01-02 15:04:05"))
+
+	default:
+		return fmt.Errorf("unknown keys command %q\n\n%s", args[0], keysUsage)
+	}
+
+	return nil
+}
+
+// printSigningKeys writes a table of signing keys to stdout
+func printSigningKeys(records []models.SigningKeyRecord) {
+	fmt.Fprintf(os.Stdout, "%-45s %-6s %-8s %-20s %s\n", "KID", "ALG", "STATUS", "CREATED AT", "RETIRED AT")
+	for _, record := range records {
+		retiredAt := "-"
+		if record.RetiredAt != nil {
+			retiredAt = record.RetiredAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(os.Stdout, "%-45s %-6s %-8s %-20s %s\n", record.ID, record.Algorithm, record.Status,
+			record.CreatedAt.Format("2006-01-02 15:04:05"), retiredAt)
+	}
+}
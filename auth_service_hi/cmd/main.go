@@ -1,14 +1,26 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"time"
+	"strconv"
 
+	"github.com/herb-immortal/auth_service_hi/pkg/audit"
 	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/config"
 	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/health"
+	"github.com/herb-immortal/auth_service_hi/pkg/keys"
+	"github.com/herb-immortal/auth_service_hi/pkg/logging"
+	"github.com/herb-immortal/auth_service_hi/pkg/metrics"
+	"github.com/herb-immortal/auth_service_hi/pkg/notify"
+	"github.com/herb-immortal/auth_service_hi/pkg/oidc"
+	"github.com/herb-immortal/auth_service_hi/pkg/ratelimit"
 	"github.com/herb-immortal/auth_service_hi/pkg/utils"
 )
 
@@ -175,6 +187,10 @@ const htmlTemplate = `<!DOCTYPE html>
                     <label for="loginPassword">Password</label>
                     <input type="password" id="loginPassword" required>
                 </div>
+                <div class="form-group">
+                    <label for="loginOTP">Authenticator Code (if enabled)</label>
+                    <input type="text" id="loginOTP" inputmode="numeric" autocomplete="one-time-code">
+                </div>
                 <button type="submit" class="btn">Login</button>
                 <p id="loginMessage" class="message"></p>
             </form>
@@ -200,11 +216,10 @@ const htmlTemplate = `<!DOCTYPE html>
                     <label for="signupPassword">Password</label>
                     <input type="password" id="signupPassword" required>
                 </div>
-                <div class="form-group">
+                <div class="form-group" id="signupRoleGroup">
                     <label for="signupRole">Role</label>
                     <select id="signupRole" required>
                         <option value="customer">Customer</option>
-                        <option value="admin">Admin</option>
                         <option value="healer">Healer</option>
                         <option value="vendor">Vendor</option>
                     </select>
@@ -214,6 +229,19 @@ const htmlTemplate = `<!DOCTYPE html>
             </form>
         </div>
 
+        <!-- Password Reset Form -->
+        <div id="resetForm" class="form-container">
+            <h2>Choose a New Password</h2>
+            <form id="reset">
+                <div class="form-group">
+                    <label for="resetPassword">New Password</label>
+                    <input type="password" id="resetPassword" minlength="8" autocomplete="new-password" required>
+                </div>
+                <button type="submit" class="btn">Reset Password</button>
+                <p id="resetMessage" class="message"></p>
+            </form>
+        </div>
+
         <!-- Profile Page -->
         <div id="profilePage" class="form-container">
             <h2>User Profile</h2>
@@ -248,6 +276,8 @@ const htmlTemplate = `<!DOCTYPE html>
             const SIGNUP_ENDPOINT = API_URL + '/api/auth/signup';
             const LOGIN_ENDPOINT = API_URL + '/api/auth/login';
             const PROFILE_ENDPOINT = API_URL + '/api/auth/profile';
+            const LOGOUT_ENDPOINT = API_URL + '/api/auth/logout';
+            const RESET_ENDPOINT = API_URL + '/api/auth/password/reset';
 
             // DOM elements
             const loginTab = document.getElementById('loginTab');
@@ -255,12 +285,14 @@ const htmlTemplate = `<!DOCTYPE html>
             const profileTab = document.getElementById('profileTab');
             const loginForm = document.getElementById('loginForm');
             const signupForm = document.getElementById('signupForm');
+            const resetForm = document.getElementById('resetForm');
             const profilePage = document.getElementById('profilePage');
             const logoutButton = document.getElementById('logoutButton');
 
             // Messages
             const loginMessage = document.getElementById('loginMessage');
             const signupMessage = document.getElementById('signupMessage');
+            const resetMessage = document.getElementById('resetMessage');
 
             // Check if user is already logged in
             checkAuthStatus();
@@ -273,8 +305,23 @@ const htmlTemplate = `<!DOCTYPE html>
             // Form submissions
             document.getElementById('login').addEventListener('submit', handleLogin);
             document.getElementById('signup').addEventListener('submit', handleSignup);
+            document.getElementById('reset').addEventListener('submit', handleReset);
             logoutButton.addEventListener('click', handleLogout);
 
+            // An invitation link carries a token that also decides the role
+            const invitationToken = new URLSearchParams(window.location.search).get('invitation');
+            if (invitationToken) {
+                document.getElementById('signupRoleGroup').style.display = 'none';
+                document.getElementById('signupRole').required = false;
+                showTab('signup');
+            }
+
+            // A password reset link carries the token that authorises the new password
+            const resetToken = new URLSearchParams(window.location.search).get('reset');
+            if (resetToken) {
+                showTab('reset');
+            }
+
             // Tab switching function
             function showTab(tabName) {
                 // Hide all tabs
@@ -283,6 +330,7 @@ const htmlTemplate = `<!DOCTYPE html>
                 profileTab.classList.remove('active');
                 loginForm.classList.remove('active');
                 signupForm.classList.remove('active');
+                resetForm.classList.remove('active');
                 profilePage.classList.remove('active');
 
                 // Show selected tab
@@ -292,6 +340,8 @@ const htmlTemplate = `<!DOCTYPE html>
                 } else if (tabName === 'signup') {
                     signupTab.classList.add('active');
                     signupForm.classList.add('active');
+                } else if (tabName === 'reset') {
+                    resetForm.classList.add('active');
                 } else if (tabName === 'profile') {
                     profileTab.classList.add('active');
                     profilePage.classList.add('active');
@@ -319,6 +369,7 @@ const htmlTemplate = `<!DOCTYPE html>
                 
                 const email = document.getElementById('loginEmail').value;
                 const password = document.getElementById('loginPassword').value;
+                const otp_code = document.getElementById('loginOTP').value;
                 
                 try {
                     const response = await fetch(LOGIN_ENDPOINT, {
@@ -326,7 +377,7 @@ const htmlTemplate = `<!DOCTYPE html>
                         headers: {
                             'Content-Type': 'application/json',
                         },
-                        body: JSON.stringify({ email, password })
+                        body: JSON.stringify({ email, password, otp_code })
                     });
                     
                     const data = await response.json();
@@ -361,6 +412,11 @@ const htmlTemplate = `<!DOCTYPE html>
                 const phone_number = document.getElementById('signupPhone').value;
                 const password = document.getElementById('signupPassword').value;
                 const role = document.getElementById('signupRole').value;
+                const payload = { name, email, phone_number, password, role };
+                if (invitationToken) {
+                    payload.role = '';
+                    payload.invitation_token = invitationToken;
+                }
                 
                 try {
                     const response = await fetch(SIGNUP_ENDPOINT, {
@@ -368,13 +424,7 @@ const htmlTemplate = `<!DOCTYPE html>
                         headers: {
                             'Content-Type': 'application/json',
                         },
-                        body: JSON.stringify({
-                            name,
-                            email,
-                            phone_number,
-                            password,
-                            role
-                        })
+                        body: JSON.stringify(payload)
                     });
                     
                     const data = await response.json();
@@ -397,6 +447,43 @@ const htmlTemplate = `<!DOCTYPE html>
                 }
             }
 
+            // Handle password reset form submission
+            async function handleReset(event) {
+                event.preventDefault();
+
+                const password = document.getElementById('resetPassword').value;
+
+                try {
+                    const response = await fetch(RESET_ENDPOINT, {
+                        method: 'POST',
+                        headers: {
+                            'Content-Type': 'application/json',
+                        },
+                        body: JSON.stringify({ token: resetToken, password })
+                    });
+
+                    const data = await response.json();
+
+                    if (response.ok) {
+                        resetMessage.textContent = 'Password changed. Please login with your new password.';
+                        resetMessage.className = 'message success';
+                        document.getElementById('reset').reset();
+                        // The reset signed the user out everywhere, and the link cannot be used again
+                        localStorage.removeItem('token');
+                        profileTab.classList.add('hidden');
+                        window.history.replaceState(null, '', window.location.pathname);
+                        setTimeout(() => showTab('login'), 2000);
+                    } else {
+                        resetMessage.textContent = data.error || 'Password reset failed. Please request a new link.';
+                        resetMessage.className = 'message error';
+                    }
+                } catch (error) {
+                    resetMessage.textContent = 'An error occurred. Please try again later.';
+                    resetMessage.className = 'message error';
+                    console.error('Password reset error:', error);
+                }
+            }
+
             // Fetch profile data
             async function fetchProfile() {
                 const token = localStorage.getItem('token');
@@ -436,6 +523,16 @@ const htmlTemplate = `<!DOCTYPE html>
 
             // Handle logout
             function handleLogout() {
+                const token = localStorage.getItem('token');
+                if (token) {
+                    // Revoke the session on the server; the UI logs out regardless of the result
+                    fetch(LOGOUT_ENDPOINT, {
+                        method: 'POST',
+                        headers: {
+                            'Authorization': 'Bearer ' + token,
+                        }
+                    }).catch(error => console.error('Logout error:', error));
+                }
                 localStorage.removeItem('token');
                 profileTab.classList.add('hidden');
                 showTab('login');
@@ -449,48 +546,252 @@ const htmlTemplate = `<!DOCTYPE html>
 `
 
 func main() {
-	// Database configuration
-	// In a production environment, these would be environment variables
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run starts the service, or runs a subcommand, and returns once it has stopped.
+// Failures are returned rather than fatal, so that the teardown of whatever was
+// already started always runs: background jobs are stopped before the database is
+// closed.
+func run() error {
+	// Load configuration from the optional config file and the environment
+	cfg, err := config.Load(os.Getenv("AUTH_CONFIG_FILE"))
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	// "auth-service config" prints the effective configuration and exits
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		fmt.Print(cfg)
+		return nil
+	}
+
+	// Structured logs, tagged with the request ID for lines logged while serving a
+	// request. The standard logger writes through the same handler.
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.LogLevel())
+	if err != nil {
+		return fmt.Errorf("failed to set up logging: %w", err)
+	}
+	slog.SetDefault(logger)
+
+	log.Printf("Effective configuration:\n%s", cfg)
+
 	dbConfig := &database.Config{
-		Host:     "localhost",
-		Port:     5432,
-		User:     "postgres",
-		Password: "postgres",
-		DBName:   "auth_service",
-		SSLMode:  "disable",
+		Host:            cfg.Database.Host,
+		Port:            cfg.Database.Port,
+		User:            cfg.Database.User,
+		Password:        cfg.Database.Password,
+		DBName:          cfg.Database.Name,
+		SSLMode:         cfg.Database.SSLMode,
+		MaxOpenConns:    cfg.Database.MaxOpenConns,
+		MaxIdleConns:    cfg.Database.MaxIdleConns,
+		ConnMaxLifetime: cfg.Database.ConnMaxLifetime,
 	}
 
 	// Connect to database
 	db, err := database.NewConnection(dbConfig)
 	if err != nil {
-		log.Fatalf("Failed to connect to database: %v", err)
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	err = runWithDatabase(cfg, db)
+	if closeErr := db.Close(); closeErr != nil {
+		slog.Error("Failed to close database", "error", closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info("Shutdown complete")
+	return nil
+}
+
+// runWithDatabase migrates the schema and then runs a subcommand or the service.
+// The caller closes the database once it returns.
+func runWithDatabase(cfg *config.Config, db *sql.DB) error {
+	// "auth-service migrate ..." manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(db, os.Args[2:]); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+		return nil
 	}
-	defer db.Close()
 
-	// Create database tables if they don't exist
-	if err := database.CreateTables(db); err != nil {
-		log.Fatalf("Failed to create database tables: %v", err)
+	// Apply pending schema migrations
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
 	}
+	slog.Info("Database schema is up to date", "applied", applied)
 
+	// "auth-service clients ..." manages OpenID Connect clients and exits
+	if len(os.Args) > 1 && os.Args[1] == "clients" {
+		if err := runClients(database.NewOAuthRepository(db), os.Args[2:]); err != nil {
+			return fmt.Errorf("client management failed: %w", err)
+		}
+		return nil
+	}
+
+	// Signing keys stored in the database; rotated keys are published for the
+	// activation delay before they sign, and retired keys verify for one access
+	// token lifetime. Private keys are encrypted at rest when a key encryption key
+	// is configured, which Validate requires in production.
+	var keyOpts []keys.Option
+	if kek := cfg.KeyEncryptionKey(); kek != nil {
+		keyOpts = append(keyOpts, keys.WithKeyEncryptionKey(kek))
+	} else if cfg.JWT.KeyRing {
+		slog.Warn("Signing keys are stored unencrypted; set jwt.key_encryption_key")
+	}
+	keyManager := keys.NewManager(database.NewSigningKeyRepository(db), cfg.JWT.KeyAlgorithm, cfg.JWT.AccessTokenTTL, cfg.JWT.KeyActivationDelay, keyOpts...)
+
+	// "auth-service keys ..." manages the signing key ring and exits
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		if err := runKeys(keyManager, os.Args[2:]); err != nil {
+			return fmt.Errorf("key management failed: %w", err)
+		}
+		return nil
+	}
+
+	// Periodic jobs run until shutdown and are stopped before the database is
+	// closed, which flushes the audit log; see server.go
+	jobs := newBackgroundJobs()
+	err = runService(cfg, db, migrator, keyManager, jobs)
+	jobs.Stop()
+	return err
+}
+
+// runService serves requests until shutdown, or runs a subcommand that needs the
+// full service. Background jobs are started on jobs; the caller stops them once it
+// returns.
+func runService(cfg *config.Config, db *sql.DB, migrator *database.Migrator, keyManager *keys.Manager, jobs *backgroundJobs) error {
 	// Initialize repositories
 	userRepo := database.NewUserRepository(db)
+	sessionRepo := database.NewSessionRepository(db)
+	oauthRepo := database.NewOAuthRepository(db)
+	roleRepo := database.NewRoleRepository(db)
+
+	// Initialize JWT token manager. An asymmetric key lets other services verify
+	// tokens through the JWKS endpoint; otherwise tokens are signed with the shared secret.
+	var tokenManager *utils.TokenManager
+	var handlerOpts []auth.HandlerOption
+	if cfg.JWT.KeyRing {
+		keyRing, err := keyManager.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load JWT signing keys: %w", err)
+		}
+		slog.Info("Signing tokens with the database key ring", "alg", keyRing.Active().Method.Alg(), "kid", keyRing.Active().ID)
+		tokenManager = utils.NewTokenManagerWithKeyRing(keyRing, cfg.JWT.Issuer, cfg.JWT.AccessTokenTTL)
+		handlerOpts = append(handlerOpts, auth.WithKeyRotator(keyManager))
+
+		// Activate rotated keys when due and pick up rotations made by other instances
+		jobs.Go(func(ctx context.Context) { keyManager.Run(ctx, cfg.JWT.KeyRefreshInterval) })
+	} else if cfg.JWT.SigningKeyFile != "" {
+		signingKey, err := utils.LoadSigningKeyFile(cfg.JWT.KeyID, cfg.JWT.SigningKeyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load JWT signing key: %w", err)
+		}
+		slog.Info("Signing tokens with key file", "alg", signingKey.Method.Alg(), "kid", signingKey.ID)
+		tokenManager = utils.NewTokenManagerWithKey(signingKey, cfg.JWT.Issuer, cfg.JWT.AccessTokenTTL)
+	} else {
+		slog.Warn("Signing tokens with the shared HS256 secret; set jwt.signing_key_file to publish verification keys")
+		tokenManager = utils.NewTokenManager(cfg.JWT.Secret, cfg.JWT.Issuer, cfg.JWT.AccessTokenTTL)
+	}
 
-	// Initialize JWT token manager
-	// In a production environment, these would be environment variables
-	jwtSecret := "your-secret-key"        // Use a strong secret key in production
-	jwtIssuer := "auth-service"           // Your application name
-	jwtTTL := 24 * time.Hour              // Token validity duration
-	tokenManager := utils.NewTokenManager(jwtSecret, jwtIssuer, jwtTTL)
+	// Prometheus metrics, served at /metrics
+	var registry *metrics.Registry
+	if cfg.Metrics.Enabled {
+		registry = metrics.NewRegistry()
+		metrics.RegisterDBStats(registry, db)
+	}
 
 	// Initialize authentication service
-	authService := auth.NewAuthService(userRepo, tokenManager)
+	// Emails and text messages are written to the log until real providers are configured
+	authOpts := []auth.Option{
+		auth.WithRefreshTokenTTL(cfg.JWT.RefreshTokenTTL),
+		auth.WithMailer(notify.NewLogMailer()),
+		auth.WithSMSSender(notify.NewLogSMSSender()),
+		auth.WithPublicURL(cfg.Server.PublicURL),
+		auth.WithPasswordResetURL(cfg.Auth.PasswordResetURL),
+		auth.WithEmailVerificationRequired(cfg.EmailVerificationRoles()...),
+		auth.WithClientStore(oauthRepo),
+		auth.WithRoleStore(roleRepo),
+		auth.WithInvitationStore(userRepo),
+		auth.WithInvitationRequired(cfg.InvitationRequiredRoles()...),
+		auth.WithLockoutPolicy(cfg.Auth.LockoutThreshold, cfg.Auth.LockoutDuration, cfg.Auth.MaxLockoutDuration),
+		auth.WithTrustedProxies(cfg.TrustedProxies()),
+	}
+	if cfg.JWT.EmbedPermissions {
+		authOpts = append(authOpts, auth.WithPermissionsInToken())
+	}
+	if registry != nil {
+		authOpts = append(authOpts, auth.WithMetrics(registry))
+	}
+	if cfg.Audit.Enabled {
+		// Audit events are queued in memory and written in the background, so a slow
+		// database never holds up a login
+		auditRepo := database.NewAuditRepository(db)
+		auditLogger := audit.NewLogger(auditRepo, cfg.Audit.BufferSize)
+		jobs.Go(auditLogger.Run)
+		if registry != nil {
+			registry.NewCounterFunc("audit_events_dropped_total",
+				"Audit events lost to a full buffer or a failed write.",
+				func() float64 { return float64(auditLogger.Dropped()) })
+		}
+		authOpts = append(authOpts, auth.WithAuditLog(auditLogger, auditRepo))
+	}
+	authService := auth.NewAuthService(userRepo, sessionRepo, tokenManager, authOpts...)
+
+	// "auth-service invitations ..." invites privileged users and exits
+	if len(os.Args) > 1 && os.Args[1] == "invitations" {
+		if err := runInvitations(authService, os.Args[2:]); err != nil {
+			return fmt.Errorf("invitation management failed: %w", err)
+		}
+		return nil
+	}
 
 	// Initialize HTTP handler
-	httpHandler := auth.NewHTTPHandler(authService)
+	httpHandler := auth.NewHTTPHandler(authService, handlerOpts...)
+
+	// OpenID Connect provider for single sign-on; the public URL is its issuer
+	oidcProvider := oidc.NewProvider(authService, tokenManager, oauthRepo, cfg.Server.PublicURL)
+	if tokenManager.KeyRing().Active().IsSymmetric() {
+		slog.Warn("ID tokens are signed with the shared HS256 secret and cannot be verified by clients; configure an asymmetric signing key for OpenID Connect")
+	}
 
 	// Set up HTTP router
 	mux := http.NewServeMux()
 	httpHandler.SetupRoutes(mux)
+	oidcProvider.SetupRoutes(mux)
+
+	// Liveness and readiness probes for the orchestrator
+	healthChecker := health.NewChecker(cfg.Health.Timeout)
+	healthChecker.Add("database", db.PingContext)
+	healthChecker.Add("migrations", func(ctx context.Context) error {
+		pending, err := migrator.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		if pending > 0 {
+			return fmt.Errorf("%d migration(s) pending", pending)
+		}
+		return nil
+	})
+	if cfg.JWT.KeyRing {
+		// Allow a couple of failed refreshes before taking the instance out of service
+		healthChecker.Add("signing_keys", func(ctx context.Context) error {
+			return keyManager.CheckFresh(3 * cfg.JWT.KeyRefreshInterval)
+		})
+	}
+	mux.Handle("/healthz", healthChecker.LiveHandler())
+	mux.Handle("/readyz", healthChecker.ReadyHandler())
+	if registry != nil {
+		mux.Handle("/metrics", registry.Handler(cfg.Metrics.Token))
+	}
 	
 	// Add a handler for the root path to serve the UI
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -504,25 +805,95 @@ func main() {
 		http.NotFound(w, r)
 	})
 	
+	// Throttle password guessing and email flooding per client IP and per account.
+	// Buckets are kept in memory, so each instance enforces the limits on its own.
+	var handler http.Handler = mux
+	if cfg.RateLimit.Enabled {
+		rateStore := ratelimit.NewMemoryStore()
+		jobs.Go(func(ctx context.Context) { rateStore.Run(ctx, cfg.RateLimit.CleanupInterval) })
+		handler = ratelimit.New(rateStore, cfg.RateLimitRules(), cfg.TrustedProxies()).Middleware(mux)
+		slog.Info("Rate limiting enabled", "rules", len(cfg.RateLimit.Rules))
+	}
+
+	// Count and time requests, including those rejected by the rate limiter
+	if registry != nil {
+		handler = metrics.NewHTTPMetrics(registry, mux).Middleware(handler)
+	}
+
+	// Tag every request with an ID, outermost so that rate limited requests get
+	// one too
+	handler = logging.Middleware(handler)
+
 	// Print welcome message with usage information
+	baseURL := cfg.Server.PublicURL
 	log.Println("=================================================")
 	log.Println("Auth Service is running!")
 	log.Println("API Endpoints:")
-	log.Println("  POST http://localhost:8080/api/auth/signup - Create a new user")
-	log.Println("  POST http://localhost:8080/api/auth/login - Login")
-	log.Println("  GET http://localhost:8080/api/auth/profile - Get user profile (protected)")
+	log.Printf("  POST %s/api/auth/signup - Create a new user", baseURL)
+	log.Printf("  GET %s/.well-known/jwks.json - Public token verification keys", baseURL)
+	log.Printf("  POST %s/api/auth/login - Login", baseURL)
+	log.Printf("  POST %s/api/auth/refresh - Rotate refresh token", baseURL)
+	log.Printf("  GET/POST %s/api/auth/verify-email - Verify email address", baseURL)
+	log.Printf("  POST %s/api/auth/verify-email/resend - Resend verification email", baseURL)
+	log.Printf("  POST %s/api/auth/password/forgot - Request a password reset email", baseURL)
+	log.Printf("  POST %s/api/auth/password/reset - Reset password with a token", baseURL)
+	log.Printf("  GET %s/api/auth/profile - Get user profile (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/logout - Log out of this session (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/logout/all - Log out everywhere (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/phone/send-code - Text a phone verification code (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/phone/verify - Verify phone number (protected)", baseURL)
+	log.Printf("  GET %s/api/auth/users/contact?id=<id> - Get contact details (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/mfa/enroll - Start MFA enrollment (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/mfa/confirm - Confirm MFA enrollment (protected)", baseURL)
+	log.Printf("  POST %s/api/auth/mfa/disable - Disable MFA (protected)", baseURL)
+	log.Printf("  GET %s/api/auth/permissions - List your permissions (protected)", baseURL)
+	log.Printf("  GET/POST/PUT/DELETE %s/api/admin/roles - Manage roles (roles:manage)", baseURL)
+	log.Printf("  GET/POST/DELETE %s/api/admin/permissions - Manage permissions (roles:manage)", baseURL)
+	log.Printf("  GET/POST/DELETE %s/api/admin/invitations - Invite privileged users (users:manage)", baseURL)
+	log.Printf("  GET %s/api/admin/users - List users with filters and pagination (users:read)", baseURL)
+	log.Printf("  GET %s/api/admin/users/view?id=<id> - View a user (users:read)", baseURL)
+	log.Printf("  POST %s/api/admin/users/role - Change a user's role (users:manage)", baseURL)
+	log.Printf("  POST %s/api/admin/users/disable - Disable an account (users:manage)", baseURL)
+	log.Printf("  POST %s/api/admin/users/enable - Re-enable an account (users:manage)", baseURL)
+	log.Printf("  POST %s/api/admin/users/force-password-reset - Force a password reset (users:manage)", baseURL)
+	log.Printf("  POST %s/api/admin/users/revoke-sessions - Sign a user out everywhere (users:manage)", baseURL)
+	log.Printf("  POST %s/api/admin/users/unlock - Unlock an account locked by failed logins (users:manage)", baseURL)
+	log.Printf("  GET %s/api/admin/users/lockouts?id=<id> - Lockout history (users:read)", baseURL)
+	log.Printf("  GET %s/api/admin/audit - Search the audit log (audit:read)", baseURL)
+	if cfg.JWT.KeyRing {
+		log.Printf("  POST %s/api/admin/keys/rotate - Rotate the signing key (keys:rotate)", baseURL)
+	}
+	log.Printf("  GET %s/healthz - Liveness probe", baseURL)
+	log.Printf("  GET %s/readyz - Readiness probe with dependency checks", baseURL)
+	if registry != nil {
+		log.Printf("  GET %s/metrics - Prometheus metrics", baseURL)
+	}
+	log.Println("OpenID Connect:")
+	log.Printf("  GET %s/.well-known/openid-configuration - Provider metadata", baseURL)
+	log.Printf("  GET/POST %s/oauth/authorize - Hosted login (authorization code + PKCE)", baseURL)
+	log.Printf("  POST %s/oauth/token - Exchange a code or refresh token", baseURL)
+	log.Printf("  GET %s/oauth/userinfo - Claims for an access token", baseURL)
+	log.Printf("  POST %s/oauth/introspect - Check whether a token is active (client auth)", baseURL)
 	log.Println("Frontend:")
-	log.Println("  http://localhost:8080/ - Web interface")
+	log.Printf("  %s/ - Web interface", baseURL)
 	log.Println("=================================================")
 
-	// Start HTTP server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// Start HTTP server. The timeouts keep slow or idle clients from holding
+	// connections open indefinitely.
+	server := &http.Server{
+		Addr:              ":" + strconv.Itoa(cfg.Server.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		ErrorLog:          slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn),
 	}
 
-	log.Printf("Starting server on port %s...\n", port)
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatalf("Server error: %v", err)
+	slog.Info("Starting server", "port", cfg.Server.Port)
+	if err := serve(server, healthChecker, cfg.Server.DrainDelay, cfg.Server.ShutdownTimeout); err != nil {
+		return fmt.Errorf("server error: %w", err)
 	}
+	return nil
 }
\ No newline at end of file
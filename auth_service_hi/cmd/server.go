@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/health"
+)
+
+// backgroundJobs runs the service's periodic jobs and stops them together at
+// shutdown
+type backgroundJobs struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func newBackgroundJobs() *backgroundJobs {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &backgroundJobs{ctx: ctx, cancel: cancel}
+}
+
+// Go runs job in the background until Stop is called. job must return once its
+// context is cancelled.
+func (j *backgroundJobs) Go(job func(ctx context.Context)) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		job(j.ctx)
+	}()
+}
+
+// Stop cancels every job and waits for them to return, e.g. for the audit log to
+// write what it has buffered
+func (j *backgroundJobs) Stop() {
+	j.cancel()
+	j.wg.Wait()
+}
+
+// serve runs server until it fails or the process receives SIGTERM or SIGINT, then
+// shuts it down gracefully as serveUntil describes. A second signal during the
+// drain exits immediately.
+func serve(server *http.Server, checker *health.Checker, drainDelay, shutdownTimeout time.Duration) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	// Restore the default handling once the first signal arrives, so that a second
+	// signal kills the process
+	context.AfterFunc(ctx, stop)
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	return serveUntil(ctx, server, listener, checker, drainDelay, shutdownTimeout)
+}
+
+// serveUntil serves requests on listener until the server fails or ctx is
+// cancelled, then shuts down gracefully. Readiness fails straight away, new
+// requests are still served for drainDelay so that load balancers notice and stop
+// routing here, and then requests in flight get shutdownTimeout to complete before
+// their connections are closed.
+func serveUntil(ctx context.Context, server *http.Server, listener net.Listener, checker *health.Checker, drainDelay, shutdownTimeout time.Duration) error {
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-serverErr:
+		return err
+	case <-ctx.Done():
+	}
+
+	slog.Info("Shutting down, draining connections", "drain_delay", drainDelay, "shutdown_timeout", shutdownTimeout)
+	checker.Drain()
+	time.Sleep(drainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			slog.Warn("Requests still in flight at the shutdown deadline were cut off")
+		}
+		server.Close()
+		return err
+	}
+
+	// Serve returns ErrServerClosed as soon as Shutdown starts
+	if err := <-serverErr; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
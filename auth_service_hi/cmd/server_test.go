@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/health"
+)
+
+// shutdownLog records the order of shutdown steps
+type shutdownLog struct {
+	mu    sync.Mutex
+	steps []string
+}
+
+func (l *shutdownLog) add(step string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.steps = append(l.steps, step)
+}
+
+func (l *shutdownLog) get() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]string(nil), l.steps...)
+}
+
+// testServer serves /readyz and /slow, which blocks until release is closed, on an
+// httptest listener. It returns the server, the httptest.Server holding its
+// listener and URL, and the release channel.
+func testServer(t *testing.T, checker *health.Checker) (*http.Server, *httptest.Server, chan struct{}) {
+	t.Helper()
+
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.Handle("/readyz", checker.ReadyHandler())
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	})
+
+	// Only the listener is used; serveUntil runs the server
+	ts := httptest.NewUnstartedServer(mux)
+	ts.URL = "http://" + ts.Listener.Addr().String()
+	return &http.Server{Handler: mux}, ts, release
+}
+
+func get(t *testing.T, url string) int {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestShutdownOrder(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	server, ts, release := testServer(t, checker)
+
+	// The jobs, and so the audit log flush, stop only once the server has stopped
+	// taking requests
+	var order shutdownLog
+	jobs := newBackgroundJobs()
+	jobs.Go(func(ctx context.Context) {
+		<-ctx.Done()
+		if conn, err := net.Dial("tcp", ts.Listener.Addr().String()); err == nil {
+			conn.Close()
+			order.add("jobs stopped while serving")
+			return
+		}
+		order.add("jobs stopped")
+	})
+
+	// Run as main does: serve until shutdown, then stop the jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		err := serveUntil(ctx, server, ts.Listener, checker, 300*time.Millisecond, 5*time.Second)
+		jobs.Stop()
+		done <- err
+	}()
+
+	if status := get(t, ts.URL+"/readyz"); status != http.StatusOK {
+		t.Fatalf("/readyz before shutdown = %d, want %d", status, http.StatusOK)
+	}
+
+	// A request in flight when shutdown starts must still complete
+	inFlight := make(chan int, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/slow")
+		if err != nil {
+			inFlight <- 0
+			return
+		}
+		resp.Body.Close()
+		inFlight <- resp.StatusCode
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	cancel()
+
+	// Readiness fails during the drain, while new requests are still served and
+	// the jobs keep running
+	deadline := time.Now().Add(time.Second)
+	for get(t, ts.URL+"/readyz") != http.StatusServiceUnavailable {
+		if time.Now().After(deadline) {
+			t.Fatal("/readyz did not fail after shutdown began")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if steps := order.get(); len(steps) != 0 {
+		t.Fatalf("%v during the drain, want nothing stopped yet", steps)
+	}
+
+	close(release)
+	if status := <-inFlight; status != http.StatusOK {
+		t.Errorf("in-flight request status = %d, want %d", status, http.StatusOK)
+	}
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("serveUntil() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not finish")
+	}
+
+	if steps := order.get(); len(steps) != 1 || steps[0] != "jobs stopped" {
+		t.Errorf("shutdown steps = %v, want [jobs stopped]", steps)
+	}
+	if _, err := http.Get(ts.URL + "/readyz"); err == nil {
+		t.Error("server still accepts connections after shutdown")
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	checker := health.NewChecker(time.Second)
+	server, ts, release := testServer(t, checker)
+	defer close(release)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- serveUntil(ctx, server, ts.Listener, checker, 0, 100*time.Millisecond)
+	}()
+
+	// A request that outlives the shutdown timeout is cut off
+	inFlight := make(chan error, 1)
+	go func() {
+		resp, err := http.Get(ts.URL + "/slow")
+		if err == nil {
+			resp.Body.Close()
+		}
+		inFlight <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("serveUntil() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown did not give up at the timeout")
+	}
+	if err := <-inFlight; err == nil {
+		t.Error("request still in flight at the deadline completed")
+	}
+}
@@ -4,6 +4,8 @@ document.addEventListener('DOMContentLoaded', function() {
     const SIGNUP_ENDPOINT = `${API_URL}/api/auth/signup`;
     const LOGIN_ENDPOINT = `${API_URL}/api/auth/login`;
     const PROFILE_ENDPOINT = `${API_URL}/api/auth/profile`;
+    const LOGOUT_ENDPOINT = `${API_URL}/api/auth/logout`;
+    const RESET_ENDPOINT = `${API_URL}/api/auth/password/reset`;
 
     // DOM elements
     const loginTab = document.getElementById('loginTab');
@@ -11,12 +13,14 @@ document.addEventListener('DOMContentLoaded', function() {
     const profileTab = document.getElementById('profileTab');
     const loginForm = document.getElementById('loginForm');
     const signupForm = document.getElementById('signupForm');
+    const resetForm = document.getElementById('resetForm');
     const profilePage = document.getElementById('profilePage');
     const logoutButton = document.getElementById('logoutButton');
 
     // Messages
     const loginMessage = document.getElementById('loginMessage');
     const signupMessage = document.getElementById('signupMessage');
+    const resetMessage = document.getElementById('resetMessage');
 
     // Check if user is already logged in
     checkAuthStatus();
@@ -29,8 +33,23 @@ document.addEventListener('DOMContentLoaded', function() {
     // Form submissions
     document.getElementById('login').addEventListener('submit', handleLogin);
     document.getElementById('signup').addEventListener('submit', handleSignup);
+    document.getElementById('reset').addEventListener('submit', handleReset);
     logoutButton.addEventListener('click', handleLogout);
 
+    // An invitation link carries a token that also decides the role
+    const invitationToken = new URLSearchParams(window.location.search).get('invitation');
+    if (invitationToken) {
+        document.getElementById('signupRoleGroup').style.display = 'none';
+        document.getElementById('signupRole').required = false;
+        showTab('signup');
+    }
+
+    // A password reset link carries the token that authorises the new password
+    const resetToken = new URLSearchParams(window.location.search).get('reset');
+    if (resetToken) {
+        showTab('reset');
+    }
+
     // Tab switching function
     function showTab(tabName) {
         // Hide all tabs
@@ -39,6 +58,7 @@ document.addEventListener('DOMContentLoaded', function() {
         profileTab.classList.remove('active');
         loginForm.classList.remove('active');
         signupForm.classList.remove('active');
+        resetForm.classList.remove('active');
         profilePage.classList.remove('active');
 
         // Show selected tab
@@ -48,6 +68,8 @@ document.addEventListener('DOMContentLoaded', function() {
         } else if (tabName === 'signup') {
             signupTab.classList.add('active');
             signupForm.classList.add('active');
+        } else if (tabName === 'reset') {
+            resetForm.classList.add('active');
         } else if (tabName === 'profile') {
             profileTab.classList.add('active');
             profilePage.classList.add('active');
@@ -75,6 +97,7 @@ document.addEventListener('DOMContentLoaded', function() {
         
         const email = document.getElementById('loginEmail').value;
         const password = document.getElementById('loginPassword').value;
+        const otp_code = document.getElementById('loginOTP').value;
         
         try {
             const response = await fetch(LOGIN_ENDPOINT, {
@@ -82,7 +105,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 headers: {
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({ email, password })
+                body: JSON.stringify({ email, password, otp_code })
             });
             
             const data = await response.json();
@@ -117,6 +140,11 @@ document.addEventListener('DOMContentLoaded', function() {
         const phone_number = document.getElementById('signupPhone').value;
         const password = document.getElementById('signupPassword').value;
         const role = document.getElementById('signupRole').value;
+        const payload = { name, email, phone_number, password, role };
+        if (invitationToken) {
+            payload.role = '';
+            payload.invitation_token = invitationToken;
+        }
         
         try {
             const response = await fetch(SIGNUP_ENDPOINT, {
@@ -124,13 +152,7 @@ document.addEventListener('DOMContentLoaded', function() {
                 headers: {
                     'Content-Type': 'application/json',
                 },
-                body: JSON.stringify({
-                    name,
-                    email,
-                    phone_number,
-                    password,
-                    role
-                })
+                body: JSON.stringify(payload)
             });
             
             const data = await response.json();
@@ -153,6 +175,43 @@ document.addEventListener('DOMContentLoaded', function() {
         }
     }
 
+    // Handle password reset form submission
+    async function handleReset(event) {
+        event.preventDefault();
+
+        const password = document.getElementById('resetPassword').value;
+
+        try {
+            const response = await fetch(RESET_ENDPOINT, {
+                method: 'POST',
+                headers: {
+                    'Content-Type': 'application/json',
+                },
+                body: JSON.stringify({ token: resetToken, password })
+            });
+
+            const data = await response.json();
+
+            if (response.ok) {
+                resetMessage.textContent = 'Password changed. Please login with your new password.';
+                resetMessage.className = 'message success';
+                document.getElementById('reset').reset();
+                // The reset signed the user out everywhere, and the link cannot be used again
+                localStorage.removeItem('token');
+                profileTab.classList.add('hidden');
+                window.history.replaceState(null, '', window.location.pathname);
+                setTimeout(() => showTab('login'), 2000);
+            } else {
+                resetMessage.textContent = data.error || 'Password reset failed. Please request a new link.';
+                resetMessage.className = 'message error';
+            }
+        } catch (error) {
+            resetMessage.textContent = 'An error occurred. Please try again later.';
+            resetMessage.className = 'message error';
+            console.error('Password reset error:', error);
+        }
+    }
+
     // Fetch profile data
     async function fetchProfile() {
         const token = localStorage.getItem('token');
@@ -192,6 +251,16 @@ document.addEventListener('DOMContentLoaded', function() {
 
     // Handle logout
     function handleLogout() {
+        const token = localStorage.getItem('token');
+        if (token) {
+            // Revoke the session on the server; the UI logs out regardless of the result
+            fetch(LOGOUT_ENDPOINT, {
+                method: 'POST',
+                headers: {
+                    'Authorization': 'Bearer ' + token,
+                }
+            }).catch(error => console.error('Logout error:', error));
+        }
         localStorage.removeItem('token');
         profileTab.classList.add('hidden');
         showTab('login');
@@ -0,0 +1,122 @@
+// Package audit writes the authentication audit log in the background. Record never
+// blocks: events go into a bounded buffer and a single writer inserts them in
+// batches, so a slow or unavailable database delays or drops audit events but never
+// the login or admin action being audited.
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"sync/atomic"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const (
+	// maxBatchSize bounds how many events are inserted in one statement
+	maxBatchSize = 100
+
+	// writeTimeout bounds each insert, including those that flush the buffer on
+	// shutdown
+	writeTimeout = 5 * time.Second
+)
+
+// Store appends events to the audit log
+type Store interface {
+	InsertAuthEvents(ctx context.Context, events []models.AuthEvent) error
+}
+
+// Logger buffers audit events and writes them to a Store
+type Logger struct {
+	store   Store
+	events  chan models.AuthEvent
+	dropped atomic.Uint64
+}
+
+// NewLogger creates a logger that buffers up to bufferSize events. Events are only
+// written while Run is running.
+func NewLogger(store Store, bufferSize int) *Logger {
+	return &Logger{
+		store:  store,
+		events: make(chan models.AuthEvent, bufferSize),
+	}
+}
+
+// Record queues an event, assigning its ID and time if they are not set. If the
+// buffer is full the event is dropped and counted.
+func (l *Logger) Record(event models.AuthEvent) {
+	if event.ID == "" {
+		event.ID = utils.GenerateUUID(models.UserRole("event"))
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	select {
+	case l.events <- event:
+	default:
+		if dropped := l.dropped.Add(1); dropped == 1 || dropped%1000 == 0 {
+			slog.Warn("Audit log buffer full, dropping events", "dropped", dropped)
+		}
+	}
+}
+
+// Dropped returns the number of events lost to a full buffer or a failed write
+func (l *Logger) Dropped() uint64 {
+	return l.dropped.Load()
+}
+
+// Run writes queued events until ctx is cancelled, then writes whatever is still
+// buffered and returns. Writes are not cancelled with ctx, so that the final flush
+// and a batch that is being written when ctx is cancelled still reach the store.
+func (l *Logger) Run(ctx context.Context) {
+	writeCtx := context.WithoutCancel(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			l.flush(writeCtx)
+			return
+		case event := <-l.events:
+			l.write(writeCtx, l.batch(event))
+		}
+	}
+}
+
+// batch collects first and whatever else is already buffered, up to maxBatchSize
+func (l *Logger) batch(first models.AuthEvent) []models.AuthEvent {
+	batch := []models.AuthEvent{first}
+	for len(batch) < maxBatchSize {
+		select {
+		case event := <-l.events:
+			batch = append(batch, event)
+		default:
+			return batch
+		}
+	}
+	return batch
+}
+
+// flush writes every buffered event
+func (l *Logger) flush(ctx context.Context) {
+	for {
+		select {
+		case event := <-l.events:
+			l.write(ctx, l.batch(event))
+		default:
+			return
+		}
+	}
+}
+
+// write inserts a batch, dropping it if the store fails
+func (l *Logger) write(ctx context.Context, batch []models.AuthEvent) {
+	ctx, cancel := context.WithTimeout(ctx, writeTimeout)
+	defer cancel()
+
+	if err := l.store.InsertAuthEvents(ctx, batch); err != nil {
+		l.dropped.Add(uint64(len(batch)))
+		slog.ErrorContext(ctx, "Failed to write audit events", "events", len(batch), "error", err)
+	}
+}
@@ -0,0 +1,165 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// fakeStore records the batches written to it, or fails every write if err is set
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]models.AuthEvent
+	err     error
+}
+
+func (s *fakeStore) InsertAuthEvents(ctx context.Context, events []models.AuthEvent) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, append([]models.AuthEvent(nil), events...))
+	return nil
+}
+
+// batchSizes returns the number of events in each batch written so far
+func (s *fakeStore) batchSizes() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sizes := make([]int, len(s.batches))
+	for i, batch := range s.batches {
+		sizes[i] = len(batch)
+	}
+	return sizes
+}
+
+// written returns the total number of events written so far
+func (s *fakeStore) written() int {
+	total := 0
+	for _, size := range s.batchSizes() {
+		total += size
+	}
+	return total
+}
+
+// record queues n login events
+func record(l *Logger, n int) {
+	for i := 0; i < n; i++ {
+		l.Record(models.AuthEvent{Type: models.EventLogin, Outcome: models.OutcomeSuccess})
+	}
+}
+
+// runCancelled runs the logger with a context that is already cancelled, so Run
+// only flushes what is buffered
+func runCancelled(l *Logger) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	l.Run(ctx)
+}
+
+func TestRecord(t *testing.T) {
+	store := &fakeStore{}
+	l := NewLogger(store, 10)
+
+	createdAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	l.Record(models.AuthEvent{Type: models.EventLogin})
+	l.Record(models.AuthEvent{ID: "event_given", Type: models.EventLogout, CreatedAt: createdAt})
+	runCancelled(l)
+
+	if sizes := store.batchSizes(); len(sizes) != 1 || sizes[0] != 2 {
+		t.Fatalf("batch sizes = %v, want [2]", sizes)
+	}
+	generated, given := store.batches[0][0], store.batches[0][1]
+	if !strings.HasPrefix(generated.ID, "event_") || generated.CreatedAt.IsZero() {
+		t.Errorf("event without an ID or time recorded as %+v", generated)
+	}
+	if given.ID != "event_given" || !given.CreatedAt.Equal(createdAt) {
+		t.Errorf("event with an ID and time recorded as %+v", given)
+	}
+}
+
+func TestFullBufferDropsEvents(t *testing.T) {
+	store := &fakeStore{}
+	l := NewLogger(store, 3)
+
+	// Nothing drains the buffer until Run starts, and Record must not block
+	record(l, 5)
+	if got := l.Dropped(); got != 2 {
+		t.Errorf("Dropped() = %d, want 2", got)
+	}
+
+	runCancelled(l)
+	if got := store.written(); got != 3 {
+		t.Errorf("%d events written, want the 3 buffered", got)
+	}
+}
+
+func TestFailedWriteDropsBatch(t *testing.T) {
+	store := &fakeStore{err: errors.New("database unavailable")}
+	l := NewLogger(store, 10)
+
+	record(l, 4)
+	runCancelled(l)
+	if got := l.Dropped(); got != 4 {
+		t.Errorf("Dropped() = %d, want 4", got)
+	}
+}
+
+func TestRunFlushesOnCancel(t *testing.T) {
+	store := &fakeStore{}
+	l := NewLogger(store, 500)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx)
+		close(done)
+	}()
+
+	record(l, 250)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancel")
+	}
+
+	// The final flush writes with a live context even though ctx is cancelled
+	if got := store.written(); got != 250 {
+		t.Errorf("%d events written, want 250", got)
+	}
+	if got := l.Dropped(); got != 0 {
+		t.Errorf("Dropped() = %d, want 0", got)
+	}
+}
+
+func TestBatchSizeCapped(t *testing.T) {
+	store := &fakeStore{}
+	l := NewLogger(store, 3*maxBatchSize)
+
+	record(l, 2*maxBatchSize+50)
+	runCancelled(l)
+
+	want := []int{maxBatchSize, maxBatchSize, 50}
+	got := store.batchSizes()
+	if len(got) != len(want) {
+		t.Fatalf("batch sizes = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("batch sizes = %v, want %v", got, want)
+		}
+	}
+}
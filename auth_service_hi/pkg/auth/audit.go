@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/ratelimit"
+)
+
+var ErrAuditNotConfigured = errors.New("audit log is not configured")
+
+const (
+	defaultEventsPerPage = 50
+	maxEventsPerPage     = 200
+)
+
+// AuditRecorder receives audit events. Record must not block; audit.Logger queues
+// events and writes them in the background.
+type AuditRecorder interface {
+	Record(event models.AuthEvent)
+}
+
+// WithAuditLog records signups, logins, account changes and admin actions with
+// recorder, and serves the recorded events from store through ListAuthEvents
+func WithAuditLog(recorder AuditRecorder, store database.AuditStore) Option {
+	return func(s *AuthService) {
+		s.auditRecorder = recorder
+		s.auditStore = store
+	}
+}
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For header is believed when
+// recording client IPs in the audit log
+func WithTrustedProxies(proxies ratelimit.TrustedProxies) Option {
+	return func(s *AuthService) {
+		s.trustedProxies = proxies
+	}
+}
+
+// Audit records an event about the request r, filling in the client IP, the user
+// agent and, for authenticated requests, the acting user. A nil err records a
+// success; otherwise a failure, with the error added to the detail.
+func (s *AuthService) Audit(r *http.Request, event models.AuthEvent, err error) {
+	if s.auditRecorder == nil {
+		return
+	}
+
+	event.Outcome = models.OutcomeSuccess
+	if err != nil {
+		event.Outcome = models.OutcomeFailure
+		if event.Detail == "" {
+			event.Detail = err.Error()
+		} else {
+			event.Detail += ": " + err.Error()
+		}
+	}
+	if event.ActorID == "" {
+		if user := GetUserFromContext(r.Context()); user != nil {
+			event.ActorID = user.ID
+		}
+	}
+	event.IPAddress = s.trustedProxies.ClientIP(r)
+	event.UserAgent = r.UserAgent()
+
+	s.auditRecorder.Record(event)
+}
+
+// ListAuthEvents returns one page of the audit events matching the filter, newest
+// first. A zero page or per_page selects the first page of 50 events.
+func (s *AuthService) ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) (*models.AuthEventListResponse, error) {
+	if s.auditStore == nil {
+		return nil, ErrAuditNotConfigured
+	}
+
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage == 0 {
+		filter.PerPage = defaultEventsPerPage
+	}
+	if filter.Page < 1 || filter.PerPage < 1 || filter.PerPage > maxEventsPerPage {
+		return nil, ErrInvalidPage
+	}
+
+	events, total, err := s.auditStore.ListAuthEvents(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if events == nil {
+		events = []models.AuthEvent{}
+	}
+
+	return &models.AuthEventListResponse{
+		Events:  events,
+		Total:   total,
+		Page:    filter.Page,
+		PerPage: filter.PerPage,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"net/http"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// AuthEventsHandler returns a page of the audit log. Query parameters: user_id (actor
+// or target), email, type, outcome (success/failure), since and until (RFC 3339),
+// page and per_page.
+func (h *HTTPHandler) AuthEventsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, err := parseAuthEventFilter(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	events, err := h.authService.ListAuthEvents(r.Context(), filter)
+	if err != nil {
+		switch err {
+		case ErrInvalidPage:
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+		case ErrAuditNotConfigured:
+			RespondWithError(w, http.StatusNotImplemented, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Failed to list audit events", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, events)
+}
+
+// parseAuthEventFilter reads the audit log filters from the query string
+func parseAuthEventFilter(r *http.Request) (models.AuthEventFilter, error) {
+	query := r.URL.Query()
+	filter := models.AuthEventFilter{
+		UserID:  query.Get("user_id"),
+		Email:   query.Get("email"),
+		Type:    models.AuthEventType(query.Get("type")),
+		Outcome: models.AuthEventOutcome(query.Get("outcome")),
+	}
+
+	var err error
+	if filter.Since, err = queryTime(query, "since"); err != nil {
+		return filter, err
+	}
+	if filter.Until, err = queryTime(query, "until"); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = queryInt(query, "page"); err != nil {
+		return filter, err
+	}
+	if filter.PerPage, err = queryInt(query, "per_page"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
@@ -1,41 +1,181 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"log/slog"
+	"strings"
 	"time"
 
 	"github.com/herb-immortal/auth_service_hi/pkg/database"
 	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/notify"
+	"github.com/herb-immortal/auth_service_hi/pkg/ratelimit"
 	"github.com/herb-immortal/auth_service_hi/pkg/utils"
 )
 
 var (
-	ErrInvalidCredentials  = errors.New("invalid email or password")
-	ErrUserAlreadyExists   = errors.New("user with this email already exists")
-	ErrInvalidRole         = errors.New("invalid role")
-	ErrInvalidOTP          = errors.New("invalid OTP code")
+	ErrInvalidCredentials   = errors.New("invalid email or password")
+	ErrUserAlreadyExists    = errors.New("user with this email already exists")
+	ErrInvalidRole          = errors.New("invalid role")
+	ErrInvalidOTP           = errors.New("invalid OTP code")
+	ErrOTPRequired          = errors.New("OTP code required")
+	ErrMFAAlreadyEnabled    = errors.New("multi-factor authentication is already enabled")
+	ErrMFANotEnrolled       = errors.New("multi-factor authentication enrollment has not been started")
+	ErrMFANotEnabled        = errors.New("multi-factor authentication is not enabled")
 	ErrVerificationRequired = errors.New("email or phone verification required")
-	ErrInvalidSession      = errors.New("invalid or expired session")
+	ErrInvalidSession       = errors.New("invalid or expired session")
+	ErrInvalidRefreshToken  = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused   = errors.New("refresh token has already been used")
+	ErrInvalidToken         = errors.New("invalid or expired token")
+	ErrTooManyRequests      = errors.New("too many requests, please try again later")
+	ErrInvalidCode          = errors.New("invalid or expired verification code")
+	ErrTooManyAttempts      = errors.New("too many failed attempts, please request a new code")
+	ErrAlreadyVerified      = errors.New("already verified")
+	ErrUserNotFound         = errors.New("user not found")
+	ErrPasswordTooShort     = errors.New("password must be at least 8 characters")
+	ErrClientToken          = errors.New("client tokens cannot be used on behalf of a user")
+	ErrNotClientToken       = errors.New("a client token is required")
+	ErrInsufficientScope    = errors.New("token does not have the required scope")
 )
 
+// mfaIssuer is the account issuer shown in authenticator apps
+const mfaIssuer = "Herb Immortal"
+
+// DefaultRefreshTokenTTL is how long a login can be kept alive through refresh tokens
+const DefaultRefreshTokenTTL = 30 * 24 * time.Hour
+
 // AuthService handles authentication operations
 type AuthService struct {
-	userRepo     *database.UserRepository
+	users        database.UserStore
+	sessions     database.SessionStore
 	tokenManager *utils.TokenManager
+	refreshTTL   time.Duration
+	mailer       notify.Mailer
+	smsSender    notify.SMSSender
+	publicURL    string
+	resetURL     string
+
+	// Roles that must verify their email address before they can log in
+	emailVerificationRequired map[models.UserRole]bool
+
+	// Account lockout after repeated failed logins; see lockout.go
+	lockoutThreshold   int
+	lockoutDuration    time.Duration
+	maxLockoutDuration time.Duration
+
+	// Invitations for privileged signups; see invitation.go
+	invitations        database.InvitationStore
+	invitationRequired map[models.UserRole]bool
+
+	// OAuth clients, checked when validating client tokens
+	clients database.OAuthStore
+
+	// Role-based access control; see rbac.go
+	roles            database.RoleStore
+	embedPermissions bool
+	permissionCache  *permissionCache
+
+	// Audit log; see audit.go
+	auditRecorder  AuditRecorder
+	auditStore     database.AuditStore
+	trustedProxies ratelimit.TrustedProxies
+
+	// Prometheus metrics; see metrics.go
+	metrics *authMetrics
+}
+
+// Option configures optional AuthService behaviour
+type Option func(*AuthService)
+
+// WithRefreshTokenTTL sets the absolute lifetime of a refresh token family
+func WithRefreshTokenTTL(ttl time.Duration) Option {
+	return func(s *AuthService) {
+		s.refreshTTL = ttl
+	}
+}
+
+// WithMailer sets the mailer used for verification and notification emails
+func WithMailer(mailer notify.Mailer) Option {
+	return func(s *AuthService) {
+		s.mailer = mailer
+	}
+}
+
+// WithSMSSender sets the sender used for phone verification codes
+func WithSMSSender(sender notify.SMSSender) Option {
+	return func(s *AuthService) {
+		s.smsSender = sender
+	}
+}
+
+// WithPublicURL sets the externally reachable base URL used to build links in emails
+func WithPublicURL(publicURL string) Option {
+	return func(s *AuthService) {
+		s.publicURL = strings.TrimRight(publicURL, "/")
+	}
+}
+
+// WithPasswordResetURL sets the page that password reset emails link to. The reset
+// token is appended as the "token" query parameter. When empty, emails link to the
+// bundled UI at <public URL>/?reset=<token>.
+func WithPasswordResetURL(resetURL string) Option {
+	return func(s *AuthService) {
+		s.resetURL = resetURL
+	}
 }
 
-// NewAuthService creates a new authentication service
-func NewAuthService(userRepo *database.UserRepository, tokenManager *utils.TokenManager) *AuthService {
-	return &AuthService{
-		userRepo:     userRepo,
+// WithClientStore makes ValidateClientToken check that the token's client is still
+// registered, so that deleting a client revokes the tokens it was issued
+func WithClientStore(clients database.OAuthStore) Option {
+	return func(s *AuthService) {
+		s.clients = clients
+	}
+}
+
+// WithEmailVerificationRequired makes Login fail for users with the given roles
+// until they have verified their email address
+func WithEmailVerificationRequired(roles ...models.UserRole) Option {
+	return func(s *AuthService) {
+		for _, role := range roles {
+			s.emailVerificationRequired[role] = true
+		}
+	}
+}
+
+// NewAuthService creates a new authentication service. The stores can be backed by
+// PostgreSQL (database.UserRepository and database.SessionRepository) or by a
+// database.MemoryStore, which implements both.
+func NewAuthService(users database.UserStore, sessions database.SessionStore, tokenManager *utils.TokenManager, opts ...Option) *AuthService {
+	s := &AuthService{
+		users:        users,
+		sessions:     sessions,
 		tokenManager: tokenManager,
+		refreshTTL:   DefaultRefreshTokenTTL,
+		mailer:       notify.NewLogMailer(),
+		smsSender:    notify.NewLogSMSSender(),
+		publicURL:    "http://localhost:8080",
+
+		lockoutThreshold:   DefaultLockoutThreshold,
+		lockoutDuration:    DefaultLockoutDuration,
+		maxLockoutDuration: DefaultMaxLockoutDuration,
+
+		emailVerificationRequired: make(map[models.UserRole]bool),
+		invitationRequired:        make(map[models.UserRole]bool),
+		permissionCache:           newPermissionCache(permissionCacheTTL),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Signup registers a new user
-func (s *AuthService) Signup(req models.SignupRequest) (*models.User, error) {
+func (s *AuthService) Signup(ctx context.Context, req models.SignupRequest) (created *models.User, err error) {
+	defer func() { s.metrics.recordSignup(req.Role, err) }()
+
 	// Check if user with this email already exists
-	existingUser, err := s.userRepo.GetUserByEmail(req.Email)
+	existingUser, err := s.users.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -43,16 +183,20 @@ func (s *AuthService) Signup(req models.SignupRequest) (*models.User, error) {
 		return nil, ErrUserAlreadyExists
 	}
 
-	// Validate role
-	if req.Role != models.RoleCustomer && 
-	   req.Role != models.RoleAdmin && 
-	   req.Role != models.RoleHealer && 
-	   req.Role != models.RoleVendor {
-		return nil, ErrInvalidRole
+	// Privileged roles can only be taken with an invitation, which also fixes the role
+	var invitation *models.Invitation
+	if req.InvitationToken != "" {
+		invitation, err = s.lookupInvitation(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		req.Role = invitation.Role
+	} else if err := s.checkSignupRole(ctx, req.Role); err != nil {
+		return nil, err
 	}
 
 	// Hash password
-	passwordHash, err := utils.HashPassword(req.Password)
+	passwordHash, err := s.hashPassword(req.Password)
 	if err != nil {
 		return nil, err
 	}
@@ -71,22 +215,42 @@ func (s *AuthService) Signup(req models.SignupRequest) (*models.User, error) {
 		UpdatedAt:    now,
 	}
 
+	// The invitation link was emailed, so accepting it proves the address. The
+	// invitation is consumed in the same transaction that creates the user.
+	if invitation != nil {
+		user.EmailVerified = true
+		accepted, err := s.invitations.CreateUserWithInvitation(ctx, user, invitation.ID)
+		if err != nil {
+			return nil, err
+		}
+		if !accepted {
+			return nil, ErrInvalidInvitation
+		}
+		return user, nil
+	}
+
 	// Save user to database
-	err = s.userRepo.CreateUser(user)
+	err = s.users.CreateUser(ctx, user)
 	if err != nil {
 		return nil, err
 	}
 
-	// In a real implementation, you would send verification OTPs here
-	// and initialize MFA if required
+	// Send the email verification link. A delivery failure should not fail the
+	// signup because the user can ask for a new link.
+	if err := s.sendEmailVerification(ctx, user); err != nil {
+		slog.ErrorContext(ctx, "Failed to send verification email", "user_id", user.ID, "error", err)
+	}
 
 	return user, nil
 }
 
 // Login authenticates a user and returns a session token
-func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, error) {
+func (s *AuthService) Login(ctx context.Context, req models.LoginRequest) (response *models.AuthResponse, err error) {
+	var user *models.User
+	defer func() { s.metrics.recordLogin(user, err) }()
+
 	// Find user by email
-	user, err := s.userRepo.GetUserByEmail(req.Email)
+	user, err = s.users.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, err
 	}
@@ -94,99 +258,443 @@ func (s *AuthService) Login(req models.LoginRequest) (*models.AuthResponse, erro
 		return nil, ErrInvalidCredentials
 	}
 
+	// A locked account refuses every attempt, so guessing cannot continue
+	if user.IsLocked(time.Now()) {
+		return nil, &AccountLockedError{Until: *user.LockedUntil}
+	}
+
 	// Verify password
-	if !utils.CheckPassword(req.Password, user.PasswordHash) {
-		return nil, ErrInvalidCredentials
+	if !s.checkPassword(req.Password, user.PasswordHash) {
+		return nil, s.recordLoginFailure(ctx, user, ErrInvalidCredentials)
 	}
 
-	// In a real implementation, you would verify OTP/2FA here
-	// For simplicity, we'll skip that in this basic implementation
-	// if user.MFASecret != "" && req.OTPCode == "" {
-	//     return nil, errors.New("OTP code required")
-	// }
-	// if user.MFASecret != "" {
-	//     // Verify OTP code
-	//     valid := verifyOTP(user.MFASecret, req.OTPCode)
-	//     if !valid {
-	//         return nil, ErrInvalidOTP
-	//     }
-	// }
+	// Disabled accounts cannot start new sessions
+	if user.IsDisabled() {
+		return nil, ErrAccountDisabled
+	}
 
-	// Generate JWT token
-	token, expiresAt, err := s.tokenManager.GenerateToken(user.ID, user.Role)
+	// Some roles may not log in before confirming their email address
+	if s.emailVerificationRequired[user.Role] && !user.EmailVerified {
+		return nil, ErrVerificationRequired
+	}
+
+	// Verify the TOTP code for users who have completed MFA enrollment
+	if user.MFAEnabled {
+		if req.OTPCode == "" {
+			return nil, ErrOTPRequired
+		}
+		valid, err := s.validateOTP(ctx, user, req.OTPCode)
+		if err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, s.recordLoginFailure(ctx, user, ErrInvalidOTP)
+		}
+	}
+
+	// A successful login starts the lockout policy over
+	if user.FailedLoginCount > 0 || user.LockoutCount > 0 {
+		if err := s.users.ResetFailedLogins(ctx, user.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate session ID (for database/Redis storage). The session lives as long
+	// as the refresh token family, not just the short-lived access token.
+	now := time.Now()
+	session := &models.Session{
+		ID:        utils.GenerateUUID(models.UserRole("session")),
+		UserID:    user.ID,
+		ExpiresAt: now.Add(s.refreshTTL),
+		CreatedAt: now,
+	}
+
+	// Store session in database
+	err = s.sessions.SaveSession(ctx, session)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate session ID (for database/Redis storage)
-	sessionID := utils.GenerateUUID(models.UserRole("session"))
+	return s.issueTokens(ctx, user, &models.RefreshToken{
+		SessionID: session.ID,
+		ExpiresAt: session.ExpiresAt,
+	}, "")
+}
 
-	// Store session in database
-	err = s.userRepo.SaveSession(sessionID, user.ID, expiresAt)
+// Refresh exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once; presenting an already rotated token is treated
+// as theft and revokes every token issued for the same login. A refresh token can
+// only be redeemed by the client it was issued to; clientID is empty for first-party
+// logins.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken, clientID string) (*models.AuthResponse, error) {
+	current, err := s.sessions.GetRefreshTokenByHash(ctx, utils.HashToken(refreshToken))
 	if err != nil {
 		return nil, err
 	}
+	if current == nil || current.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// A token issued to another client is not a valid grant for this one
+	if current.ClientID != clientID {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Reuse of a rotated token means it was copied; kill the whole family
+	if current.RotatedAt != nil {
+		if err := s.Logout(ctx, current.SessionID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if current.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// The session may have been ended by a logout since the token was issued
+	session, err := s.sessions.GetSession(ctx, current.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := s.users.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.IsDisabled() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	return s.issueTokens(ctx, user, &models.RefreshToken{
+		SessionID: current.SessionID,
+		ClientID:  current.ClientID,
+		Scope:     current.Scope,
+		ExpiresAt: current.ExpiresAt,
+	}, current.ID)
+}
+
+// IssueSessionTokens creates a new access token and refresh token for an existing
+// session. Single sign-on uses it to give an application its own tokens for the
+// session the user started on the hosted login page; logging out of that session
+// revokes them all. The tokens carry the scope granted to the client, and the
+// refresh token can only be redeemed by that client.
+func (s *AuthService) IssueSessionTokens(ctx context.Context, sessionID, clientID, scope string) (*models.AuthResponse, error) {
+	session, user, err := s.activeSession(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, &models.RefreshToken{
+		SessionID: session.ID,
+		ClientID:  clientID,
+		Scope:     scope,
+		ExpiresAt: session.ExpiresAt,
+	}, "")
+}
+
+// issueTokens creates an access token and a refresh token for the session, client
+// and scope of grant, which expires the new refresh token with the rest of its
+// family. When rotatedFromID is set, the refresh token with that ID is marked as
+// used in the same transaction that stores the new one.
+func (s *AuthService) issueTokens(ctx context.Context, user *models.User, grant *models.RefreshToken, rotatedFromID string) (*models.AuthResponse, error) {
+	// Embed the role's permissions so other services can authorize without a lookup
+	var permissions []string
+	if s.embedPermissions {
+		var err error
+		permissions, err = s.RolePermissions(ctx, user.Role)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate JWT token
+	token, expiresAt, err := s.tokenManager.GenerateToken(user.ID, user.Role, grant.SessionID, grant.Scope, permissions...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Generate opaque refresh token; only its hash is stored
+	refreshToken, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	record := &models.RefreshToken{
+		ID:        utils.GenerateUUID(models.UserRole("refresh")),
+		UserID:    user.ID,
+		SessionID: grant.SessionID,
+		ClientID:  grant.ClientID,
+		Scope:     grant.Scope,
+		TokenHash: utils.HashToken(refreshToken),
+		ExpiresAt: grant.ExpiresAt,
+		CreatedAt: time.Now(),
+	}
+
+	if rotatedFromID == "" {
+		err = s.sessions.SaveRefreshToken(ctx, record)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		rotated, err := s.sessions.RotateRefreshToken(ctx, rotatedFromID, record)
+		if err != nil {
+			return nil, err
+		}
+		if !rotated {
+			// Another request exchanged this token first
+			if err := s.Logout(ctx, grant.SessionID); err != nil {
+				return nil, err
+			}
+			return nil, ErrRefreshTokenReused
+		}
+	}
 
 	// Return response with token and user info
 	return &models.AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
+		Token:            token,
+		RefreshToken:     refreshToken,
+		ExpiresAt:        expiresAt,
+		RefreshExpiresAt: grant.ExpiresAt,
+		SessionID:        grant.SessionID,
 		User: models.User{
-			ID:           user.ID,
-			Email:        user.Email,
-			PhoneNumber:  user.PhoneNumber,
-			Name:         user.Name,
-			Role:         user.Role,
-			CreatedAt:    user.CreatedAt,
-			UpdatedAt:    user.UpdatedAt,
+			ID:            user.ID,
+			Email:         user.Email,
+			MFAEnabled:    user.MFAEnabled,
+			PhoneNumber:   user.PhoneNumber,
+			Name:          user.Name,
+			Role:          user.Role,
+			EmailVerified: user.EmailVerified,
+			PhoneVerified: user.PhoneVerified,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
 		},
 	}, nil
 }
 
 // ValidateSession checks if a session is valid
-func (s *AuthService) ValidateSession(sessionID string) (*models.User, error) {
-	// Get session from database
-	userID, expiresAt, err := s.userRepo.GetSession(sessionID)
+func (s *AuthService) ValidateSession(ctx context.Context, sessionID string) (*models.User, error) {
+	_, user, err := s.activeSession(ctx, sessionID)
 	if err != nil {
 		return nil, err
 	}
-	
+
+	// Extend session validity (in a real implementation with Redis)
+	// Here you would update the TTL in Redis
+
+	return user, nil
+}
+
+// GetSession returns an unexpired session of an enabled user
+func (s *AuthService) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	session, _, err := s.activeSession(ctx, sessionID)
+	return session, err
+}
+
+// activeSession returns the session and its user, or ErrInvalidSession if the
+// session does not exist, has expired or belongs to a disabled user
+func (s *AuthService) activeSession(ctx context.Context, sessionID string) (*models.Session, *models.User, error) {
+	// Get session from database
+	session, err := s.sessions.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	// Check if session exists and hasn't expired
-	if userID == "" || expiresAt.Before(time.Now()) {
-		return nil, ErrInvalidSession
+	if session == nil || session.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrInvalidSession
 	}
-	
+
 	// Get user by ID
-	user, err := s.userRepo.GetUserByID(userID)
+	user, err := s.users.GetUserByID(ctx, session.UserID)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	if user == nil {
-		return nil, ErrInvalidSession
+	if user == nil || user.IsDisabled() {
+		return nil, nil, ErrInvalidSession
 	}
-	
-	// Extend session validity (in a real implementation with Redis)
-	// Here you would update the TTL in Redis
-	
-	return user, nil
+
+	return session, user, nil
 }
 
-// ValidateToken validates a JWT token and returns the associated user
-func (s *AuthService) ValidateToken(tokenString string) (*models.User, error) {
+// ValidateToken validates a JWT token and returns the associated user and claims.
+// The session the token was issued for must still exist, so logging out revokes the
+// token immediately rather than when it expires.
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*models.User, *utils.JWTClaims, error) {
 	// Verify JWT token
 	claims, err := s.tokenManager.ValidateToken(tokenString)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// Machine clients have no user or session behind their tokens
+	if claims.IsClientToken() {
+		return nil, nil, ErrClientToken
 	}
-	
+
+	// Check the backing session
+	if claims.SessionID == "" {
+		return nil, nil, ErrInvalidSession
+	}
+	session, err := s.sessions.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if session == nil || session.UserID != claims.UserID || session.ExpiresAt.Before(time.Now()) {
+		return nil, nil, ErrInvalidSession
+	}
+
 	// Get user by ID
-	user, err := s.userRepo.GetUserByID(claims.UserID)
+	user, err := s.users.GetUserByID(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil || user.IsDisabled() {
+		return nil, nil, ErrInvalidSession
+	}
+
+	return user, claims, nil
+}
+
+// ValidateClientToken validates a token issued with the client credentials grant and
+// checks that it carries every required scope. Client tokens are not backed by a
+// session; with a client store they stay valid until they expire or the client is
+// deleted, and without one until they expire.
+func (s *AuthService) ValidateClientToken(ctx context.Context, tokenString string, requiredScopes ...string) (*utils.JWTClaims, error) {
+	claims, err := s.tokenManager.ValidateToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
-	if user == nil {
+
+	if !claims.IsClientToken() {
+		return nil, ErrNotClientToken
+	}
+
+	if s.clients != nil {
+		client, err := s.clients.GetOAuthClient(ctx, claims.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil {
+			return nil, ErrInvalidToken
+		}
+	}
+
+	for _, scope := range requiredScopes {
+		if !claims.HasScope(scope) {
+			return nil, ErrInsufficientScope
+		}
+	}
+
+	return claims, nil
+}
+
+// Logout ends a single session and revokes the refresh tokens issued for it
+func (s *AuthService) Logout(ctx context.Context, sessionID string) error {
+	if err := s.sessions.RevokeRefreshTokenFamily(ctx, sessionID); err != nil {
+		return err
+	}
+	return s.sessions.DeleteSession(ctx, sessionID)
+}
+
+// LogoutAll ends every session of a user, signing them out on all devices
+func (s *AuthService) LogoutAll(ctx context.Context, userID string) error {
+	if err := s.sessions.RevokeUserRefreshTokens(ctx, userID); err != nil {
+		return err
+	}
+	return s.sessions.DeleteUserSessions(ctx, userID)
+}
+
+// StartMFAEnrollment generates a new TOTP secret for the user. The secret is stored
+// but not enforced until the user confirms it with ConfirmMFAEnrollment.
+func (s *AuthService) StartMFAEnrollment(ctx context.Context, userID string) (*models.MFAEnrollmentResponse, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || user.IsDisabled() {
 		return nil, ErrInvalidSession
 	}
-	
-	return user, nil
-}
\ No newline at end of file
+	if user.MFAEnabled {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.users.UpdateMFA(ctx, user.ID, secret, false); err != nil {
+		return nil, err
+	}
+
+	return &models.MFAEnrollmentResponse{
+		Secret:     secret,
+		OTPAuthURI: utils.TOTPURI(mfaIssuer, user.Email, secret),
+	}, nil
+}
+
+// ConfirmMFAEnrollment enables MFA once the user proves their authenticator works
+func (s *AuthService) ConfirmMFAEnrollment(ctx context.Context, userID, otpCode string) error {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidSession
+	}
+	if user.MFAEnabled {
+		return ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return ErrMFANotEnrolled
+	}
+
+	valid, err := s.validateOTP(ctx, user, otpCode)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidOTP
+	}
+
+	return s.users.UpdateMFA(ctx, user.ID, user.MFASecret, true)
+}
+
+// DisableMFA turns off MFA for the user after checking a current TOTP code
+func (s *AuthService) DisableMFA(ctx context.Context, userID, otpCode string) error {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidSession
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnabled
+	}
+
+	valid, err := s.validateOTP(ctx, user, otpCode)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return ErrInvalidOTP
+	}
+
+	return s.users.UpdateMFA(ctx, user.ID, "", false)
+}
+
+// validateOTP checks a TOTP code against the user's secret and marks its time step
+// as used, so that each code is accepted at most once
+func (s *AuthService) validateOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	step, ok := utils.ValidateTOTP(user.MFASecret, code, time.Now(), user.MFALastStep)
+	if !ok {
+		return false, nil
+	}
+	// Another request may have used the same code since the user was loaded
+	return s.users.UseTOTPStep(ctx, user.ID, step)
+}
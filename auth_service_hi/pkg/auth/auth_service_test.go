@@ -0,0 +1,342 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/notify"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const testPassword = "correct horse battery"
+
+// testUserCount numbers the users created by createTestUser
+var testUserCount atomic.Int64
+
+// discardMailer and discardSMS keep test output free of notification logs
+type discardMailer struct{}
+
+func (discardMailer) SendEmail(to, subject, body string) error { return nil }
+
+type discardSMS struct{}
+
+func (discardSMS) SendSMS(to, message string) error { return nil }
+
+var (
+	_ notify.Mailer    = discardMailer{}
+	_ notify.SMSSender = discardSMS{}
+)
+
+// newTestService creates an AuthService backed by a fresh MemoryStore
+func newTestService(t *testing.T, opts ...Option) (*AuthService, *database.MemoryStore) {
+	t.Helper()
+
+	store := database.NewMemoryStore()
+	tokenManager := utils.NewTokenManager("test-secret-that-is-long-enough-123", "test", time.Minute)
+	opts = append([]Option{
+		WithMailer(discardMailer{}),
+		WithSMSSender(discardSMS{}),
+		WithClientStore(store),
+		WithRoleStore(store),
+		WithInvitationStore(store),
+	}, opts...)
+	return NewAuthService(store, store, tokenManager, opts...), store
+}
+
+// createTestUser stores a user whose password is testPassword. The hash uses the
+// minimum bcrypt cost so that tests stay fast.
+func createTestUser(t *testing.T, store *database.MemoryStore, email string, role models.UserRole, modify ...func(*models.User)) *models.User {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	user := &models.User{
+		ID:            fmt.Sprintf("%s_%d", role, testUserCount.Add(1)),
+		Email:         email,
+		PasswordHash:  string(hash),
+		Name:          "Test User",
+		Role:          role,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	for _, m := range modify {
+		m(user)
+	}
+	if err := store.CreateUser(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// login logs in with testPassword and fails the test on error
+func login(t *testing.T, s *AuthService, email string) *models.AuthResponse {
+	t.Helper()
+
+	response, err := s.Login(context.Background(), models.LoginRequest{Email: email, Password: testPassword})
+	if err != nil {
+		t.Fatalf("login as %s: %v", email, err)
+	}
+	return response
+}
+
+func TestLogin(t *testing.T) {
+	mfaSecret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	validCode, err := utils.GenerateTOTPCode(mfaSecret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, store := newTestService(t, WithEmailVerificationRequired(models.RoleHealer))
+	createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+	createTestUser(t, store, "disabled@example.com", models.RoleCustomer, func(u *models.User) {
+		now := time.Now()
+		u.DisabledAt = &now
+	})
+	createTestUser(t, store, "unverified@example.com", models.RoleHealer, func(u *models.User) {
+		u.EmailVerified = false
+	})
+	createTestUser(t, store, "mfa@example.com", models.RoleCustomer, func(u *models.User) {
+		u.MFASecret = mfaSecret
+		u.MFAEnabled = true
+	})
+
+	tests := []struct {
+		name    string
+		req     models.LoginRequest
+		wantErr error
+	}{
+		{"valid credentials", models.LoginRequest{Email: "customer@example.com", Password: testPassword}, nil},
+		{"wrong password", models.LoginRequest{Email: "customer@example.com", Password: "wrong"}, ErrInvalidCredentials},
+		{"unknown email", models.LoginRequest{Email: "nobody@example.com", Password: testPassword}, ErrInvalidCredentials},
+		{"disabled account", models.LoginRequest{Email: "disabled@example.com", Password: testPassword}, ErrAccountDisabled},
+		{"unverified email", models.LoginRequest{Email: "unverified@example.com", Password: testPassword}, ErrVerificationRequired},
+		{"missing OTP", models.LoginRequest{Email: "mfa@example.com", Password: testPassword}, ErrOTPRequired},
+		{"wrong OTP", models.LoginRequest{Email: "mfa@example.com", Password: testPassword, OTPCode: "000000"}, ErrInvalidOTP},
+		{"valid OTP", models.LoginRequest{Email: "mfa@example.com", Password: testPassword, OTPCode: validCode}, nil},
+		{"replayed OTP", models.LoginRequest{Email: "mfa@example.com", Password: testPassword, OTPCode: validCode}, ErrInvalidOTP},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := s.Login(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if response.Token == "" || response.RefreshToken == "" {
+				t.Fatal("Login() returned an empty token")
+			}
+			user, _, err := s.ValidateToken(context.Background(), response.Token)
+			if err != nil {
+				t.Fatalf("ValidateToken() error = %v", err)
+			}
+			if user.Email != tt.req.Email {
+				t.Errorf("token belongs to %s, want %s", user.Email, tt.req.Email)
+			}
+		})
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	tests := []struct {
+		name string
+		// run refreshes with tokens from the login response and returns the error of
+		// the step under test
+		run     func(s *AuthService, first *models.AuthResponse) error
+		wantErr error
+	}{
+		{
+			name: "rotates the refresh token",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				second, err := s.Refresh(context.Background(), first.RefreshToken, "")
+				if err != nil {
+					return err
+				}
+				if second.RefreshToken == first.RefreshToken {
+					return errors.New("refresh token was not rotated")
+				}
+				_, err = s.Refresh(context.Background(), second.RefreshToken, "")
+				return err
+			},
+		},
+		{
+			name: "unknown token",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				_, err := s.Refresh(context.Background(), "not-a-token", "")
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "reused token",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				if _, err := s.Refresh(context.Background(), first.RefreshToken, ""); err != nil {
+					return err
+				}
+				_, err := s.Refresh(context.Background(), first.RefreshToken, "")
+				return err
+			},
+			wantErr: ErrRefreshTokenReused,
+		},
+		{
+			name: "reuse revokes the token that replaced it",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				second, err := s.Refresh(context.Background(), first.RefreshToken, "")
+				if err != nil {
+					return err
+				}
+				if _, err := s.Refresh(context.Background(), first.RefreshToken, ""); !errors.Is(err, ErrRefreshTokenReused) {
+					return fmt.Errorf("reusing the first token: %v", err)
+				}
+				_, err = s.Refresh(context.Background(), second.RefreshToken, "")
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+		{
+			name: "reuse ends the session",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				second, err := s.Refresh(context.Background(), first.RefreshToken, "")
+				if err != nil {
+					return err
+				}
+				if _, err := s.Refresh(context.Background(), first.RefreshToken, ""); !errors.Is(err, ErrRefreshTokenReused) {
+					return fmt.Errorf("reusing the first token: %v", err)
+				}
+				_, _, err = s.ValidateToken(context.Background(), second.Token)
+				return err
+			},
+			wantErr: ErrInvalidSession,
+		},
+		{
+			name: "after logout",
+			run: func(s *AuthService, first *models.AuthResponse) error {
+				if err := s.Logout(context.Background(), first.SessionID); err != nil {
+					return err
+				}
+				_, err := s.Refresh(context.Background(), first.RefreshToken, "")
+				return err
+			},
+			wantErr: ErrInvalidRefreshToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestService(t)
+			createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+			first := login(t, s, "customer@example.com")
+
+			if err := tt.run(s, first); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLogout(t *testing.T) {
+	tests := []struct {
+		name string
+		// logout ends one or all of the user's sessions
+		logout func(s *AuthService, session *models.AuthResponse) error
+		// otherSessionValid is whether a second login survives the logout
+		otherSessionValid bool
+	}{
+		{
+			name: "logout",
+			logout: func(s *AuthService, session *models.AuthResponse) error {
+				return s.Logout(context.Background(), session.SessionID)
+			},
+			otherSessionValid: true,
+		},
+		{
+			name: "logout everywhere",
+			logout: func(s *AuthService, session *models.AuthResponse) error {
+				return s.LogoutAll(context.Background(), session.User.ID)
+			},
+			otherSessionValid: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestService(t)
+			createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+			session := login(t, s, "customer@example.com")
+			other := login(t, s, "customer@example.com")
+
+			if err := tt.logout(s, session); err != nil {
+				t.Fatalf("logout error = %v", err)
+			}
+
+			if _, _, err := s.ValidateToken(context.Background(), session.Token); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("access token after logout: error = %v, want %v", err, ErrInvalidSession)
+			}
+			if _, err := s.Refresh(context.Background(), session.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("refresh after logout: error = %v, want %v", err, ErrInvalidRefreshToken)
+			}
+
+			_, _, err := s.ValidateToken(context.Background(), other.Token)
+			if tt.otherSessionValid && err != nil {
+				t.Errorf("other session: error = %v, want it to stay valid", err)
+			}
+			if !tt.otherSessionValid && !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("other session: error = %v, want %v", err, ErrInvalidSession)
+			}
+		})
+	}
+}
+
+func TestValidateClientToken(t *testing.T) {
+	s, store := newTestService(t)
+	client := &models.OAuthClient{
+		ID:         "client_1",
+		Name:       "Order Service",
+		Scopes:     []string{"bookings:read"},
+		GrantTypes: []string{models.GrantClientCredentials},
+		CreatedAt:  time.Now(),
+	}
+	if err := store.CreateOAuthClient(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := s.tokenManager.GenerateClientToken(client.ID, "bookings:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.ValidateClientToken(context.Background(), token, "bookings:read"); err != nil {
+		t.Fatalf("ValidateClientToken() error = %v", err)
+	}
+	if _, err := s.ValidateClientToken(context.Background(), token, "bookings:write"); !errors.Is(err, ErrInsufficientScope) {
+		t.Errorf("ValidateClientToken() with a missing scope error = %v, want %v", err, ErrInsufficientScope)
+	}
+	user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+	if _, err := s.ValidateClientToken(context.Background(), login(t, s, user.Email).Token); !errors.Is(err, ErrNotClientToken) {
+		t.Errorf("ValidateClientToken() with a user token error = %v, want %v", err, ErrNotClientToken)
+	}
+
+	if _, err := store.DeleteOAuthClient(context.Background(), client.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ValidateClientToken(context.Background(), token, "bookings:read"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("ValidateClientToken() after deleting the client error = %v, want %v", err, ErrInvalidToken)
+	}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const (
+	// emailVerificationTTL is how long a verification link stays valid
+	emailVerificationTTL = 24 * time.Hour
+
+	// Resend throttling: at most one email per minute and five per hour
+	resendMinInterval = time.Minute
+	resendHourlyLimit = 5
+)
+
+// VerifyEmail consumes an email verification token and marks the address as verified
+func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+
+	userID, err := s.users.ConsumeVerificationToken(ctx, utils.HashToken(token), models.PurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	if userID == "" {
+		return ErrInvalidToken
+	}
+
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrInvalidToken
+	}
+
+	return s.users.UpdateVerificationStatus(ctx, user.ID, true, user.PhoneVerified)
+}
+
+// ResendVerificationEmail sends a new verification link. It returns nil for unknown
+// or already verified addresses, and quietly drops throttled requests, so callers
+// cannot probe which emails are registered.
+func (s *AuthService) ResendVerificationEmail(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerified {
+		return nil
+	}
+
+	if err := s.checkVerificationThrottle(ctx, user.ID, models.PurposeEmailVerification); err != nil {
+		if err == ErrTooManyRequests {
+			slog.WarnContext(ctx, "Verification email throttled", "user_id", user.ID)
+			return nil
+		}
+		return err
+	}
+
+	return s.sendEmailVerification(ctx, user)
+}
+
+// checkVerificationThrottle limits how often a token or code can be sent to a user
+func (s *AuthService) checkVerificationThrottle(ctx context.Context, userID string, purpose models.VerificationPurpose) error {
+	now := time.Now()
+
+	recent, err := s.users.CountVerificationTokensSince(ctx, userID, purpose, now.Add(-resendMinInterval))
+	if err != nil {
+		return err
+	}
+	if recent > 0 {
+		return ErrTooManyRequests
+	}
+
+	hourly, err := s.users.CountVerificationTokensSince(ctx, userID, purpose, now.Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if hourly >= resendHourlyLimit {
+		return ErrTooManyRequests
+	}
+
+	return nil
+}
+
+// issueVerificationToken stores a new single-use token for the user and returns its value
+func (s *AuthService) issueVerificationToken(ctx context.Context, userID string, purpose models.VerificationPurpose, ttl time.Duration) (string, error) {
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.users.SaveVerificationToken(ctx, &models.VerificationToken{
+		ID:        utils.GenerateUUID(models.UserRole("verify")),
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// sendEmailVerification issues a verification token and emails the link to the user
+func (s *AuthService) sendEmailVerification(ctx context.Context, user *models.User) error {
+	token, err := s.issueVerificationToken(ctx, user.ID, models.PurposeEmailVerification, emailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	link := s.publicURL + "/api/auth/verify-email?token=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address by opening the link below:\n\n%s\n\nThe link expires in %d hours.\n",
+		user.Name, link, int(emailVerificationTTL.Hours()))
+
+	return s.mailer.SendEmail(user.Email, "Verify your email address", body)
+}
@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+// recordingMailer keeps the emails sent through it
+type recordingMailer struct {
+	mu     sync.Mutex
+	bodies []string
+}
+
+func (m *recordingMailer) SendEmail(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bodies = append(m.bodies, body)
+	return nil
+}
+
+// sent returns the number of emails sent so far
+func (m *recordingMailer) sent() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.bodies)
+}
+
+var verificationLink = regexp.MustCompile(`/api/auth/verify-email\?token=(\S+)`)
+
+// lastToken returns the token from the verification link in the last email
+func (m *recordingMailer) lastToken(t *testing.T) string {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.bodies) == 0 {
+		t.Fatal("no email was sent")
+	}
+	match := verificationLink.FindStringSubmatch(m.bodies[len(m.bodies)-1])
+	if match == nil {
+		t.Fatalf("email has no verification link: %s", m.bodies[len(m.bodies)-1])
+	}
+	token, err := url.QueryUnescape(match[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func unverified(u *models.User) { u.EmailVerified = false }
+
+func TestVerifyEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	s, store := newTestService(t, WithMailer(mailer))
+	user := createTestUser(t, store, "healer@example.com", models.RoleHealer, unverified)
+
+	if err := s.ResendVerificationEmail(context.Background(), user.Email); err != nil {
+		t.Fatal(err)
+	}
+	token := mailer.lastToken(t)
+
+	// Tokens for another purpose or past their expiry are stored directly
+	saveToken := func(purpose models.VerificationPurpose, expiresAt time.Time) string {
+		value, err := utils.GenerateSecureToken()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.SaveVerificationToken(context.Background(), &models.VerificationToken{
+			ID:        utils.GenerateUUID(models.UserRole("verify")),
+			UserID:    user.ID,
+			Purpose:   purpose,
+			TokenHash: utils.HashToken(value),
+			ExpiresAt: expiresAt,
+			CreatedAt: time.Now(),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return value
+	}
+	expired := saveToken(models.PurposeEmailVerification, time.Now().Add(-time.Minute))
+	resetToken := saveToken(models.PurposePasswordReset, time.Now().Add(time.Hour))
+
+	// The cases run in order; the valid token verifies the address and is used up
+	tests := []struct {
+		name         string
+		token        string
+		wantErr      error
+		wantVerified bool
+	}{
+		{"empty token", "", ErrInvalidToken, false},
+		{"unknown token", "not-a-token", ErrInvalidToken, false},
+		{"expired token", expired, ErrInvalidToken, false},
+		{"password reset token", resetToken, ErrInvalidToken, false},
+		{"valid token", token, nil, true},
+		{"used token", token, ErrInvalidToken, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := s.VerifyEmail(context.Background(), tt.token); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("VerifyEmail() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := store.GetUserByID(context.Background(), user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stored.EmailVerified != tt.wantVerified {
+				t.Errorf("EmailVerified = %v, want %v", stored.EmailVerified, tt.wantVerified)
+			}
+		})
+	}
+}
+
+func TestResendVerificationEmail(t *testing.T) {
+	mailer := &recordingMailer{}
+	s, store := newTestService(t, WithMailer(mailer))
+	createTestUser(t, store, "verified@example.com", models.RoleCustomer)
+	createTestUser(t, store, "unverified@example.com", models.RoleCustomer, unverified)
+
+	tests := []struct {
+		name     string
+		email    string
+		wantSent int
+	}{
+		{"unknown address", "nobody@example.com", 0},
+		{"verified address", "verified@example.com", 0},
+		{"unverified address", "unverified@example.com", 1},
+		{"within a minute of the last email", "unverified@example.com", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Throttled requests succeed like any other so that they reveal nothing
+			if err := s.ResendVerificationEmail(context.Background(), tt.email); err != nil {
+				t.Fatalf("ResendVerificationEmail() error = %v", err)
+			}
+			if got := mailer.sent(); got != tt.wantSent {
+				t.Errorf("%d emails sent, want %d", got, tt.wantSent)
+			}
+		})
+	}
+}
+
+func TestResendVerificationHandler(t *testing.T) {
+	s, store := newTestService(t)
+	createTestUser(t, store, "unverified@example.com", models.RoleCustomer, unverified)
+	h := NewHTTPHandler(s)
+
+	// The second request for the registered address is throttled
+	for _, email := range []string{"nobody@example.com", "unverified@example.com", "unverified@example.com"} {
+		r := httptest.NewRequest(http.MethodPost, "/api/auth/verify-email/resend", strings.NewReader(`{"email":"`+email+`"}`))
+		w := httptest.NewRecorder()
+		h.ResendVerificationHandler(w, r)
+		if w.Code != http.StatusAccepted {
+			t.Errorf("resend for %s: status = %d, want %d", email, w.Code, http.StatusAccepted)
+		}
+	}
+}
+
+func TestEmailVerificationRequired(t *testing.T) {
+	mailer := &recordingMailer{}
+	s, store := newTestService(t, WithMailer(mailer), WithEmailVerificationRequired(models.RoleHealer))
+	createTestUser(t, store, "customer@example.com", models.RoleCustomer, unverified)
+	createTestUser(t, store, "healer@example.com", models.RoleHealer, unverified)
+
+	// Only the configured roles must verify before logging in
+	login(t, s, "customer@example.com")
+	_, err := s.Login(context.Background(), models.LoginRequest{Email: "healer@example.com", Password: testPassword})
+	if !errors.Is(err, ErrVerificationRequired) {
+		t.Fatalf("Login() as an unverified healer: error = %v, want %v", err, ErrVerificationRequired)
+	}
+
+	if err := s.ResendVerificationEmail(context.Background(), "healer@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.VerifyEmail(context.Background(), mailer.lastToken(t)); err != nil {
+		t.Fatal(err)
+	}
+	login(t, s, "healer@example.com")
+}
@@ -3,19 +3,50 @@ package auth
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/herb-immortal/auth_service_hi/pkg/authz"
+	"github.com/herb-immortal/auth_service_hi/pkg/keys"
+	"github.com/herb-immortal/auth_service_hi/pkg/logging"
 	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
 )
 
 // HTTPHandler handles HTTP requests for authentication
 type HTTPHandler struct {
 	authService *AuthService
+	keyRotator  KeyRotator
+}
+
+// KeyRotator publishes a new signing key that replaces the active one at the
+// returned time. It fails with keys.ErrRotationPending while an earlier rotation
+// has not taken effect yet.
+type KeyRotator interface {
+	Rotate() (*utils.SigningKey, time.Time, error)
+}
+
+// HandlerOption configures optional HTTPHandler features
+type HandlerOption func(*HTTPHandler)
+
+// WithKeyRotator enables the admin endpoint that rotates signing keys
+func WithKeyRotator(rotator KeyRotator) HandlerOption {
+	return func(h *HTTPHandler) {
+		h.keyRotator = rotator
+	}
 }
 
 // NewHTTPHandler creates a new HTTP handler for authentication
-func NewHTTPHandler(authService *AuthService) *HTTPHandler {
-	return &HTTPHandler{authService: authService}
+func NewHTTPHandler(authService *AuthService, opts ...HandlerOption) *HTTPHandler {
+	h := &HTTPHandler{authService: authService}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // EnableCORS adds CORS headers to responses
@@ -24,14 +55,15 @@ func EnableCORS(next http.HandlerFunc) http.HandlerFunc {
 		// Set CORS headers
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID")
+
 		// Handle preflight requests
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
 			return
 		}
-		
+
 		// Call the next handler
 		next(w, r)
 	}
@@ -50,6 +82,16 @@ func RespondWithError(w http.ResponseWriter, code int, message string) {
 	RespondWithJSON(w, code, map[string]string{"error": message})
 }
 
+// RespondWithInternalError logs err with the request ID and sends a 500 response
+// with message and the request ID, which the client can quote to support
+func RespondWithInternalError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	slog.ErrorContext(r.Context(), message, "method", r.Method, "path", r.URL.Path, "error", err)
+	RespondWithJSON(w, http.StatusInternalServerError, map[string]string{
+		"error":      message,
+		"request_id": logging.RequestID(r.Context()),
+	})
+}
+
 // SignupHandler handles user registration
 func (h *HTTPHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -65,19 +107,29 @@ func (h *HTTPHandler) SignupHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	user, err := h.authService.Signup(req)
+	user, err := h.authService.Signup(r.Context(), req)
 	if err != nil {
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventSignup, Email: req.Email}, err)
 		switch err {
 		case ErrUserAlreadyExists:
 			RespondWithError(w, http.StatusConflict, err.Error())
-		case ErrInvalidRole:
+		case ErrInvalidRole, ErrInvalidInvitation:
 			RespondWithError(w, http.StatusBadRequest, err.Error())
+		case ErrInvitationRequired:
+			RespondWithError(w, http.StatusForbidden, err.Error())
 		default:
-			RespondWithError(w, http.StatusInternalServerError, "Error creating user")
+			RespondWithInternalError(w, r, "Error creating user", err)
 		}
 		return
 	}
 
+	h.authService.Audit(r, models.AuthEvent{
+		Type:         models.EventSignup,
+		TargetUserID: user.ID,
+		Email:        user.Email,
+		Detail:       "role " + string(user.Role),
+	}, nil)
+
 	RespondWithJSON(w, http.StatusCreated, user)
 }
 
@@ -96,22 +148,144 @@ func (h *HTTPHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	defer r.Body.Close()
 
-	authResponse, err := h.authService.Login(req)
+	authResponse, err := h.authService.Login(r.Context(), req)
 	if err != nil {
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventLogin, Email: req.Email}, err)
+
+		var locked *AccountLockedError
+		if errors.As(err, &locked) {
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfter()))
+			RespondWithError(w, http.StatusLocked, "Too many failed login attempts; try again later")
+			return
+		}
+
 		switch err {
 		case ErrInvalidCredentials:
 			RespondWithError(w, http.StatusUnauthorized, "Invalid email or password")
 		case ErrInvalidOTP:
 			RespondWithError(w, http.StatusUnauthorized, "Invalid OTP code")
+		case ErrOTPRequired:
+			RespondWithError(w, http.StatusUnauthorized, "OTP code required")
+		case ErrVerificationRequired:
+			RespondWithError(w, http.StatusForbidden, "Please verify your email address before logging in")
+		case ErrAccountDisabled:
+			RespondWithError(w, http.StatusForbidden, "This account has been disabled")
+		default:
+			RespondWithInternalError(w, r, "Error during login", err)
+		}
+		return
+	}
+
+	h.authService.Audit(r, models.AuthEvent{
+		Type:         models.EventLogin,
+		ActorID:      authResponse.User.ID,
+		TargetUserID: authResponse.User.ID,
+		Email:        authResponse.User.Email,
+	}, nil)
+
+	SetAuthCookies(w, authResponse)
+
+	RespondWithJSON(w, http.StatusOK, authResponse)
+}
+
+// RefreshHandler exchanges a refresh token for a new token pair
+func (h *HTTPHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.RefreshRequest
+	if r.ContentLength != 0 {
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+	}
+
+	// Browser clients send the refresh token as a cookie instead
+	if req.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshCookieName); err == nil {
+			req.RefreshToken = cookie.Value
+		}
+	}
+	if req.RefreshToken == "" {
+		RespondWithError(w, http.StatusBadRequest, "Refresh token required")
+		return
+	}
+
+	authResponse, err := h.authService.Refresh(r.Context(), req.RefreshToken, "")
+	if err != nil {
+		switch err {
+		case ErrInvalidRefreshToken:
+			RespondWithError(w, http.StatusUnauthorized, "Invalid or expired refresh token")
+		case ErrRefreshTokenReused:
+			RespondWithError(w, http.StatusUnauthorized, "Refresh token reuse detected, please log in again")
 		default:
-			RespondWithError(w, http.StatusInternalServerError, "Error during login")
+			RespondWithInternalError(w, r, "Error refreshing token", err)
 		}
 		return
 	}
 
-	// Set session cookie for browser clients
+	SetAuthCookies(w, authResponse)
+
+	RespondWithJSON(w, http.StatusOK, authResponse)
+}
+
+// LogoutHandler ends the session the request was authenticated with
+func (h *HTTPHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	claims := GetClaimsFromContext(r.Context())
+
+	err := h.authService.Logout(r.Context(), claims.SessionID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventLogout, TargetUserID: claims.UserID}, err)
+	if err != nil {
+		RespondWithInternalError(w, r, "Error during logout", err)
+		return
+	}
+
+	clearAuthCookies(w)
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out"})
+}
+
+// LogoutAllHandler ends every session of the authenticated user
+func (h *HTTPHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+
+	err := h.authService.LogoutAll(r.Context(), user.ID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventLogoutAll, TargetUserID: user.ID}, err)
+	if err != nil {
+		RespondWithInternalError(w, r, "Error during logout", err)
+		return
+	}
+
+	clearAuthCookies(w)
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Logged out of all sessions"})
+}
+
+const (
+	sessionCookieName = "session_token"
+	refreshCookieName = "refresh_token"
+)
+
+// SetAuthCookies sets the access and refresh token cookies for browser clients. The
+// hosted OpenID Connect login page uses them as its single sign-on session.
+func SetAuthCookies(w http.ResponseWriter, authResponse *models.AuthResponse) {
 	http.SetCookie(w, &http.Cookie{
-		Name:     "session_token",
+		Name:     sessionCookieName,
 		Value:    authResponse.Token,
 		Expires:  authResponse.ExpiresAt,
 		HttpOnly: true,
@@ -120,47 +294,504 @@ func (h *HTTPHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		Secure:   true, // Set to true in production with HTTPS
 	})
 
-	RespondWithJSON(w, http.StatusOK, authResponse)
+	// The refresh cookie is only ever sent to the refresh endpoint
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    authResponse.RefreshToken,
+		Expires:  authResponse.RefreshExpiresAt,
+		HttpOnly: true,
+		Path:     "/api/auth/refresh",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   true,
+	})
+}
+
+// SessionCookieToken returns the access token from the session cookie, if any
+func SessionCookieToken(r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return cookie.Value
+}
+
+// clearAuthCookies removes the cookies set by SetAuthCookies
+func clearAuthCookies(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   true,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Path:     "/api/auth/refresh",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   true,
+	})
+}
+
+// VerifyEmailHandler consumes an email verification token. GET supports the link
+// sent by email; POST accepts the token in a JSON body.
+func (h *HTTPHandler) VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	var req models.VerifyEmailRequest
+
+	switch r.Method {
+	case http.MethodGet:
+		req.Token = r.URL.Query().Get("token")
+	case http.MethodPost:
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+			return
+		}
+		defer r.Body.Close()
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := h.authService.VerifyEmail(r.Context(), req.Token); err != nil {
+		switch err {
+		case ErrInvalidToken:
+			RespondWithError(w, http.StatusBadRequest, "Invalid or expired verification link")
+		default:
+			RespondWithInternalError(w, r, "Error verifying email", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Email address verified"})
+}
+
+// ResendVerificationHandler sends a new verification email
+func (h *HTTPHandler) ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ResendVerificationRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	if err := h.authService.ResendVerificationEmail(r.Context(), req.Email); err != nil {
+		RespondWithInternalError(w, r, "Error sending verification email", err)
+		return
+	}
+
+	// Same response whether or not the address is registered
+	RespondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If the address belongs to an unverified account, a verification email has been sent",
+	})
+}
+
+// ForgotPasswordHandler sends a password reset email
+func (h *HTTPHandler) ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ForgotPasswordRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	err := h.authService.ForgotPassword(r.Context(), req.Email)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventPasswordResetRequested, Email: req.Email}, err)
+	if err != nil {
+		RespondWithInternalError(w, r, "Error requesting password reset", err)
+		return
+	}
+
+	// Same response whether or not the address is registered
+	RespondWithJSON(w, http.StatusAccepted, map[string]string{
+		"message": "If the address is registered, a password reset email has been sent",
+	})
+}
+
+// ResetPasswordHandler sets a new password using a reset token
+func (h *HTTPHandler) ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ResetPasswordRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	userID, err := h.authService.ResetPassword(r.Context(), req.Token, req.Password)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventPasswordReset, TargetUserID: userID}, err)
+	if err != nil {
+		switch err {
+		case ErrInvalidToken:
+			RespondWithError(w, http.StatusBadRequest, "Invalid or expired reset token")
+		case ErrPasswordTooShort:
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error resetting password", err)
+		}
+		return
+	}
+
+	clearAuthCookies(w)
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password has been reset, please log in"})
+}
+
+// SendPhoneCodeHandler texts a verification code to the authenticated user's phone
+func (h *HTTPHandler) SendPhoneCodeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+
+	if err := h.authService.SendPhoneVerificationCode(r.Context(), user.ID); err != nil {
+		switch err {
+		case ErrAlreadyVerified:
+			RespondWithError(w, http.StatusConflict, "Phone number already verified")
+		case ErrTooManyRequests:
+			RespondWithError(w, http.StatusTooManyRequests, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error sending verification code", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusAccepted, map[string]string{"message": "Verification code sent"})
+}
+
+// VerifyPhoneHandler confirms the authenticated user's phone number with a code
+func (h *HTTPHandler) VerifyPhoneHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.VerifyPhoneRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	user := GetUserFromContext(r.Context())
+
+	if err := h.authService.VerifyPhone(r.Context(), user.ID, req.Code); err != nil {
+		switch err {
+		case ErrInvalidCode:
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+		case ErrTooManyAttempts:
+			RespondWithError(w, http.StatusTooManyRequests, err.Error())
+		case ErrAlreadyVerified:
+			RespondWithError(w, http.StatusConflict, "Phone number already verified")
+		default:
+			RespondWithInternalError(w, r, "Error verifying phone number", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Phone number verified"})
+}
+
+// ContactInfoHandler returns the contact details of a healer or vendor
+func (h *HTTPHandler) ContactInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID := r.URL.Query().Get("id")
+	if userID == "" {
+		RespondWithError(w, http.StatusBadRequest, "User ID required")
+		return
+	}
+
+	contact, err := h.authService.GetContactInfo(r.Context(), userID)
+	if err != nil {
+		switch err {
+		case ErrUserNotFound:
+			RespondWithError(w, http.StatusNotFound, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error retrieving contact details", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, contact)
+}
+
+// MFAEnrollHandler starts TOTP enrollment for the authenticated user
+func (h *HTTPHandler) MFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+
+	enrollment, err := h.authService.StartMFAEnrollment(r.Context(), user.ID)
+	if err != nil {
+		switch err {
+		case ErrMFAAlreadyEnabled:
+			RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error starting MFA enrollment", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, enrollment)
+}
+
+// MFAConfirmHandler enables MFA after the user submits their first TOTP code
+func (h *HTTPHandler) MFAConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.MFACodeRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	user := GetUserFromContext(r.Context())
+
+	err := h.authService.ConfirmMFAEnrollment(r.Context(), user.ID, req.OTPCode)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventMFAEnabled, TargetUserID: user.ID}, err)
+	if err != nil {
+		switch err {
+		case ErrInvalidOTP:
+			RespondWithError(w, http.StatusUnauthorized, "Invalid OTP code")
+		case ErrMFAAlreadyEnabled, ErrMFANotEnrolled:
+			RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error confirming MFA enrollment", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]bool{"mfa_enabled": true})
+}
+
+// MFADisableHandler turns off MFA for the authenticated user
+func (h *HTTPHandler) MFADisableHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.MFACodeRequest
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request payload")
+		return
+	}
+	defer r.Body.Close()
+
+	user := GetUserFromContext(r.Context())
+
+	err := h.authService.DisableMFA(r.Context(), user.ID, req.OTPCode)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventMFADisabled, TargetUserID: user.ID}, err)
+	if err != nil {
+		switch err {
+		case ErrInvalidOTP:
+			RespondWithError(w, http.StatusUnauthorized, "Invalid OTP code")
+		case ErrMFANotEnabled:
+			RespondWithError(w, http.StatusConflict, err.Error())
+		default:
+			RespondWithInternalError(w, r, "Error disabling MFA", err)
+		}
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]bool{"mfa_enabled": false})
+}
+
+// JWKSHandler publishes the public keys that verify our tokens
+func (h *HTTPHandler) JWKSHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	// Let verifiers cache the key set, but not for so long that they miss a new key
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	RespondWithJSON(w, http.StatusOK, h.authService.tokenManager.JWKS())
+}
+
+// RotateKeysHandler handles signing key rotation requests. The route requires the
+// keys:rotate permission.
+func (h *HTTPHandler) RotateKeysHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+
+	key, activatesAt, err := h.keyRotator.Rotate()
+	if err != nil {
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventKeysRotated}, err)
+		if errors.Is(err, keys.ErrRotationPending) {
+			RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		RespondWithInternalError(w, r, "Failed to rotate signing key", err)
+		return
+	}
+
+	slog.InfoContext(r.Context(), "Signing key rotation started", "kid", key.ID, "activates_at", activatesAt, "user_id", user.ID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventKeysRotated, Detail: "pending key " + key.ID}, nil)
+	RespondWithJSON(w, http.StatusOK, map[string]string{
+		"kid":          key.ID,
+		"alg":          key.Method.Alg(),
+		"status":       string(models.SigningKeyPending),
+		"activates_at": activatesAt.UTC().Format(time.RFC3339),
+	})
 }
 
 // Type to store user in context
 type userContextKey string
 
-const userKey userContextKey = "user"
+const (
+	userKey   userContextKey = "user"
+	claimsKey userContextKey = "claims"
+)
 
 // AuthMiddleware validates JWT tokens for protected routes
 func (h *HTTPHandler) AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Get token from Authorization header
-		tokenString := r.Header.Get("Authorization")
+		tokenString := bearerToken(r)
 		if tokenString == "" {
 			// Try from cookie as fallback
-			cookie, err := r.Cookie("session_token")
+			cookie, err := r.Cookie(sessionCookieName)
 			if err != nil {
 				RespondWithError(w, http.StatusUnauthorized, "Authorization token required")
 				return
 			}
 			tokenString = cookie.Value
-		} else {
-			// Remove "Bearer " prefix if present
-			if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-				tokenString = tokenString[7:]
-			}
 		}
 
 		// Validate token
-		user, err := h.authService.ValidateToken(tokenString)
+		user, claims, err := h.authService.ValidateToken(r.Context(), tokenString)
+		if err == ErrClientToken {
+			RespondWithError(w, http.StatusForbidden, "This endpoint requires a user token")
+			return
+		}
 		if err != nil {
 			RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
 			return
 		}
 
-		// Store user in request context
+		// Store user and token claims in request context
 		ctx := context.WithValue(r.Context(), userKey, user)
+		ctx = context.WithValue(ctx, claimsKey, claims)
+		ctx = authz.WithPrincipal(ctx, authz.PrincipalFromClaims(claims))
 		next(w, r.WithContext(ctx))
 	}
 }
 
+// RequireRole authenticates the request like AuthMiddleware and then only lets users
+// with one of the given roles through, e.g.
+//
+//	mux.HandleFunc("/api/admin/...", EnableCORS(h.RequireRole(models.RoleAdmin)(handler)))
+func (h *HTTPHandler) RequireRole(roles ...models.UserRole) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return h.AuthMiddleware(authz.RequireRole(roles...)(next))
+	}
+}
+
+// RequirePermission authenticates the request like AuthMiddleware and then only lets
+// users whose role grants every one of the given permissions through. Permissions
+// are looked up on each request, so changes apply without waiting for tokens to expire.
+func (h *HTTPHandler) RequirePermission(permissions ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return h.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			user := GetUserFromContext(r.Context())
+			for _, permission := range permissions {
+				if !h.authService.HasPermission(r.Context(), user, permission) {
+					authz.ForbiddenPermissions(w, permissions)
+					return
+				}
+			}
+			next(w, r)
+		})
+	}
+}
+
+// ClientAuthMiddleware validates client tokens for service-to-service routes. The
+// token must carry every one of the given scopes. User tokens are rejected; the
+// client's claims are available through GetClaimsFromContext.
+func (h *HTTPHandler) ClientAuthMiddleware(scopes ...string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			tokenString := bearerToken(r)
+			if tokenString == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				RespondWithError(w, http.StatusUnauthorized, "Authorization token required")
+				return
+			}
+
+			claims, err := h.authService.ValidateClientToken(r.Context(), tokenString, scopes...)
+			switch err {
+			case nil:
+			case ErrNotClientToken:
+				RespondWithError(w, http.StatusForbidden, "This endpoint requires a client token")
+				return
+			case ErrInsufficientScope:
+				w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="`+strings.Join(scopes, " ")+`"`)
+				RespondWithError(w, http.StatusForbidden, "Token does not have the required scope")
+				return
+			default:
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			ctx = authz.WithPrincipal(ctx, authz.PrincipalFromClaims(claims))
+			next(w, r.WithContext(ctx))
+		}
+	}
+}
+
+// bearerToken returns the token from an "Authorization: Bearer" header, if any
+func bearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && header[:7] == "Bearer " {
+		return header[7:]
+	}
+	return header
+}
+
 // WithUser adds the user to the request context
 func WithUser(ctx context.Context, user *models.User) context.Context {
 	return context.WithValue(ctx, userKey, user)
@@ -174,13 +805,53 @@ func GetUserFromContext(ctx context.Context) *models.User {
 	return nil
 }
 
+// GetClaimsFromContext retrieves the validated token claims from the request context
+func GetClaimsFromContext(ctx context.Context) *utils.JWTClaims {
+	if claims, ok := ctx.Value(claimsKey).(*utils.JWTClaims); ok {
+		return claims
+	}
+	return nil
+}
+
 // SetupRoutes registers the authentication routes
 func (h *HTTPHandler) SetupRoutes(mux *http.ServeMux) {
 	// Apply CORS middleware to all routes
 	mux.HandleFunc("/api/auth/signup", EnableCORS(h.SignupHandler))
+	mux.HandleFunc("/.well-known/jwks.json", EnableCORS(h.JWKSHandler))
 	mux.HandleFunc("/api/auth/login", EnableCORS(h.LoginHandler))
+	mux.HandleFunc("/api/auth/refresh", EnableCORS(h.RefreshHandler))
+	mux.HandleFunc("/api/auth/verify-email", EnableCORS(h.VerifyEmailHandler))
+	mux.HandleFunc("/api/auth/verify-email/resend", EnableCORS(h.ResendVerificationHandler))
+	mux.HandleFunc("/api/auth/password/forgot", EnableCORS(h.ForgotPasswordHandler))
+	mux.HandleFunc("/api/auth/password/reset", EnableCORS(h.ResetPasswordHandler))
+	mux.HandleFunc("/api/auth/logout", EnableCORS(h.AuthMiddleware(h.LogoutHandler)))
+	mux.HandleFunc("/api/auth/logout/all", EnableCORS(h.AuthMiddleware(h.LogoutAllHandler)))
 	mux.HandleFunc("/api/auth/profile", EnableCORS(h.AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		user := GetUserFromContext(r.Context())
 		RespondWithJSON(w, http.StatusOK, user)
 	})))
-}
\ No newline at end of file
+	mux.HandleFunc("/api/auth/phone/send-code", EnableCORS(h.AuthMiddleware(h.SendPhoneCodeHandler)))
+	mux.HandleFunc("/api/auth/phone/verify", EnableCORS(h.AuthMiddleware(h.VerifyPhoneHandler)))
+	mux.HandleFunc("/api/auth/users/contact", EnableCORS(h.AuthMiddleware(h.ContactInfoHandler)))
+	mux.HandleFunc("/api/auth/mfa/enroll", EnableCORS(h.AuthMiddleware(h.MFAEnrollHandler)))
+	mux.HandleFunc("/api/auth/mfa/confirm", EnableCORS(h.AuthMiddleware(h.MFAConfirmHandler)))
+	mux.HandleFunc("/api/auth/mfa/disable", EnableCORS(h.AuthMiddleware(h.MFADisableHandler)))
+	mux.HandleFunc("/api/auth/permissions", EnableCORS(h.AuthMiddleware(h.MyPermissionsHandler)))
+	mux.HandleFunc("/api/admin/roles", EnableCORS(h.RequirePermission("roles:manage")(h.RolesHandler)))
+	mux.HandleFunc("/api/admin/permissions", EnableCORS(h.RequirePermission("roles:manage")(h.PermissionsHandler)))
+	mux.HandleFunc("/api/admin/invitations", EnableCORS(h.RequirePermission("users:manage")(h.InvitationsHandler)))
+	mux.HandleFunc("/api/admin/users", EnableCORS(h.RequirePermission("users:read")(h.ListUsersHandler)))
+	mux.HandleFunc("/api/admin/users/view", EnableCORS(h.RequirePermission("users:read")(h.GetUserHandler)))
+	mux.HandleFunc("/api/admin/users/role", EnableCORS(h.RequirePermission("users:manage")(h.ChangeUserRoleHandler)))
+	mux.HandleFunc("/api/admin/users/disable", EnableCORS(h.RequirePermission("users:manage")(h.DisableUserHandler)))
+	mux.HandleFunc("/api/admin/users/enable", EnableCORS(h.RequirePermission("users:manage")(h.EnableUserHandler)))
+	mux.HandleFunc("/api/admin/users/force-password-reset", EnableCORS(h.RequirePermission("users:manage")(h.ForcePasswordResetHandler)))
+	mux.HandleFunc("/api/admin/users/revoke-sessions", EnableCORS(h.RequirePermission("users:manage")(h.RevokeUserSessionsHandler)))
+	mux.HandleFunc("/api/admin/users/unlock", EnableCORS(h.RequirePermission("users:manage")(h.UnlockUserHandler)))
+	mux.HandleFunc("/api/admin/users/lockouts", EnableCORS(h.RequirePermission("users:read")(h.ListLockoutsHandler)))
+	mux.HandleFunc("/api/admin/audit", EnableCORS(h.RequirePermission("audit:read")(h.AuthEventsHandler)))
+
+	if h.keyRotator != nil {
+		mux.HandleFunc("/api/admin/keys/rotate", EnableCORS(h.RequirePermission("keys:rotate")(h.RotateKeysHandler)))
+	}
+}
@@ -0,0 +1,224 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+var (
+	ErrInvitationRequired        = errors.New("an invitation is required to sign up with this role")
+	ErrInvalidInvitation         = errors.New("invalid or expired invitation")
+	ErrInvitationNotFound        = errors.New("invitation not found or already used")
+	ErrInvitationsNotConfigured  = errors.New("invitations are not configured")
+	ErrInvalidEmail              = errors.New("invalid email address")
+	ErrInvalidInvitationLifetime = errors.New("expires_in_hours must be between 1 and 720")
+)
+
+const (
+	// defaultInvitationTTL is how long an invitation stays valid unless the admin says otherwise
+	defaultInvitationTTL = 72 * time.Hour
+
+	// maxInvitationTTL bounds how long an unused invitation can stay valid
+	maxInvitationTTL = 30 * 24 * time.Hour
+)
+
+// selfSignupRoles are the roles anyone may choose at signup, unless configured to
+// require an invitation. Admin and custom roles always need one.
+var selfSignupRoles = map[models.UserRole]bool{
+	models.RoleCustomer: true,
+	models.RoleHealer:   true,
+	models.RoleVendor:   true,
+}
+
+// WithInvitationStore enables invitations, which are the only way to sign up with a
+// privileged role. Without a store nobody can sign up as an admin.
+func WithInvitationStore(invitations database.InvitationStore) Option {
+	return func(s *AuthService) {
+		s.invitations = invitations
+	}
+}
+
+// WithInvitationRequired makes Signup require an invitation for the given roles in
+// addition to admin
+func WithInvitationRequired(roles ...models.UserRole) Option {
+	return func(s *AuthService) {
+		for _, role := range roles {
+			s.invitationRequired[role] = true
+		}
+	}
+}
+
+// CreateInvitation invites someone to sign up with a role and emails them a
+// single-use link. invitedBy is the inviting admin, who must be allowed to grant the
+// role (see checkRoleGrant), or nil for the operator running the CLI. The response
+// carries the only copy of the link.
+func (s *AuthService) CreateInvitation(ctx context.Context, req models.CreateInvitationRequest, invitedBy *models.User) (*models.InvitationResponse, error) {
+	if s.invitations == nil {
+		return nil, ErrInvitationsNotConfigured
+	}
+
+	address, err := mail.ParseAddress(req.Email)
+	if err != nil || address.Address != req.Email {
+		return nil, ErrInvalidEmail
+	}
+
+	ttl := defaultInvitationTTL
+	if req.ExpiresInHours != 0 {
+		ttl = time.Duration(req.ExpiresInHours) * time.Hour
+		if ttl < time.Hour || ttl > maxInvitationTTL {
+			return nil, ErrInvalidInvitationLifetime
+		}
+	}
+
+	// Invitations may be for any defined role, including custom ones
+	exists, err := s.roleExists(ctx, req.Role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrInvalidRole
+	}
+	if err := s.checkRoleGrant(ctx, invitedBy, req.Role); err != nil {
+		return nil, err
+	}
+
+	existingUser, err := s.users.GetUserByEmail(ctx, req.Email)
+	if err != nil {
+		return nil, err
+	}
+	if existingUser != nil {
+		return nil, ErrUserAlreadyExists
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	var inviterID string
+	if invitedBy != nil {
+		inviterID = invitedBy.ID
+	}
+
+	now := time.Now()
+	invitation := models.Invitation{
+		ID:        utils.GenerateUUID(models.UserRole("invite")),
+		Email:     req.Email,
+		Role:      req.Role,
+		TokenHash: utils.HashToken(token),
+		InvitedBy: inviterID,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if err := s.invitations.CreateInvitation(ctx, &invitation); err != nil {
+		return nil, err
+	}
+
+	link := s.publicURL + "/?invitation=" + url.QueryEscape(token)
+	body := fmt.Sprintf("Hello,\n\nYou have been invited to join Herb Immortal as %s. Open the link below to create your account:\n\n%s\n\nThe invitation can be used once and expires on %s.\n",
+		invitation.Role, link, invitation.ExpiresAt.UTC().Format("January 2, 2006 15:04 MST"))
+
+	// The admin receives the link too, so a delivery failure does not fail the request
+	if err := s.mailer.SendEmail(invitation.Email, "You're invited to Herb Immortal", body); err != nil {
+		slog.ErrorContext(ctx, "Failed to send invitation", "invitation_id", invitation.ID, "error", err)
+	}
+
+	return &models.InvitationResponse{Invitation: invitation, Link: link}, nil
+}
+
+// ListInvitations returns every invitation, newest first
+func (s *AuthService) ListInvitations(ctx context.Context) ([]models.Invitation, error) {
+	if s.invitations == nil {
+		return nil, ErrInvitationsNotConfigured
+	}
+
+	invitations, err := s.invitations.ListInvitations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if invitations == nil {
+		invitations = []models.Invitation{}
+	}
+	return invitations, nil
+}
+
+// RevokeInvitation withdraws an invitation that has not been used yet
+func (s *AuthService) RevokeInvitation(ctx context.Context, id string) error {
+	if s.invitations == nil {
+		return ErrInvitationsNotConfigured
+	}
+
+	revoked, err := s.invitations.RevokeInvitation(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !revoked {
+		return ErrInvitationNotFound
+	}
+	return nil
+}
+
+// checkSignupRole decides whether a role may be chosen at signup without an invitation
+func (s *AuthService) checkSignupRole(ctx context.Context, role models.UserRole) error {
+	if role == models.RoleAdmin || s.invitationRequired[role] {
+		return ErrInvitationRequired
+	}
+	if !selfSignupRoles[role] {
+		return ErrInvalidRole
+	}
+	return nil
+}
+
+// lookupInvitation finds a usable invitation matching the signup request
+func (s *AuthService) lookupInvitation(ctx context.Context, req models.SignupRequest) (*models.Invitation, error) {
+	if s.invitations == nil {
+		return nil, ErrInvalidInvitation
+	}
+
+	invitation, err := s.invitations.GetInvitationByHash(ctx, utils.HashToken(req.InvitationToken))
+	if err != nil {
+		return nil, err
+	}
+	if invitation == nil || !invitation.IsUsable(time.Now()) {
+		return nil, ErrInvalidInvitation
+	}
+
+	// The invitation is bound to the address it was sent to and to its role
+	if !strings.EqualFold(invitation.Email, req.Email) {
+		return nil, ErrInvalidInvitation
+	}
+	if req.Role != "" && req.Role != invitation.Role {
+		return nil, ErrInvalidInvitation
+	}
+
+	return invitation, nil
+}
+
+// roleExists reports whether a role is defined in the role store, or is one of the
+// default roles when no store is configured
+func (s *AuthService) roleExists(ctx context.Context, role models.UserRole) (bool, error) {
+	if s.roles == nil {
+		for _, defaultRole := range models.DefaultRoles {
+			if defaultRole.Name == role {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	stored, err := s.roles.GetRole(ctx, role)
+	if err != nil {
+		return false, err
+	}
+	return stored != nil, nil
+}
@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// InvitationsHandler manages invitations: GET lists them, POST creates one and
+// returns its link, and DELETE ?id=<invitation> revokes an unused one
+func (h *HTTPHandler) InvitationsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		invitations, err := h.authService.ListInvitations(r.Context())
+		if err != nil {
+			respondWithInvitationError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, invitations)
+
+	case http.MethodPost:
+		var req models.CreateInvitationRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		invitation, err := h.authService.CreateInvitation(r.Context(), req, GetUserFromContext(r.Context()))
+		h.authService.Audit(r, models.AuthEvent{
+			Type:   models.EventInvitationCreated,
+			Email:  req.Email,
+			Detail: "role " + string(req.Role),
+		}, err)
+		if err != nil {
+			respondWithInvitationError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, invitation)
+
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			RespondWithError(w, http.StatusBadRequest, "Invitation ID is required")
+			return
+		}
+
+		err := h.authService.RevokeInvitation(r.Context(), id)
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventInvitationRevoked, Detail: "invitation " + id}, err)
+		if err != nil {
+			respondWithInvitationError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Invitation revoked"})
+
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// respondWithInvitationError maps invitation management errors to responses
+func respondWithInvitationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrInvalidEmail, ErrInvalidRole, ErrInvalidInvitationLifetime:
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case ErrInvitationNotFound:
+		RespondWithError(w, http.StatusNotFound, err.Error())
+	case ErrRoleNotGrantable:
+		RespondWithError(w, http.StatusForbidden, err.Error())
+	case ErrUserAlreadyExists:
+		RespondWithError(w, http.StatusConflict, err.Error())
+	case ErrInvitationsNotConfigured:
+		RespondWithError(w, http.StatusNotImplemented, err.Error())
+	default:
+		RespondWithInternalError(w, r, "Failed to manage invitations", err)
+	}
+}
@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+// invitationToken extracts the token from an invitation link
+func invitationToken(t *testing.T, invitation *models.InvitationResponse) string {
+	t.Helper()
+
+	link, err := url.Parse(invitation.Link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return link.Query().Get("invitation")
+}
+
+// signupRequest is a valid signup for the address with an invitation token
+func signupRequest(email, token string) models.SignupRequest {
+	return models.SignupRequest{
+		Name:            "Jane Doe",
+		Email:           email,
+		Password:        testPassword,
+		PhoneNumber:     "+15550100",
+		InvitationToken: token,
+	}
+}
+
+func TestCreateInvitationRoleGrant(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestService(t)
+	admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+	if _, err := s.CreateRole(ctx, models.CreateRoleRequest{
+		Name:        "support",
+		Permissions: []string{"users:read", "users:manage", "orders:read", "orders:create"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	support := createTestUser(t, store, "support@example.com", "support")
+
+	tests := []struct {
+		name    string
+		actor   *models.User
+		role    models.UserRole
+		wantErr error
+	}{
+		{"admin invites an admin", admin, models.RoleAdmin, nil},
+		{"operator invites an admin", nil, models.RoleAdmin, nil},
+		{"support invites a customer", support, models.RoleCustomer, nil},
+		{"support invites support", support, "support", nil},
+		{"support invites an admin", support, models.RoleAdmin, ErrRoleNotGrantable},
+		{"support invites a vendor", support, models.RoleVendor, ErrRoleNotGrantable},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			email := string(rune('a'+i)) + "@example.com"
+			invitation, err := s.CreateInvitation(ctx, models.CreateInvitationRequest{Email: email, Role: tt.role}, tt.actor)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateInvitation() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			wantInviter := ""
+			if tt.actor != nil {
+				wantInviter = tt.actor.ID
+			}
+			if invitation.InvitedBy != wantInviter {
+				t.Errorf("InvitedBy = %q, want %q", invitation.InvitedBy, wantInviter)
+			}
+		})
+	}
+}
+
+func TestSignupWithInvitation(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("creates a verified user with the invited role once", func(t *testing.T) {
+		s, _ := newTestService(t)
+		invitation, err := s.CreateInvitation(ctx, models.CreateInvitationRequest{Email: "jane@example.com", Role: models.RoleAdmin}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := invitationToken(t, invitation)
+
+		user, err := s.Signup(ctx, signupRequest("Jane@Example.com", token))
+		if err != nil {
+			t.Fatalf("Signup() error = %v", err)
+		}
+		if user.Role != models.RoleAdmin || !user.EmailVerified {
+			t.Errorf("user role %s, email verified %v; want a verified admin", user.Role, user.EmailVerified)
+		}
+
+		if _, err := s.Signup(ctx, signupRequest("jane2@example.com", token)); !errors.Is(err, ErrInvalidInvitation) {
+			t.Errorf("second Signup() error = %v, want %v", err, ErrInvalidInvitation)
+		}
+		if err := s.RevokeInvitation(ctx, invitation.ID); !errors.Is(err, ErrInvitationNotFound) {
+			t.Errorf("RevokeInvitation() of a used invitation error = %v, want %v", err, ErrInvitationNotFound)
+		}
+	})
+
+	t.Run("rejects unusable invitations", func(t *testing.T) {
+		s, store := newTestService(t)
+		invitation, err := s.CreateInvitation(ctx, models.CreateInvitationRequest{Email: "jane@example.com", Role: models.RoleAdmin}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		token := invitationToken(t, invitation)
+
+		revoked, err := s.CreateInvitation(ctx, models.CreateInvitationRequest{Email: "revoked@example.com", Role: models.RoleAdmin}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := s.RevokeInvitation(ctx, revoked.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		const expiredToken = "expired-invitation-token"
+		if err := store.CreateInvitation(ctx, &models.Invitation{
+			ID:        "invite_expired",
+			Email:     "expired@example.com",
+			Role:      models.RoleAdmin,
+			TokenHash: utils.HashToken(expiredToken),
+			ExpiresAt: time.Now().Add(-time.Minute),
+			CreatedAt: time.Now().Add(-time.Hour),
+		}); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			req  models.SignupRequest
+		}{
+			{"unknown token", signupRequest("jane@example.com", "not-a-token")},
+			{"other address", signupRequest("mallory@example.com", token)},
+			{"other role", func() models.SignupRequest {
+				req := signupRequest("jane@example.com", token)
+				req.Role = models.RoleCustomer
+				return req
+			}()},
+			{"revoked", signupRequest("revoked@example.com", invitationToken(t, revoked))},
+			{"expired", signupRequest("expired@example.com", expiredToken)},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := s.Signup(ctx, tt.req); !errors.Is(err, ErrInvalidInvitation) {
+					t.Errorf("Signup() error = %v, want %v", err, ErrInvalidInvitation)
+				}
+			})
+		}
+
+		// The rejected attempts leave the invitation usable
+		if _, err := s.Signup(ctx, signupRequest("jane@example.com", token)); err != nil {
+			t.Errorf("Signup() after rejected attempts error = %v", err)
+		}
+	})
+
+	t.Run("privileged roles need an invitation", func(t *testing.T) {
+		s, _ := newTestService(t)
+		req := signupRequest("jane@example.com", "")
+		req.Role = models.RoleAdmin
+		if _, err := s.Signup(ctx, req); !errors.Is(err, ErrInvitationRequired) {
+			t.Errorf("Signup() as admin error = %v, want %v", err, ErrInvitationRequired)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// failLogins makes n login attempts with a wrong password and returns the last error
+func failLogins(t *testing.T, s *AuthService, email string, n int) error {
+	t.Helper()
+
+	var err error
+	for i := 0; i < n; i++ {
+		_, err = s.Login(context.Background(), models.LoginRequest{Email: email, Password: "wrong password"})
+	}
+	return err
+}
+
+func TestLockout(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("threshold failures lock the account", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(3, time.Hour, 4*time.Hour))
+		user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+
+		if err := failLogins(t, s, user.Email, 2); !errors.Is(err, ErrInvalidCredentials) {
+			t.Fatalf("failures below the threshold: error = %v, want %v", err, ErrInvalidCredentials)
+		}
+
+		var locked *AccountLockedError
+		if err := failLogins(t, s, user.Email, 1); !errors.As(err, &locked) {
+			t.Fatalf("failure at the threshold: error = %v, want an *AccountLockedError", err)
+		}
+		if until := time.Until(locked.Until); until < 59*time.Minute || until > time.Hour {
+			t.Errorf("locked for %s, want an hour", until)
+		}
+		if locked.RetryAfter() < 3540 {
+			t.Errorf("RetryAfter() = %d, want about 3600", locked.RetryAfter())
+		}
+
+		// Even the right password is refused while locked
+		if _, err := s.Login(ctx, models.LoginRequest{Email: user.Email, Password: testPassword}); !errors.Is(err, ErrAccountLocked) {
+			t.Errorf("Login() while locked error = %v, want %v", err, ErrAccountLocked)
+		}
+
+		lockouts, err := s.ListLockouts(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lockouts) != 1 || lockouts[0].FailedAttempts != 3 {
+			t.Errorf("ListLockouts() = %+v, want one lockout after 3 failures", lockouts)
+		}
+	})
+
+	t.Run("unlock ends the lockout", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(2, time.Hour, 4*time.Hour))
+		user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+		admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+
+		if _, err := s.UnlockUser(ctx, user.ID, admin.ID); !errors.Is(err, ErrAccountNotLocked) {
+			t.Errorf("UnlockUser() before a lockout error = %v, want %v", err, ErrAccountNotLocked)
+		}
+
+		if err := failLogins(t, s, user.Email, 2); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("error = %v, want %v", err, ErrAccountLocked)
+		}
+		if _, err := s.UnlockUser(ctx, user.ID, admin.ID); err != nil {
+			t.Fatalf("UnlockUser() error = %v", err)
+		}
+		login(t, s, user.Email)
+
+		lockouts, err := s.ListLockouts(ctx, user.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lockouts) != 1 || lockouts[0].UnlockedBy != admin.ID || lockouts[0].UnlockedAt == nil {
+			t.Errorf("ListLockouts() = %+v, want one lockout unlocked by %s", lockouts, admin.ID)
+		}
+	})
+
+	t.Run("a successful login resets the count", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(3, time.Hour, 4*time.Hour))
+		user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+
+		failLogins(t, s, user.Email, 2)
+		login(t, s, user.Email)
+		if err := failLogins(t, s, user.Email, 2); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("error = %v, want %v; earlier failures were not reset", err, ErrInvalidCredentials)
+		}
+	})
+
+	t.Run("wrong OTP codes count", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(2, time.Hour, 4*time.Hour))
+		user := createTestUser(t, store, "mfa@example.com", models.RoleCustomer, func(u *models.User) {
+			u.MFASecret = "JBSWY3DPEHPK3PXP"
+			u.MFAEnabled = true
+		})
+
+		var err error
+		for i := 0; i < 2; i++ {
+			_, err = s.Login(ctx, models.LoginRequest{Email: user.Email, Password: testPassword, OTPCode: "000000"})
+		}
+		if !errors.Is(err, ErrAccountLocked) {
+			t.Errorf("error = %v, want %v", err, ErrAccountLocked)
+		}
+	})
+
+	t.Run("the lockout expires", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(2, 10*time.Millisecond, time.Second))
+		user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+
+		if err := failLogins(t, s, user.Email, 2); !errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("error = %v, want %v", err, ErrAccountLocked)
+		}
+		time.Sleep(20 * time.Millisecond)
+		login(t, s, user.Email)
+	})
+
+	t.Run("a zero threshold disables lockout", func(t *testing.T) {
+		s, store := newTestService(t, WithLockoutPolicy(0, time.Hour, time.Hour))
+		user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+
+		if err := failLogins(t, s, user.Email, 20); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("error = %v, want %v", err, ErrInvalidCredentials)
+		}
+		login(t, s, user.Email)
+	})
+}
+
+func TestLockoutDurationAfter(t *testing.T) {
+	s, _ := newTestService(t, WithLockoutPolicy(5, 15*time.Minute, 2*time.Hour))
+
+	tests := []struct {
+		previousLockouts int
+		want             time.Duration
+	}{
+		{0, 15 * time.Minute},
+		{1, 30 * time.Minute},
+		{2, time.Hour},
+		{3, 2 * time.Hour},
+		{4, 2 * time.Hour},
+		{100, 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := s.lockoutDurationAfter(tt.previousLockouts); got != tt.want {
+			t.Errorf("lockoutDurationAfter(%d) = %s, want %s", tt.previousLockouts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/metrics"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+// Bucket upper bounds in seconds for bcrypt, which is deliberately slow
+var passwordHashBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+// activeSessionsTimeout bounds the session count query run on every scrape
+const activeSessionsTimeout = 5 * time.Second
+
+// authMetrics holds the metrics AuthService updates. A nil *authMetrics records
+// nothing.
+type authMetrics struct {
+	logins       *metrics.CounterVec
+	signups      *metrics.CounterVec
+	passwordHash *metrics.HistogramVec
+}
+
+// WithMetrics counts logins and signups by result and role, times password
+// hashing and reports the number of active sessions per role in registry
+func WithMetrics(registry *metrics.Registry) Option {
+	return func(s *AuthService) {
+		s.metrics = &authMetrics{
+			logins: registry.NewCounterVec("auth_logins_total",
+				"Login attempts by result (success or the reason for failure) and role.",
+				"result", "role"),
+			signups: registry.NewCounterVec("auth_signups_total",
+				"Signup attempts by result (success or the reason for failure) and role.",
+				"result", "role"),
+			passwordHash: registry.NewHistogramVec("auth_password_hash_duration_seconds",
+				"Time taken to hash (hash) or check (verify) a password with bcrypt.",
+				passwordHashBuckets, "operation"),
+		}
+
+		// Counted at scrape time, so sessions that simply expire drop out too
+		registry.NewGaugeVecFunc("auth_active_sessions",
+			"Unexpired login sessions by the role of their user.", "role",
+			func() map[string]float64 {
+				ctx, cancel := context.WithTimeout(context.Background(), activeSessionsTimeout)
+				defer cancel()
+
+				counts, err := s.sessions.CountActiveSessions(ctx)
+				if err != nil {
+					slog.ErrorContext(ctx, "Failed to count active sessions", "error", err)
+					return nil
+				}
+				values := make(map[string]float64, len(counts))
+				for role, count := range counts {
+					values[string(role)] = float64(count)
+				}
+				return values
+			})
+	}
+}
+
+// recordLogin counts a login attempt. user is nil if the email address is unknown.
+func (m *authMetrics) recordLogin(user *models.User, err error) {
+	if m == nil {
+		return
+	}
+
+	role := "unknown"
+	if user != nil {
+		role = string(user.Role)
+	}
+	m.logins.Inc(loginResult(err), role)
+}
+
+// recordSignup counts a signup attempt for the requested role
+func (m *authMetrics) recordSignup(role models.UserRole, err error) {
+	if m == nil {
+		return
+	}
+
+	// The role comes from the request, so only the built-in roles are kept as
+	// labels
+	label := "other"
+	switch role {
+	case models.RoleCustomer, models.RoleAdmin, models.RoleHealer, models.RoleVendor:
+		label = string(role)
+	}
+	m.signups.Inc(signupResult(err), label)
+}
+
+// loginResult names the outcome of a login for the result label
+func loginResult(err error) string {
+	var locked *AccountLockedError
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &locked):
+		return "locked"
+	case err == ErrInvalidCredentials:
+		return "invalid_credentials"
+	case err == ErrOTPRequired:
+		return "otp_required"
+	case err == ErrInvalidOTP:
+		return "invalid_otp"
+	case err == ErrAccountDisabled:
+		return "disabled"
+	case err == ErrVerificationRequired:
+		return "unverified"
+	}
+	return "error"
+}
+
+// signupResult names the outcome of a signup for the result label
+func signupResult(err error) string {
+	switch err {
+	case nil:
+		return "success"
+	case ErrUserAlreadyExists:
+		return "user_exists"
+	case ErrInvalidRole:
+		return "invalid_role"
+	case ErrInvitationRequired:
+		return "invitation_required"
+	case ErrInvalidInvitation:
+		return "invalid_invitation"
+	}
+	return "error"
+}
+
+// hashPassword hashes a password with bcrypt, timing it
+func (s *AuthService) hashPassword(password string) (string, error) {
+	start := time.Now()
+	hash, err := utils.HashPassword(password)
+	if s.metrics != nil {
+		s.metrics.passwordHash.Observe(time.Since(start).Seconds(), "hash")
+	}
+	return hash, err
+}
+
+// checkPassword reports whether password matches a bcrypt hash, timing the check
+func (s *AuthService) checkPassword(password, hash string) bool {
+	start := time.Now()
+	ok := utils.CheckPassword(password, hash)
+	if s.metrics != nil {
+		s.metrics.passwordHash.Observe(time.Since(start).Seconds(), "verify")
+	}
+	return ok
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link stays valid
+	passwordResetTTL = time.Hour
+
+	minPasswordLength = 8
+)
+
+// ForgotPassword emails a password reset link to the user. It returns nil whether or
+// not the address is registered, and quietly drops throttled requests, so the caller
+// learns nothing about which accounts exist.
+func (s *AuthService) ForgotPassword(ctx context.Context, email string) error {
+	user, err := s.users.GetUserByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	if err := s.checkVerificationThrottle(ctx, user.ID, models.PurposePasswordReset); err != nil {
+		if err == ErrTooManyRequests {
+			slog.WarnContext(ctx, "Password reset throttled", "user_id", user.ID)
+			return nil
+		}
+		return err
+	}
+
+	link, err := s.issuePasswordResetLink(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nWe received a request to reset your password. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes. If you did not ask for a reset, you can ignore this email.\n",
+		user.Name, link, int(passwordResetTTL.Minutes()))
+
+	return s.mailer.SendEmail(user.Email, "Reset your password", body)
+}
+
+// issuePasswordResetLink stores a new password reset token for the user and returns
+// the link to the reset page
+func (s *AuthService) issuePasswordResetLink(ctx context.Context, userID string) (string, error) {
+	token, err := s.issueVerificationToken(ctx, userID, models.PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return "", err
+	}
+
+	if s.resetURL == "" {
+		return s.publicURL + "/?reset=" + url.QueryEscape(token), nil
+	}
+	return s.resetURL + "?token=" + url.QueryEscape(token), nil
+}
+
+// ResetPassword consumes a password reset token, sets the new password and signs the
+// user out everywhere. It returns the ID of the user whose password was reset.
+func (s *AuthService) ResetPassword(ctx context.Context, token, newPassword string) (string, error) {
+	if len(newPassword) < minPasswordLength {
+		return "", ErrPasswordTooShort
+	}
+	if token == "" {
+		return "", ErrInvalidToken
+	}
+
+	userID, err := s.users.ConsumeVerificationToken(ctx, utils.HashToken(token), models.PurposePasswordReset)
+	if err != nil {
+		return "", err
+	}
+	if userID == "" {
+		return "", ErrInvalidToken
+	}
+
+	passwordHash, err := s.hashPassword(newPassword)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.users.UpdatePassword(ctx, userID, passwordHash); err != nil {
+		return "", err
+	}
+
+	// Whoever knew the old password may still hold a session
+	return userID, s.LogoutAll(ctx, userID)
+}
@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+func TestPasswordResetLink(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       []Option
+		wantPrefix string
+		tokenParam string
+	}{
+		{"bundled UI", []Option{WithPublicURL("https://auth.example.com/")}, "https://auth.example.com/?reset=", "reset"},
+		{"configured page", []Option{WithPublicURL("https://auth.example.com"), WithPasswordResetURL("https://app.example.com/reset")}, "https://app.example.com/reset?token=", "token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store := newTestService(t, tt.opts...)
+			user := createTestUser(t, store, "customer@example.com", models.RoleCustomer)
+
+			link, err := s.issuePasswordResetLink(context.Background(), user.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(link, tt.wantPrefix) {
+				t.Fatalf("link = %s, want prefix %s", link, tt.wantPrefix)
+			}
+
+			// The token in the link must reset the password
+			u, err := url.Parse(link)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID, err := s.ResetPassword(context.Background(), u.Query().Get(tt.tokenParam), "a new password")
+			if err != nil {
+				t.Fatalf("ResetPassword() error = %v", err)
+			}
+			if userID != user.ID {
+				t.Errorf("ResetPassword() reset %s, want %s", userID, user.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+func TestGetContactInfo(t *testing.T) {
+	s, store := newTestService(t)
+	verified := func(u *models.User) {
+		u.PhoneNumber = "+15550100"
+		u.PhoneVerified = true
+	}
+
+	tests := []struct {
+		name    string
+		user    *models.User
+		wantErr error
+	}{
+		{"verified healer", createTestUser(t, store, "healer@example.com", models.RoleHealer, verified), nil},
+		{"verified vendor", createTestUser(t, store, "vendor@example.com", models.RoleVendor, verified), nil},
+		{"unverified healer", createTestUser(t, store, "unverified@example.com", models.RoleHealer), ErrUserNotFound},
+		{"customer", createTestUser(t, store, "customer@example.com", models.RoleCustomer, verified), ErrUserNotFound},
+		{"admin", createTestUser(t, store, "admin@example.com", models.RoleAdmin, verified), ErrUserNotFound},
+		{"disabled healer", createTestUser(t, store, "disabled@example.com", models.RoleHealer, verified, func(u *models.User) {
+			now := time.Now()
+			u.DisabledAt = &now
+		}), ErrUserNotFound},
+		{"unknown user", &models.User{ID: "healer_unknown"}, ErrUserNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contact, err := s.GetContactInfo(context.Background(), tt.user.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("GetContactInfo() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if contact != nil {
+					t.Errorf("GetContactInfo() returned %+v with an error", contact)
+				}
+				return
+			}
+			if contact.Email != tt.user.Email || contact.PhoneNumber != tt.user.PhoneNumber {
+				t.Errorf("GetContactInfo() = %+v, want the details of %s", contact, tt.user.Email)
+			}
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+var (
+	ErrRolesNotConfigured    = errors.New("role management is not configured")
+	ErrRoleNotFound          = errors.New("role not found")
+	ErrRoleExists            = errors.New("role already exists")
+	ErrBuiltInRole           = errors.New("built-in roles cannot be deleted")
+	ErrRoleInUse             = errors.New("role is still assigned to users")
+	ErrInvalidRoleName       = errors.New("role names must be 2-50 lowercase letters, digits or underscores, starting with a letter")
+	ErrPermissionNotFound    = errors.New("permission not found")
+	ErrPermissionExists      = errors.New("permission already exists")
+	ErrInvalidPermissionName = errors.New("permission names must have the form <resource>:<action> in lowercase")
+	ErrLastAdminPermission   = errors.New("users:manage and roles:manage must each stay granted to at least one role")
+	ErrRoleNotGrantable      = errors.New("cannot grant or revoke a role with permissions you do not have")
+)
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_]{1,49}$`)
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:[a-z][a-z0-9_-]*$`)
+)
+
+// permissionCacheTTL bounds how long a permission change made on another instance
+// takes to apply. Changes made through this instance apply immediately.
+const permissionCacheTTL = 30 * time.Second
+
+// WithRoleStore enables database-backed roles and permissions. Without it, the
+// built-in roles have their default permissions and cannot be changed.
+func WithRoleStore(roles database.RoleStore) Option {
+	return func(s *AuthService) {
+		s.roles = roles
+	}
+}
+
+// WithPermissionsInToken embeds the effective permissions of the user's role in
+// access tokens as the "perms" claim
+func WithPermissionsInToken() Option {
+	return func(s *AuthService) {
+		s.embedPermissions = true
+	}
+}
+
+// HasPermission reports whether the user's role grants the permission. Lookup
+// failures are logged and deny access.
+func (s *AuthService) HasPermission(ctx context.Context, user *models.User, permission string) bool {
+	if user == nil {
+		return false
+	}
+
+	permissions, err := s.RolePermissions(ctx, user.Role)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load permissions", "role", user.Role, "error", err)
+		return false
+	}
+
+	for _, p := range permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RolePermissions returns the names of the permissions granted to a role. Unknown
+// roles have no permissions.
+func (s *AuthService) RolePermissions(ctx context.Context, role models.UserRole) ([]string, error) {
+	if permissions, ok := s.permissionCache.get(role); ok {
+		return permissions, nil
+	}
+
+	var permissions []string
+	if s.roles == nil {
+		for _, defaultRole := range models.DefaultRoles {
+			if defaultRole.Name == role {
+				permissions = defaultRole.Permissions
+			}
+		}
+	} else {
+		stored, err := s.roles.GetRole(ctx, role)
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			permissions = stored.Permissions
+		}
+	}
+
+	s.permissionCache.set(role, permissions)
+	return permissions, nil
+}
+
+// ListPermissions returns every defined permission
+func (s *AuthService) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	if s.roles == nil {
+		return models.DefaultPermissions, nil
+	}
+	return s.roles.ListPermissions(ctx)
+}
+
+// CreatePermission defines a new permission that can then be granted to roles
+func (s *AuthService) CreatePermission(ctx context.Context, req models.CreatePermissionRequest) (*models.Permission, error) {
+	if s.roles == nil {
+		return nil, ErrRolesNotConfigured
+	}
+	if len(req.Name) > 100 || !permissionNamePattern.MatchString(req.Name) {
+		return nil, ErrInvalidPermissionName
+	}
+
+	if _, err := s.findPermission(ctx, req.Name); err == nil {
+		return nil, ErrPermissionExists
+	} else if err != ErrPermissionNotFound {
+		return nil, err
+	}
+
+	permission := &models.Permission{
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.roles.CreatePermission(ctx, permission); err != nil {
+		return nil, err
+	}
+
+	return permission, nil
+}
+
+// DeletePermission removes a permission and revokes it from every role. The admin
+// permissions cannot be deleted.
+func (s *AuthService) DeletePermission(ctx context.Context, name string) error {
+	if s.roles == nil {
+		return ErrRolesNotConfigured
+	}
+	if isAdminPermission(name) {
+		return ErrLastAdminPermission
+	}
+
+	deleted, err := s.roles.DeletePermission(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPermissionNotFound
+	}
+
+	s.permissionCache.clear()
+	return nil
+}
+
+// ListRoles returns every role with its permissions
+func (s *AuthService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	if s.roles == nil {
+		return models.DefaultRoles, nil
+	}
+	return s.roles.ListRoles(ctx)
+}
+
+// GetRole returns a role with its permissions
+func (s *AuthService) GetRole(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	if s.roles == nil {
+		for _, role := range models.DefaultRoles {
+			if role.Name == name {
+				return &role, nil
+			}
+		}
+		return nil, ErrRoleNotFound
+	}
+
+	role, err := s.roles.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// CreateRole defines a new custom role with the given permissions
+func (s *AuthService) CreateRole(ctx context.Context, req models.CreateRoleRequest) (*models.Role, error) {
+	if s.roles == nil {
+		return nil, ErrRolesNotConfigured
+	}
+	if !roleNamePattern.MatchString(string(req.Name)) {
+		return nil, ErrInvalidRoleName
+	}
+
+	if _, err := s.GetRole(ctx, req.Name); err == nil {
+		return nil, ErrRoleExists
+	} else if err != ErrRoleNotFound {
+		return nil, err
+	}
+
+	if err := s.checkPermissions(ctx, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+		CreatedAt:   time.Now(),
+	}
+	if err := s.roles.CreateRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	s.permissionCache.clear()
+	return s.GetRole(ctx, req.Name)
+}
+
+// SetRolePermissions replaces the permissions granted to a role. Built-in roles can
+// be changed too, e.g. to take orders:refund away from admins, but the last role
+// holding users:manage or roles:manage cannot lose it.
+func (s *AuthService) SetRolePermissions(ctx context.Context, req models.SetRolePermissionsRequest) (*models.Role, error) {
+	if s.roles == nil {
+		return nil, ErrRolesNotConfigured
+	}
+
+	if _, err := s.GetRole(ctx, req.Name); err != nil {
+		return nil, err
+	}
+	if err := s.checkPermissions(ctx, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.roles.SetRolePermissions(ctx, req.Name, req.Permissions)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrLastAdminPermission
+	}
+
+	s.permissionCache.clear()
+	return s.GetRole(ctx, req.Name)
+}
+
+// DeleteRole removes a custom role that is no longer assigned to any user. The last
+// role holding users:manage or roles:manage cannot be deleted.
+func (s *AuthService) DeleteRole(ctx context.Context, name models.UserRole) error {
+	if s.roles == nil {
+		return ErrRolesNotConfigured
+	}
+
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if role.BuiltIn {
+		return ErrBuiltInRole
+	}
+	if last, err := s.lastAdminPermissionHolder(ctx, role); err != nil {
+		return err
+	} else if last {
+		return ErrLastAdminPermission
+	}
+
+	deleted, err := s.roles.DeleteRole(ctx, name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRoleInUse
+	}
+
+	s.permissionCache.clear()
+	return nil
+}
+
+// checkRoleGrant verifies that the actor may assign users to or remove them from the
+// role: the actor's own role must hold every permission of that role, and assigning
+// admins always takes roles:manage. A nil actor is the operator running the CLI,
+// who may grant any role.
+func (s *AuthService) checkRoleGrant(ctx context.Context, actor *models.User, role models.UserRole) error {
+	if actor == nil {
+		return nil
+	}
+
+	if role == models.RoleAdmin && !s.HasPermission(ctx, actor, "roles:manage") {
+		return ErrRoleNotGrantable
+	}
+
+	permissions, err := s.RolePermissions(ctx, role)
+	if err != nil {
+		return err
+	}
+	for _, permission := range permissions {
+		if !s.HasPermission(ctx, actor, permission) {
+			return ErrRoleNotGrantable
+		}
+	}
+	return nil
+}
+
+// lastAdminPermissionHolder reports whether the role is the only one granted one of
+// models.AdminPermissions
+func (s *AuthService) lastAdminPermissionHolder(ctx context.Context, role *models.Role) (bool, error) {
+	roles, err := s.ListRoles(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	for _, permission := range role.Permissions {
+		if !isAdminPermission(permission) {
+			continue
+		}
+		held := false
+		for _, other := range roles {
+			if other.Name == role.Name {
+				continue
+			}
+			for _, p := range other.Permissions {
+				held = held || p == permission
+			}
+		}
+		if !held {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// isAdminPermission reports whether the permission is one of models.AdminPermissions
+func isAdminPermission(name string) bool {
+	for _, permission := range models.AdminPermissions {
+		if permission == name {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPermissions verifies that every named permission exists
+func (s *AuthService) checkPermissions(ctx context.Context, names []string) error {
+	for _, name := range names {
+		if _, err := s.findPermission(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findPermission looks up a permission by name
+func (s *AuthService) findPermission(ctx context.Context, name string) (*models.Permission, error) {
+	permissions, err := s.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, permission := range permissions {
+		if permission.Name == name {
+			return &permission, nil
+		}
+	}
+	return nil, ErrPermissionNotFound
+}
+
+// permissionCache holds the permissions of each role for a short time, so that
+// permission checks do not hit the database on every request
+type permissionCache struct {
+	mu      sync.RWMutex
+	ttl     time.Duration
+	entries map[models.UserRole]permissionCacheEntry
+}
+
+type permissionCacheEntry struct {
+	permissions []string
+	expiresAt   time.Time
+}
+
+func newPermissionCache(ttl time.Duration) *permissionCache {
+	return &permissionCache{
+		ttl:     ttl,
+		entries: make(map[models.UserRole]permissionCacheEntry),
+	}
+}
+
+func (c *permissionCache) get(role models.UserRole) ([]string, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	entry, ok := c.entries[role]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.permissions, true
+}
+
+func (c *permissionCache) set(role models.UserRole, permissions []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[role] = permissionCacheEntry{permissions: permissions, expiresAt: time.Now().Add(c.ttl)}
+}
+
+func (c *permissionCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[models.UserRole]permissionCacheEntry)
+}
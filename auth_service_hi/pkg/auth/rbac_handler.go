@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// MyPermissionsHandler returns the role and effective permissions of the current user
+func (h *HTTPHandler) MyPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	user := GetUserFromContext(r.Context())
+	permissions, err := h.authService.RolePermissions(r.Context(), user.Role)
+	if err != nil {
+		RespondWithInternalError(w, r, "Failed to load permissions", err)
+		return
+	}
+	if permissions == nil {
+		permissions = []string{}
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"role":        user.Role,
+		"permissions": permissions,
+	})
+}
+
+// RolesHandler manages roles: GET lists them, POST creates one, PUT replaces the
+// permissions of one, and DELETE ?name=<role> removes a custom role
+func (h *HTTPHandler) RolesHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		roles, err := h.authService.ListRoles(r.Context())
+		if err != nil {
+			RespondWithInternalError(w, r, "Failed to list roles", err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, roles)
+
+	case http.MethodPost:
+		var req models.CreateRoleRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		role, err := h.authService.CreateRole(r.Context(), req)
+		h.authService.Audit(r, models.AuthEvent{
+			Type:   models.EventRoleCreated,
+			Detail: "role " + string(req.Name) + " with permissions " + strings.Join(req.Permissions, ", "),
+		}, err)
+		if err != nil {
+			respondWithRBACError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, role)
+
+	case http.MethodPut:
+		var req models.SetRolePermissionsRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		role, err := h.authService.SetRolePermissions(r.Context(), req)
+		h.authService.Audit(r, models.AuthEvent{
+			Type:   models.EventRoleUpdated,
+			Detail: "role " + string(req.Name) + " with permissions " + strings.Join(req.Permissions, ", "),
+		}, err)
+		if err != nil {
+			respondWithRBACError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, role)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			RespondWithError(w, http.StatusBadRequest, "Role name is required")
+			return
+		}
+
+		err := h.authService.DeleteRole(r.Context(), models.UserRole(name))
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventRoleDeleted, Detail: "role " + name}, err)
+		if err != nil {
+			respondWithRBACError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Role deleted"})
+
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// PermissionsHandler manages permissions: GET lists them, POST creates one, and
+// DELETE ?name=<permission> removes one and revokes it from every role
+func (h *HTTPHandler) PermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		permissions, err := h.authService.ListPermissions(r.Context())
+		if err != nil {
+			RespondWithInternalError(w, r, "Failed to list permissions", err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, permissions)
+
+	case http.MethodPost:
+		var req models.CreatePermissionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+			return
+		}
+
+		permission, err := h.authService.CreatePermission(r.Context(), req)
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventPermissionCreated, Detail: "permission " + req.Name}, err)
+		if err != nil {
+			respondWithRBACError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, permission)
+
+	case http.MethodDelete:
+		name := r.URL.Query().Get("name")
+		if name == "" {
+			RespondWithError(w, http.StatusBadRequest, "Permission name is required")
+			return
+		}
+
+		err := h.authService.DeletePermission(r.Context(), name)
+		h.authService.Audit(r, models.AuthEvent{Type: models.EventPermissionDeleted, Detail: "permission " + name}, err)
+		if err != nil {
+			respondWithRBACError(w, r, err)
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Permission deleted"})
+
+	default:
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
+
+// respondWithRBACError maps role and permission management errors to responses
+func respondWithRBACError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrInvalidRoleName, ErrInvalidPermissionName:
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case ErrRoleNotFound, ErrPermissionNotFound:
+		RespondWithError(w, http.StatusNotFound, err.Error())
+	case ErrRoleExists, ErrPermissionExists, ErrBuiltInRole, ErrRoleInUse, ErrLastAdminPermission:
+		RespondWithError(w, http.StatusConflict, err.Error())
+	case ErrRolesNotConfigured:
+		RespondWithError(w, http.StatusNotImplemented, err.Error())
+	default:
+		RespondWithInternalError(w, r, "Failed to update roles", err)
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// withoutPermission returns the admin role's permissions minus one
+func withoutPermission(t *testing.T, s *AuthService, name string) []string {
+	t.Helper()
+
+	role, err := s.GetRole(context.Background(), models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var permissions []string
+	for _, p := range role.Permissions {
+		if p != name {
+			permissions = append(permissions, p)
+		}
+	}
+	return permissions
+}
+
+func TestAdminPermissionsStayGranted(t *testing.T) {
+	ctx := context.Background()
+
+	for _, permission := range models.AdminPermissions {
+		t.Run(permission, func(t *testing.T) {
+			s, _ := newTestService(t)
+
+			// Taking it from the only role that holds it would lock the admin API
+			_, err := s.SetRolePermissions(ctx, models.SetRolePermissionsRequest{
+				Name:        models.RoleAdmin,
+				Permissions: withoutPermission(t, s, permission),
+			})
+			if !errors.Is(err, ErrLastAdminPermission) {
+				t.Fatalf("SetRolePermissions() error = %v, want %v", err, ErrLastAdminPermission)
+			}
+			if !s.HasPermission(ctx, &models.User{Role: models.RoleAdmin}, permission) {
+				t.Fatalf("admins lost %s after a refused change", permission)
+			}
+
+			if err := s.DeletePermission(ctx, permission); !errors.Is(err, ErrLastAdminPermission) {
+				t.Errorf("DeletePermission() error = %v, want %v", err, ErrLastAdminPermission)
+			}
+
+			// Once another role holds it, admins can give it up, but that role cannot
+			// then be deleted
+			if _, err := s.CreateRole(ctx, models.CreateRoleRequest{Name: "superuser", Permissions: []string{permission}}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.SetRolePermissions(ctx, models.SetRolePermissionsRequest{
+				Name:        models.RoleAdmin,
+				Permissions: withoutPermission(t, s, permission),
+			}); err != nil {
+				t.Fatalf("SetRolePermissions() with another holder error = %v", err)
+			}
+			if err := s.DeleteRole(ctx, "superuser"); !errors.Is(err, ErrLastAdminPermission) {
+				t.Errorf("DeleteRole() of the last holder error = %v, want %v", err, ErrLastAdminPermission)
+			}
+		})
+	}
+}
+
+func TestSetRolePermissions(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTestService(t)
+
+	role, err := s.SetRolePermissions(ctx, models.SetRolePermissionsRequest{
+		Name:        models.RoleAdmin,
+		Permissions: withoutPermission(t, s, "orders:refund"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range role.Permissions {
+		if p == "orders:refund" {
+			t.Error("orders:refund was not removed")
+		}
+	}
+	if s.HasPermission(ctx, &models.User{Role: models.RoleAdmin}, "orders:refund") {
+		t.Error("HasPermission() still grants the removed permission")
+	}
+}
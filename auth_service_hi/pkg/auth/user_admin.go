@@ -0,0 +1,182 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+var (
+	ErrLastAdmin       = errors.New("cannot demote or disable the last active admin")
+	ErrAccountDisabled = errors.New("account is disabled")
+	ErrInvalidPage     = errors.New("page must be at least 1 and per_page between 1 and 200")
+)
+
+const (
+	defaultUsersPerPage = 50
+	maxUsersPerPage     = 200
+)
+
+// ListUsers returns one page of the users matching the filter, newest first. A zero
+// page or per_page selects the first page of 50 users.
+func (s *AuthService) ListUsers(ctx context.Context, filter models.UserFilter) (*models.UserListResponse, error) {
+	if filter.Page == 0 {
+		filter.Page = 1
+	}
+	if filter.PerPage == 0 {
+		filter.PerPage = defaultUsersPerPage
+	}
+	if filter.Page < 1 || filter.PerPage < 1 || filter.PerPage > maxUsersPerPage {
+		return nil, ErrInvalidPage
+	}
+
+	users, total, err := s.users.ListUsers(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []models.User{}
+	}
+
+	return &models.UserListResponse{
+		Users:   users,
+		Total:   total,
+		Page:    filter.Page,
+		PerPage: filter.PerPage,
+	}, nil
+}
+
+// GetUser returns a single user
+func (s *AuthService) GetUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.users.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// ChangeUserRole assigns a defined role to a user and signs them out everywhere, so
+// their next tokens carry the new role. The actor must hold every permission of both
+// the user's current role and the new one (see checkRoleGrant). The last active
+// admin cannot be demoted.
+func (s *AuthService) ChangeUserRole(ctx context.Context, actor *models.User, userID string, role models.UserRole) (*models.User, error) {
+	exists, err := s.roleExists(ctx, role)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrInvalidRole
+	}
+
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	if err := s.checkRoleGrant(ctx, actor, user.Role); err != nil {
+		return nil, err
+	}
+	if err := s.checkRoleGrant(ctx, actor, role); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.users.UpdateUserRole(ctx, userID, role)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrLastAdmin
+	}
+
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// DisableUser blocks a user from logging in and ends all of their sessions. The last
+// active admin cannot be disabled.
+func (s *AuthService) DisableUser(ctx context.Context, userID string) (*models.User, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.users.SetUserDisabled(ctx, userID, true)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, ErrLastAdmin
+	}
+
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// EnableUser lets a disabled user log in again
+func (s *AuthService) EnableUser(ctx context.Context, userID string) (*models.User, error) {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.users.SetUserDisabled(ctx, userID, false); err != nil {
+		return nil, err
+	}
+
+	return s.GetUser(ctx, userID)
+}
+
+// ForcePasswordReset replaces the user's password with a random one nobody knows,
+// signs them out everywhere and emails them a link to choose a new password
+func (s *AuthService) ForcePasswordReset(ctx context.Context, userID string) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	unusable, err := utils.GenerateSecureToken()
+	if err != nil {
+		return err
+	}
+	passwordHash, err := s.hashPassword(unusable)
+	if err != nil {
+		return err
+	}
+	if err := s.users.UpdatePassword(ctx, user.ID, passwordHash); err != nil {
+		return err
+	}
+
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	link, err := s.issuePasswordResetLink(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nAn administrator has reset your password and signed you out. Open the link below to choose a new one:\n\n%s\n\nThe link expires in %d minutes. If it expires, use \"Forgot password\" to get a new one.\n",
+		user.Name, link, int(passwordResetTTL.Minutes()))
+
+	return s.mailer.SendEmail(user.Email, "Choose a new password", body)
+}
+
+// RevokeUserSessions signs a user out on all devices
+func (s *AuthService) RevokeUserSessions(ctx context.Context, userID string) error {
+	if _, err := s.GetUser(ctx, userID); err != nil {
+		return err
+	}
+	return s.LogoutAll(ctx, userID)
+}
@@ -0,0 +1,301 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// ListUsersHandler returns a page of users. Query parameters: role, verified
+// (true/false), disabled (true/false), created_after and created_before (RFC 3339),
+// page and per_page.
+func (h *HTTPHandler) ListUsersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	users, err := h.authService.ListUsers(r.Context(), filter)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, users)
+}
+
+// GetUserHandler returns the user given by the id query parameter
+func (h *HTTPHandler) GetUserHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID := r.URL.Query().Get("id")
+	if userID == "" {
+		RespondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	user, err := h.authService.GetUser(r.Context(), userID)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// ChangeUserRoleHandler assigns a new role to a user
+func (h *HTTPHandler) ChangeUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ChangeRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.UserID == "" || req.Role == "" {
+		RespondWithError(w, http.StatusBadRequest, "User ID and role are required")
+		return
+	}
+
+	user, err := h.authService.ChangeUserRole(r.Context(), GetUserFromContext(r.Context()), req.UserID, req.Role)
+	h.authService.Audit(r, models.AuthEvent{
+		Type:         models.EventRoleChanged,
+		TargetUserID: req.UserID,
+		Detail:       "role " + string(req.Role),
+	}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// DisableUserHandler disables an account and ends its sessions
+func (h *HTTPHandler) DisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := decodeUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.DisableUser(r.Context(), userID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventUserDisabled, TargetUserID: userID}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// EnableUserHandler re-enables a disabled account
+func (h *HTTPHandler) EnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := decodeUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	user, err := h.authService.EnableUser(r.Context(), userID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventUserEnabled, TargetUserID: userID}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// ForcePasswordResetHandler invalidates a user's password and emails them a reset link
+func (h *HTTPHandler) ForcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := decodeUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.authService.ForcePasswordReset(r.Context(), userID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventPasswordResetForced, TargetUserID: userID}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Password reset email sent"})
+}
+
+// RevokeUserSessionsHandler signs a user out on all devices
+func (h *HTTPHandler) RevokeUserSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := decodeUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.authService.RevokeUserSessions(r.Context(), userID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventSessionsRevoked, TargetUserID: userID}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, map[string]string{"message": "Sessions revoked"})
+}
+
+// UnlockUserHandler ends a lockout caused by repeated failed logins
+func (h *HTTPHandler) UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, ok := decodeUserAction(w, r)
+	if !ok {
+		return
+	}
+
+	admin := GetUserFromContext(r.Context())
+	user, err := h.authService.UnlockUser(r.Context(), userID, admin.ID)
+	h.authService.Audit(r, models.AuthEvent{Type: models.EventUserUnlocked, TargetUserID: userID}, err)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, user)
+}
+
+// ListLockoutsHandler returns the lockout history of the user given by the id query parameter
+func (h *HTTPHandler) ListLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	userID := r.URL.Query().Get("id")
+	if userID == "" {
+		RespondWithError(w, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	lockouts, err := h.authService.ListLockouts(r.Context(), userID)
+	if err != nil {
+		respondWithUserAdminError(w, r, err)
+		return
+	}
+
+	RespondWithJSON(w, http.StatusOK, lockouts)
+}
+
+// decodeUserAction checks the method and reads the target user of a POST action. It
+// writes the error response and returns false if the request is invalid.
+func decodeUserAction(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if r.Method != http.MethodPost {
+		RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return "", false
+	}
+
+	var req models.UserActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		RespondWithError(w, http.StatusBadRequest, "Invalid request body")
+		return "", false
+	}
+	if req.UserID == "" {
+		RespondWithError(w, http.StatusBadRequest, "User ID is required")
+		return "", false
+	}
+
+	return req.UserID, true
+}
+
+// parseUserFilter reads the user list filters from the query string
+func parseUserFilter(r *http.Request) (models.UserFilter, error) {
+	query := r.URL.Query()
+	filter := models.UserFilter{Role: models.UserRole(query.Get("role"))}
+
+	var err error
+	if filter.EmailVerified, err = queryBool(query, "verified"); err != nil {
+		return filter, err
+	}
+	if filter.Disabled, err = queryBool(query, "disabled"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedAfter, err = queryTime(query, "created_after"); err != nil {
+		return filter, err
+	}
+	if filter.CreatedBefore, err = queryTime(query, "created_before"); err != nil {
+		return filter, err
+	}
+	if filter.Page, err = queryInt(query, "page"); err != nil {
+		return filter, err
+	}
+	if filter.PerPage, err = queryInt(query, "per_page"); err != nil {
+		return filter, err
+	}
+
+	return filter, nil
+}
+
+// queryBool parses an optional true/false query parameter
+func queryBool(query url.Values, name string) (*bool, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be true or false", name)
+	}
+	return &value, nil
+}
+
+// queryTime parses an optional RFC 3339 query parameter
+func queryTime(query url.Values, name string) (time.Time, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return time.Time{}, nil
+	}
+	value, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return value, nil
+}
+
+// queryInt parses an optional integer query parameter
+func queryInt(query url.Values, name string) (int, error) {
+	raw := query.Get(name)
+	if raw == "" {
+		return 0, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number", name)
+	}
+	return value, nil
+}
+
+// respondWithUserAdminError maps user management errors to responses
+func respondWithUserAdminError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case ErrInvalidRole, ErrInvalidPage:
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case ErrUserNotFound:
+		RespondWithError(w, http.StatusNotFound, err.Error())
+	case ErrRoleNotGrantable:
+		RespondWithError(w, http.StatusForbidden, err.Error())
+	case ErrLastAdmin, ErrAccountNotLocked:
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		RespondWithInternalError(w, r, "Failed to manage user", err)
+	}
+}
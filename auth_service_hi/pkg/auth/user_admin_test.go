@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+func TestChangeUserRole(t *testing.T) {
+	ctx := context.Background()
+	s, store := newTestService(t)
+	admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+
+	// Support staff can manage customers but not roles, and cannot refund orders
+	if _, err := s.CreateRole(ctx, models.CreateRoleRequest{
+		Name:        "support",
+		Permissions: []string{"users:read", "users:manage", "orders:read", "orders:create"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	support := createTestUser(t, store, "support@example.com", "support")
+
+	tests := []struct {
+		name    string
+		actor   *models.User
+		from    models.UserRole
+		to      models.UserRole
+		wantErr error
+	}{
+		{"admin promotes to admin", admin, models.RoleCustomer, models.RoleAdmin, nil},
+		{"admin grants a custom role", admin, models.RoleCustomer, "support", nil},
+		{"support grants a role beyond its own", support, models.RoleCustomer, models.RoleHealer, ErrRoleNotGrantable},
+		{"support promotes to admin", support, models.RoleCustomer, models.RoleAdmin, ErrRoleNotGrantable},
+		{"support grants its own role", support, models.RoleCustomer, "support", nil},
+		{"support demotes support to customer", support, "support", models.RoleCustomer, nil},
+		{"support demotes an admin", support, models.RoleAdmin, models.RoleCustomer, ErrRoleNotGrantable},
+		{"operator promotes to admin", nil, models.RoleCustomer, models.RoleAdmin, nil},
+		{"undefined role", admin, models.RoleCustomer, "wizard", ErrInvalidRole},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := createTestUser(t, store, tt.name+"@example.com", tt.from)
+			session := login(t, s, user.Email)
+
+			updated, err := s.ChangeUserRole(ctx, tt.actor, user.ID, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChangeUserRole() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, _ := store.GetUserByID(ctx, user.ID)
+			if tt.wantErr != nil {
+				if stored.Role != tt.from {
+					t.Errorf("role = %s after a refused change, want %s", stored.Role, tt.from)
+				}
+				return
+			}
+			if updated.Role != tt.to || stored.Role != tt.to {
+				t.Errorf("role = %s, want %s", stored.Role, tt.to)
+			}
+			if _, _, err := s.ValidateToken(ctx, session.Token); !errors.Is(err, ErrInvalidSession) {
+				t.Errorf("session after a role change: error = %v, want %v", err, ErrInvalidSession)
+			}
+		})
+	}
+}
+
+func TestLastAdminGuard(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("last admin cannot be demoted or disabled", func(t *testing.T) {
+		s, store := newTestService(t)
+		admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+
+		if _, err := s.ChangeUserRole(ctx, admin, admin.ID, models.RoleCustomer); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("ChangeUserRole() error = %v, want %v", err, ErrLastAdmin)
+		}
+		if _, err := s.DisableUser(ctx, admin.ID); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("DisableUser() error = %v, want %v", err, ErrLastAdmin)
+		}
+
+		stored, _ := store.GetUserByID(ctx, admin.ID)
+		if stored.Role != models.RoleAdmin || stored.IsDisabled() {
+			t.Errorf("last admin was changed: role %s, disabled %v", stored.Role, stored.IsDisabled())
+		}
+	})
+
+	t.Run("disabled admins do not count", func(t *testing.T) {
+		s, store := newTestService(t)
+		admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+		createTestUser(t, store, "disabled@example.com", models.RoleAdmin, func(u *models.User) {
+			now := time.Now()
+			u.DisabledAt = &now
+		})
+
+		if _, err := s.ChangeUserRole(ctx, admin, admin.ID, models.RoleCustomer); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("ChangeUserRole() error = %v, want %v", err, ErrLastAdmin)
+		}
+	})
+
+	t.Run("an admin can step down while another remains", func(t *testing.T) {
+		s, store := newTestService(t)
+		admin := createTestUser(t, store, "admin@example.com", models.RoleAdmin)
+		other := createTestUser(t, store, "other@example.com", models.RoleAdmin)
+
+		if _, err := s.ChangeUserRole(ctx, admin, admin.ID, models.RoleCustomer); err != nil {
+			t.Fatalf("ChangeUserRole() error = %v", err)
+		}
+		if _, err := s.DisableUser(ctx, other.ID); !errors.Is(err, ErrLastAdmin) {
+			t.Errorf("DisableUser() of the remaining admin error = %v, want %v", err, ErrLastAdmin)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package authz
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+var (
+	customer = &Principal{Subject: "customer_1", Role: models.RoleCustomer}
+	admin    = &Principal{
+		Subject:     "admin_1",
+		Role:        models.RoleAdmin,
+		Permissions: []string{"users:manage", "roles:manage"},
+	}
+	// A machine client never passes a role or permission check, whatever its scopes
+	client = PrincipalFromClaims(&utils.JWTClaims{ClientID: "client_1", Scope: "users:manage admin"})
+)
+
+// serve runs middleware around a handler that records whether it was reached
+func serve(middleware func(http.Handler) http.Handler, principal *Principal) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	if principal != nil {
+		r = r.WithContext(WithPrincipal(r.Context(), principal))
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w, reached
+}
+
+// funcMiddleware adapts a http.HandlerFunc middleware for serve
+func funcMiddleware(m func(http.HandlerFunc) http.HandlerFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m(next.ServeHTTP)
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+	adminOnly := []models.UserRole{models.RoleAdmin}
+	staff := []models.UserRole{models.RoleAdmin, models.RoleHealer}
+
+	tests := []struct {
+		name       string
+		roles      []models.UserRole
+		principal  *Principal
+		wantStatus int
+		wantBody   string
+	}{
+		{"no principal", adminOnly, nil, http.StatusUnauthorized, `{"error":"Authentication required"}`},
+		{"allowed role", adminOnly, admin, http.StatusNoContent, ""},
+		{"one of several roles", staff, admin, http.StatusNoContent, ""},
+		{"denied role", staff, customer, http.StatusForbidden, `{"error":"Insufficient permissions","required_roles":["admin","healer"]}`},
+		{"client token", adminOnly, client, http.StatusForbidden, `{"error":"Insufficient permissions","required_roles":["admin"]}`},
+	}
+
+	middlewares := map[string]func(roles ...models.UserRole) func(http.Handler) http.Handler{
+		"RequireRole": func(roles ...models.UserRole) func(http.Handler) http.Handler {
+			return funcMiddleware(RequireRole(roles...))
+		},
+		"RequireRoleHandler": RequireRoleHandler,
+	}
+
+	for name, middleware := range middlewares {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				w, reached := serve(middleware(tt.roles...), tt.principal)
+				checkResponse(t, w, reached, tt.wantStatus, tt.wantBody)
+			})
+		}
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	tests := []struct {
+		name        string
+		permissions []string
+		principal   *Principal
+		wantStatus  int
+		wantBody    string
+	}{
+		{"no principal", []string{"users:manage"}, nil, http.StatusUnauthorized, `{"error":"Authentication required"}`},
+		{"held permission", []string{"users:manage"}, admin, http.StatusNoContent, ""},
+		{"all held permissions", []string{"users:manage", "roles:manage"}, admin, http.StatusNoContent, ""},
+		{"one missing permission", []string{"users:manage", "orders:create"}, admin, http.StatusForbidden,
+			`{"error":"Insufficient permissions","required_permissions":["users:manage","orders:create"]}`},
+		{"no embedded permissions", []string{"users:manage"}, customer, http.StatusForbidden,
+			`{"error":"Insufficient permissions","required_permissions":["users:manage"]}`},
+		{"client token with a matching scope", []string{"users:manage"}, client, http.StatusForbidden,
+			`{"error":"Insufficient permissions","required_permissions":["users:manage"]}`},
+	}
+
+	middlewares := map[string]func(permissions ...string) func(http.Handler) http.Handler{
+		"RequirePermission": func(permissions ...string) func(http.Handler) http.Handler {
+			return funcMiddleware(RequirePermission(permissions...))
+		},
+		"RequirePermissionHandler": RequirePermissionHandler,
+	}
+
+	for name, middleware := range middlewares {
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				w, reached := serve(middleware(tt.permissions...), tt.principal)
+				checkResponse(t, w, reached, tt.wantStatus, tt.wantBody)
+			})
+		}
+	}
+}
+
+// checkResponse checks the status and, for denials, the JSON error body
+func checkResponse(t *testing.T, w *httptest.ResponseRecorder, reached bool, wantStatus int, wantBody string) {
+	t.Helper()
+
+	if w.Code != wantStatus {
+		t.Fatalf("status = %d, want %d", w.Code, wantStatus)
+	}
+	if allowed := wantStatus == http.StatusNoContent; reached != allowed {
+		t.Fatalf("handler reached = %v, want %v", reached, allowed)
+	}
+	if wantBody == "" {
+		return
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+	if got := w.Body.String(); got != wantBody {
+		t.Errorf("body = %s, want %s", got, wantBody)
+	}
+}
+
+func TestPrincipalFromClaims(t *testing.T) {
+	user := PrincipalFromClaims(&utils.JWTClaims{
+		UserID:      "healer_1",
+		Role:        models.RoleHealer,
+		Permissions: []string{"bookings:read"},
+	})
+	if user.IsClient() || user.Subject != "healer_1" || !user.HasRole(models.RoleHealer) || !user.HasPermission("bookings:read") {
+		t.Errorf("user principal = %+v", user)
+	}
+
+	if !client.IsClient() || client.Subject != "client_1" || len(client.Scopes) != 2 {
+		t.Errorf("client principal = %+v", client)
+	}
+}
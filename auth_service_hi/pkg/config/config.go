@@ -0,0 +1,509 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/keys"
+	"github.com/herb-immortal/auth_service_hi/pkg/logging"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/ratelimit"
+)
+
+const (
+	EnvDevelopment = "development"
+	EnvProduction  = "production"
+
+	// defaultJWTSecret and defaultDBPassword are development placeholders that
+	// Validate refuses to accept in production
+	defaultJWTSecret  = "your-secret-key"
+	defaultDBPassword = "postgres"
+
+	minProductionSecretLength = 32
+)
+
+// Config is the complete service configuration. Fields are addressed by their
+// `config` tag path, e.g. database.max_open_conns in a config file or
+// AUTH_DATABASE_MAX_OPEN_CONNS in the environment. Fields tagged `secret:"true"`
+// are redacted by String.
+type Config struct {
+	Environment string          `config:"environment"`
+	Server      ServerConfig    `config:"server"`
+	Database    DatabaseConfig  `config:"database"`
+	JWT         JWTConfig       `config:"jwt"`
+	Auth        AuthConfig      `config:"auth"`
+	RateLimit   RateLimitConfig `config:"rate_limit"`
+	Audit       AuditConfig     `config:"audit"`
+	Log         LogConfig       `config:"log"`
+	Metrics     MetricsConfig   `config:"metrics"`
+	Health      HealthConfig    `config:"health"`
+}
+
+// ServerConfig holds HTTP server settings
+type ServerConfig struct {
+	Port           int      `config:"port"`
+	PublicURL      string   `config:"public_url"`      // Externally reachable base URL used in emailed links
+	TrustedProxies []string `config:"trusted_proxies"` // IPs or CIDRs whose X-Forwarded-For is believed
+
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout"` // Time allowed to send the request headers
+	ReadTimeout       time.Duration `config:"read_timeout"`        // Time allowed to send the whole request
+	WriteTimeout      time.Duration `config:"write_timeout"`       // Time allowed to handle the request and write the response
+	IdleTimeout       time.Duration `config:"idle_timeout"`        // How long an idle keep-alive connection is kept open
+	MaxHeaderBytes    int           `config:"max_header_bytes"`    // Largest accepted request header
+	DrainDelay        time.Duration `config:"drain_delay"`         // How long requests are still accepted after readiness fails at shutdown
+	ShutdownTimeout   time.Duration `config:"shutdown_timeout"`    // How long requests in flight get to finish at shutdown
+}
+
+// DatabaseConfig holds PostgreSQL connection settings
+type DatabaseConfig struct {
+	Host            string        `config:"host"`
+	Port            int           `config:"port"`
+	User            string        `config:"user"`
+	Password        string        `config:"password" secret:"true"`
+	Name            string        `config:"name"`
+	SSLMode         string        `config:"sslmode"`
+	MaxOpenConns    int           `config:"max_open_conns"`
+	MaxIdleConns    int           `config:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `config:"conn_max_lifetime"`
+}
+
+// JWTConfig holds token signing settings
+type JWTConfig struct {
+	Secret             string        `config:"secret" secret:"true"` // HS256 secret, used when no signing key file is set
+	SigningKeyFile     string        `config:"signing_key_file"`     // PEM RSA, ECDSA or Ed25519 private key
+	KeyID              string        `config:"key_id"`               // kid of the signing key; defaults to its thumbprint
+	KeyRing            bool          `config:"key_ring"`             // Store rotating signing keys in the database
+	KeyAlgorithm       string        `config:"key_algorithm"`        // Algorithm of generated keys: EdDSA, ES256, ES384, ES512 or RS256
+	KeyRefreshInterval time.Duration `config:"key_refresh_interval"` // How often each instance reloads the key ring
+	KeyActivationDelay time.Duration `config:"key_activation_delay"` // How long a rotated key is published before it signs
+	Issuer             string        `config:"issuer"`
+	AccessTokenTTL     time.Duration `config:"access_token_ttl"`
+	RefreshTokenTTL    time.Duration `config:"refresh_token_ttl"`
+	EmbedPermissions   bool          `config:"embed_permissions"` // Include the role's permissions in access tokens
+
+	// Base64 AES-256 key that encrypts the key ring's private keys in the database
+	KeyEncryptionKey string `config:"key_encryption_key" secret:"true"`
+}
+
+// AuthConfig holds account policy settings
+type AuthConfig struct {
+	EmailVerificationRoles  []string `config:"email_verification_roles"`  // Roles that must verify their email before logging in
+	InvitationRequiredRoles []string `config:"invitation_required_roles"` // Roles besides admin that can only sign up with an invitation
+	PasswordResetURL        string   `config:"password_reset_url"`        // Page password reset emails link to; empty for the bundled UI
+
+	LockoutThreshold   int           `config:"lockout_threshold"`    // Consecutive failed logins that lock an account; 0 disables lockout
+	LockoutDuration    time.Duration `config:"lockout_duration"`     // Length of the first lockout; each further one doubles it
+	MaxLockoutDuration time.Duration `config:"max_lockout_duration"` // Upper bound for doubled lockouts
+}
+
+// RateLimitConfig holds request rate limits. Each rule has the form
+// "[METHOD] <path> <ip|email> <requests>/<period>", e.g. "POST /api/auth/login email 5/1m".
+type RateLimitConfig struct {
+	Enabled         bool          `config:"enabled"`
+	Rules           []string      `config:"rules"`            // Routes without a rule are not limited
+	CleanupInterval time.Duration `config:"cleanup_interval"` // How often refilled buckets are dropped from memory
+}
+
+// AuditConfig holds audit log settings
+type AuditConfig struct {
+	Enabled    bool `config:"enabled"`
+	BufferSize int  `config:"buffer_size"` // Events held in memory while the database catches up; more are dropped
+}
+
+// LogConfig holds logging settings
+type LogConfig struct {
+	Format string `config:"format"` // text or json
+	Level  string `config:"level"`  // debug, info, warn or error
+}
+
+// MetricsConfig holds Prometheus metrics settings
+type MetricsConfig struct {
+	Enabled bool   `config:"enabled"`
+	Token   string `config:"token" secret:"true"` // Bearer token required to scrape /metrics; empty allows anyone
+}
+
+// HealthConfig holds readiness probe settings
+type HealthConfig struct {
+	Timeout time.Duration `config:"timeout"` // How long each readiness check may take before it counts as failed
+}
+
+// Default returns the development defaults
+func Default() *Config {
+	return &Config{
+		Environment: EnvDevelopment,
+		Server: ServerConfig{
+			Port:      8080,
+			PublicURL: "http://localhost:8080",
+
+			ReadHeaderTimeout: 5 * time.Second,
+			ReadTimeout:       15 * time.Second,
+			WriteTimeout:      30 * time.Second,
+			IdleTimeout:       2 * time.Minute,
+			MaxHeaderBytes:    64 << 10,
+			DrainDelay:        5 * time.Second,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			User:            "postgres",
+			Password:        defaultDBPassword,
+			Name:            "auth_service",
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 5 * time.Minute,
+		},
+		JWT: JWTConfig{
+			Secret:             defaultJWTSecret,
+			KeyAlgorithm:       "EdDSA",
+			KeyRefreshInterval: time.Minute,
+			KeyActivationDelay: 10 * time.Minute,
+			Issuer:             "auth-service",
+			AccessTokenTTL:     15 * time.Minute,
+			RefreshTokenTTL:    30 * 24 * time.Hour,
+		},
+		Auth: AuthConfig{
+			EmailVerificationRoles: []string{
+				string(models.RoleAdmin),
+				string(models.RoleHealer),
+				string(models.RoleVendor),
+			},
+			LockoutThreshold:   5,
+			LockoutDuration:    15 * time.Minute,
+			MaxLockoutDuration: 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Enabled: true,
+			Rules: []string{
+				"POST /api/auth/login ip 20/1m",
+				"POST /api/auth/login email 5/1m",
+				"POST /api/auth/signup ip 10/10m",
+				"POST /api/auth/refresh ip 60/1m",
+				"POST /api/auth/password/forgot ip 10/10m",
+				"POST /api/auth/password/forgot email 3/1h",
+				"POST /api/auth/password/reset ip 10/10m",
+				"POST /api/auth/verify-email/resend email 3/1h",
+				"POST /oauth/authorize ip 20/1m",
+				"POST /oauth/authorize email 5/1m",
+				"POST /oauth/token ip 60/1m",
+			},
+			CleanupInterval: time.Minute,
+		},
+		Audit: AuditConfig{
+			Enabled:    true,
+			BufferSize: 1024,
+		},
+		Log: LogConfig{
+			Format: logging.FormatText,
+			Level:  "info",
+		},
+		Metrics: MetricsConfig{
+			Enabled: true,
+		},
+		Health: HealthConfig{
+			Timeout: 2 * time.Second,
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, then the config file at path (if
+// path is not empty), then environment variables, and validates the result. The file
+// may be YAML (.yaml, .yml) or TOML (.toml).
+func Load(path string) (*Config, error) {
+	cfg := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %w", err)
+		}
+
+		var values map[string]interface{}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml":
+			values, err = parseYAML(data)
+		case ".toml":
+			values, err = parseTOML(data)
+		default:
+			return nil, fmt.Errorf("unsupported config file type %q", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+
+		if err := decode(cfg, values); err != nil {
+			return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// IsProduction reports whether the service runs in production mode
+func (c *Config) IsProduction() bool {
+	return c.Environment == EnvProduction
+}
+
+// Validate checks the configuration for missing or inconsistent values and, in
+// production, for development defaults that must not be deployed
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Environment != EnvDevelopment && c.Environment != EnvProduction {
+		addf("environment must be %q or %q, got %q", EnvDevelopment, EnvProduction, c.Environment)
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		addf("server.port must be between 1 and 65535")
+	}
+	if u, err := url.Parse(c.Server.PublicURL); err != nil || u.Scheme == "" || u.Host == "" {
+		addf("server.public_url must be an absolute URL")
+	}
+	if _, err := ratelimit.ParseTrustedProxies(c.Server.TrustedProxies); err != nil {
+		addf("server.trusted_proxies: %v", err)
+	}
+	if c.Server.ReadHeaderTimeout <= 0 || c.Server.ReadTimeout <= 0 || c.Server.WriteTimeout <= 0 || c.Server.IdleTimeout <= 0 {
+		addf("server.read_header_timeout, server.read_timeout, server.write_timeout and server.idle_timeout must be positive")
+	}
+	if c.Server.ReadTimeout < c.Server.ReadHeaderTimeout {
+		addf("server.read_timeout must be at least server.read_header_timeout")
+	}
+	if c.Server.MaxHeaderBytes < 4096 {
+		addf("server.max_header_bytes must be at least 4096")
+	}
+	if c.Server.DrainDelay < 0 {
+		addf("server.drain_delay must not be negative")
+	}
+	if c.Server.ShutdownTimeout <= 0 {
+		addf("server.shutdown_timeout must be positive")
+	}
+
+	if c.Database.Host == "" {
+		addf("database.host is required")
+	}
+	if c.Database.Port < 1 || c.Database.Port > 65535 {
+		addf("database.port must be between 1 and 65535")
+	}
+	if c.Database.User == "" {
+		addf("database.user is required")
+	}
+	if c.Database.Name == "" {
+		addf("database.name is required")
+	}
+	switch c.Database.SSLMode {
+	case "disable", "allow", "prefer", "require", "verify-ca", "verify-full":
+	default:
+		addf("database.sslmode %q is not a valid PostgreSQL sslmode", c.Database.SSLMode)
+	}
+	if c.Database.MaxOpenConns < 1 {
+		addf("database.max_open_conns must be at least 1")
+	}
+	if c.Database.MaxIdleConns < 0 || c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		addf("database.max_idle_conns must be between 0 and database.max_open_conns")
+	}
+	if c.Database.ConnMaxLifetime < 0 {
+		addf("database.conn_max_lifetime must not be negative")
+	}
+
+	if c.JWT.Secret == "" && c.JWT.SigningKeyFile == "" && !c.JWT.KeyRing {
+		addf("one of jwt.secret, jwt.signing_key_file or jwt.key_ring is required")
+	}
+	if c.JWT.KeyRing {
+		if c.JWT.SigningKeyFile != "" {
+			addf("jwt.signing_key_file and jwt.key_ring cannot both be set")
+		}
+		switch c.JWT.KeyAlgorithm {
+		case "EdDSA", "ES256", "ES384", "ES512", "RS256":
+		default:
+			addf("jwt.key_algorithm must be one of EdDSA, ES256, ES384, ES512 or RS256, got %q", c.JWT.KeyAlgorithm)
+		}
+		if c.JWT.KeyRefreshInterval <= 0 {
+			addf("jwt.key_refresh_interval must be positive")
+		}
+		if c.JWT.KeyActivationDelay < c.JWT.KeyRefreshInterval {
+			addf("jwt.key_activation_delay must be at least jwt.key_refresh_interval")
+		}
+		if c.JWT.KeyEncryptionKey != "" {
+			if kek, err := base64.StdEncoding.DecodeString(c.JWT.KeyEncryptionKey); err != nil || len(kek) != keys.KeyEncryptionKeySize {
+				addf("jwt.key_encryption_key must be %d bytes encoded as base64", keys.KeyEncryptionKeySize)
+			}
+		}
+	}
+	if c.JWT.Issuer == "" {
+		addf("jwt.issuer is required")
+	}
+	if c.JWT.AccessTokenTTL <= 0 {
+		addf("jwt.access_token_ttl must be positive")
+	}
+	if c.JWT.RefreshTokenTTL < c.JWT.AccessTokenTTL {
+		addf("jwt.refresh_token_ttl must be at least jwt.access_token_ttl")
+	}
+
+	if c.Auth.PasswordResetURL != "" {
+		if u, err := url.Parse(c.Auth.PasswordResetURL); err != nil || u.Scheme == "" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			addf("auth.password_reset_url must be an absolute URL without a query or fragment")
+		}
+	}
+	for _, role := range c.Auth.EmailVerificationRoles {
+		switch models.UserRole(role) {
+		case models.RoleCustomer, models.RoleAdmin, models.RoleHealer, models.RoleVendor:
+		default:
+			addf("auth.email_verification_roles contains unknown role %q", role)
+		}
+	}
+
+	for _, role := range c.Auth.InvitationRequiredRoles {
+		switch models.UserRole(role) {
+		case models.RoleCustomer, models.RoleAdmin, models.RoleHealer, models.RoleVendor:
+		default:
+			addf("auth.invitation_required_roles contains unknown role %q", role)
+		}
+	}
+
+	if c.Auth.LockoutThreshold < 0 {
+		addf("auth.lockout_threshold must not be negative")
+	}
+	if c.Auth.LockoutThreshold > 0 {
+		if c.Auth.LockoutDuration <= 0 {
+			addf("auth.lockout_duration must be positive")
+		}
+		if c.Auth.MaxLockoutDuration < c.Auth.LockoutDuration {
+			addf("auth.max_lockout_duration must be at least auth.lockout_duration")
+		}
+	}
+
+	if _, err := ratelimit.ParseRules(c.RateLimit.Rules); err != nil {
+		addf("rate_limit.rules: %v", err)
+	}
+	if c.RateLimit.Enabled && c.RateLimit.CleanupInterval <= 0 {
+		addf("rate_limit.cleanup_interval must be positive")
+	}
+
+	if c.Audit.Enabled && c.Audit.BufferSize < 1 {
+		addf("audit.buffer_size must be at least 1")
+	}
+
+	switch c.Log.Format {
+	case logging.FormatText, logging.FormatJSON:
+	default:
+		addf("log.format must be text or json, got %q", c.Log.Format)
+	}
+	if _, err := logging.ParseLevel(c.Log.Level); err != nil {
+		addf("log.level must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+
+	if c.Health.Timeout <= 0 {
+		addf("health.timeout must be positive")
+	}
+
+	if c.IsProduction() {
+		if c.JWT.SigningKeyFile == "" && !c.JWT.KeyRing && (c.JWT.Secret == defaultJWTSecret || len(c.JWT.Secret) < minProductionSecretLength) {
+			addf("jwt.secret must be changed from the default and be at least %d characters in production, or jwt.signing_key_file or jwt.key_ring must be set", minProductionSecretLength)
+		}
+		if c.JWT.KeyRing && c.JWT.KeyEncryptionKey == "" {
+			addf("jwt.key_encryption_key must be set in production when jwt.key_ring is enabled")
+		}
+		if c.Database.Password == defaultDBPassword || c.Database.Password == "" {
+			addf("database.password must be changed from the default in production")
+		}
+		// allow and prefer fall back to plaintext if the server does not offer TLS
+		switch c.Database.SSLMode {
+		case "disable", "allow", "prefer":
+			addf("database.sslmode must be require, verify-ca or verify-full in production, got %q", c.Database.SSLMode)
+		}
+		if u, err := url.Parse(c.Server.PublicURL); err == nil && u.Scheme != "https" {
+			addf("server.public_url must use https in production")
+		}
+		if u, err := url.Parse(c.Auth.PasswordResetURL); err == nil && c.Auth.PasswordResetURL != "" && u.Scheme != "https" {
+			addf("auth.password_reset_url must use https in production")
+		}
+		if c.Metrics.Enabled && c.Metrics.Token == "" {
+			addf("metrics.token must be set in production when metrics.enabled is true")
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+	}
+	return nil
+}
+
+// EmailVerificationRoles returns the roles that must verify their email as UserRoles
+func (c *Config) EmailVerificationRoles() []models.UserRole {
+	roles := make([]models.UserRole, 0, len(c.Auth.EmailVerificationRoles))
+	for _, role := range c.Auth.EmailVerificationRoles {
+		roles = append(roles, models.UserRole(role))
+	}
+	return roles
+}
+
+// InvitationRequiredRoles returns auth.invitation_required_roles as typed roles. Admin
+// always requires an invitation whether or not it is listed.
+func (c *Config) InvitationRequiredRoles() []models.UserRole {
+	roles := make([]models.UserRole, 0, len(c.Auth.InvitationRequiredRoles))
+	for _, role := range c.Auth.InvitationRequiredRoles {
+		roles = append(roles, models.UserRole(role))
+	}
+	return roles
+}
+
+// RateLimitRules returns rate_limit.rules parsed. Validate has already rejected
+// rules that do not parse.
+func (c *Config) RateLimitRules() []ratelimit.Rule {
+	rules, _ := ratelimit.ParseRules(c.RateLimit.Rules)
+	return rules
+}
+
+// TrustedProxies returns server.trusted_proxies parsed. Validate has already
+// rejected entries that do not parse.
+func (c *Config) TrustedProxies() ratelimit.TrustedProxies {
+	proxies, _ := ratelimit.ParseTrustedProxies(c.Server.TrustedProxies)
+	return proxies
+}
+
+// KeyEncryptionKey returns the decoded key that encrypts stored signing keys, or nil
+// if none is configured. Validate has already checked its length.
+func (c *Config) KeyEncryptionKey() []byte {
+	kek, _ := base64.StdEncoding.DecodeString(c.JWT.KeyEncryptionKey)
+	if len(kek) == 0 {
+		return nil
+	}
+	return kek
+}
+
+// LogLevel returns log.level parsed. Validate has already rejected unknown levels.
+func (c *Config) LogLevel() slog.Level {
+	level, _ := logging.ParseLevel(c.Log.Level)
+	return level
+}
+
+// String renders the effective configuration, one key per line, with secrets redacted
+func (c *Config) String() string {
+	var b strings.Builder
+	for _, field := range flatten(c) {
+		value := field.value
+		if field.secret && value != "" {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(&b, "%s = %s\n", field.path, value)
+	}
+	return b.String()
+}
@@ -0,0 +1,229 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeConfig writes a config file with the given name into a temporary directory
+func writeConfig(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadExample(t *testing.T) {
+	cfg, err := Load("../../config.example.yaml")
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if len(cfg.RateLimit.Rules) == 0 {
+		t.Error("rate_limit.rules from the example were not loaded")
+	}
+}
+
+func TestLoadFormats(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+	}{
+		{
+			name: "yaml",
+			file: "config.yaml",
+			contents: `
+server:
+  port: 9090
+  trusted_proxies:
+    - 10.0.0.0/8
+    - "192.168.0.1" # quoted, with a comment
+jwt:
+  secret: "abc#def, with a comma"
+  access_token_ttl: 10m
+rate_limit:
+  rules: ["POST /api/auth/login ip 5/1m", 'POST /api/auth/signup ip 1/1m']
+audit:
+`,
+		},
+		{
+			name: "toml",
+			file: "config.toml",
+			contents: `
+[server]
+port = 9090
+trusted_proxies = [
+  "10.0.0.0/8",
+  "192.168.0.1", # quoted, with a comment
+]
+
+[jwt]
+secret = "abc#def, with a comma"
+access_token_ttl = "10m"
+
+[rate_limit]
+rules = ["POST /api/auth/login ip 5/1m", 'POST /api/auth/signup ip 1/1m']
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, tt.file, tt.contents))
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if cfg.Server.Port != 9090 {
+				t.Errorf("server.port = %d, want 9090", cfg.Server.Port)
+			}
+			if want := []string{"10.0.0.0/8", "192.168.0.1"}; !reflect.DeepEqual(cfg.Server.TrustedProxies, want) {
+				t.Errorf("server.trusted_proxies = %q, want %q", cfg.Server.TrustedProxies, want)
+			}
+			if want := "abc#def, with a comma"; cfg.JWT.Secret != want {
+				t.Errorf("jwt.secret = %q, want %q", cfg.JWT.Secret, want)
+			}
+			if cfg.JWT.AccessTokenTTL != 10*time.Minute {
+				t.Errorf("jwt.access_token_ttl = %s, want 10m", cfg.JWT.AccessTokenTTL)
+			}
+			if want := []string{"POST /api/auth/login ip 5/1m", "POST /api/auth/signup ip 1/1m"}; !reflect.DeepEqual(cfg.RateLimit.Rules, want) {
+				t.Errorf("rate_limit.rules = %q, want %q", cfg.RateLimit.Rules, want)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		contents string
+		wantErr  string
+	}{
+		{"unknown key", "config.yaml", "server:\n  prot: 8080\n", `unknown key "server.prot"`},
+		{"duplicate key", "config.yaml", "server:\n  port: 1\n  port: 2\n", "already defined"},
+		{"wrong type", "config.yaml", "server:\n  port: [1, 2]\n", "server.port"},
+		{"list expected", "config.yaml", "server:\n  trusted_proxies: 10\n", "server.trusted_proxies"},
+		{"bad duration", "config.toml", "[jwt]\naccess_token_ttl = 15\n", "jwt.access_token_ttl"},
+		{"invalid toml", "config.toml", "[server\nport = 1\n", "failed to parse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.file, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Load() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyEnvList(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{"10.0.0.1, 10.0.0.2", []string{"10.0.0.1", "10.0.0.2"}},
+		{"[10.0.0.1, '10.0.0.2']", []string{"10.0.0.1", "10.0.0.2"}},
+		{`["POST /a ip 1/1m", "b, c"]`, []string{"POST /a ip 1/1m", "b, c"}},
+		{"[]", []string{}},
+	}
+
+	for _, tt := range tests {
+		cfg := Default()
+		lookup := func(name string) (string, bool) {
+			return tt.raw, name == "AUTH_SERVER_TRUSTED_PROXIES"
+		}
+		if err := applyEnv(cfg, lookup); err != nil {
+			t.Fatalf("applyEnv(%q) error = %v", tt.raw, err)
+		}
+		if !reflect.DeepEqual(cfg.Server.TrustedProxies, tt.want) {
+			t.Errorf("applyEnv(%q) = %q, want %q", tt.raw, cfg.Server.TrustedProxies, tt.want)
+		}
+	}
+}
+
+func TestValidateProductionSSLMode(t *testing.T) {
+	for _, mode := range []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"} {
+		cfg := Default()
+		cfg.Environment = EnvProduction
+		cfg.Database.SSLMode = mode
+
+		err := cfg.Validate()
+		rejected := err != nil && strings.Contains(err.Error(), "database.sslmode")
+		wantRejected := mode == "disable" || mode == "allow" || mode == "prefer"
+		if rejected != wantRejected {
+			t.Errorf("sslmode %s: error = %v, want rejected %v", mode, err, wantRejected)
+		}
+	}
+}
+
+func TestValidateKeyEncryptionKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		production bool
+		kek        string
+		wantErr    bool
+	}{
+		{"development without a key", false, "", false},
+		{"production without a key", true, "", true},
+		{"production with a key", true, "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=", false},
+		{"too short", false, "c2hvcnQ=", true},
+		{"not base64", false, "not base64!", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.JWT.KeyRing = true
+			cfg.JWT.KeyEncryptionKey = tt.kek
+			if tt.production {
+				cfg.Environment = EnvProduction
+			}
+
+			err := cfg.Validate()
+			rejected := err != nil && strings.Contains(err.Error(), "jwt.key_encryption_key")
+			if rejected != tt.wantErr {
+				t.Errorf("Validate() error = %v, want rejected %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMetricsToken(t *testing.T) {
+	tests := []struct {
+		name       string
+		production bool
+		enabled    bool
+		token      string
+		wantErr    bool
+	}{
+		{"development without a token", false, true, "", false},
+		{"production without a token", true, true, "", true},
+		{"production with a token", true, true, "scrape-token", false},
+		{"production with metrics disabled", true, false, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			cfg.Metrics.Enabled = tt.enabled
+			cfg.Metrics.Token = tt.token
+			if tt.production {
+				cfg.Environment = EnvProduction
+			}
+
+			err := cfg.Validate()
+			rejected := err != nil && strings.Contains(err.Error(), "metrics.token")
+			if rejected != tt.wantErr {
+				t.Errorf("Validate() error = %v, want rejected %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is prepended to the upper-cased key path to form environment variable names
+const envPrefix = "AUTH_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// leaf is a settable configuration value addressed by its dotted key path
+type leaf struct {
+	path   string
+	value  reflect.Value
+	secret bool
+}
+
+// flatField is a leaf rendered as text
+type flatField struct {
+	path   string
+	value  string
+	secret bool
+}
+
+// leaves walks the `config` tags of cfg and returns every scalar field
+func leaves(cfg *Config) []leaf {
+	var out []leaf
+	collectLeaves(reflect.ValueOf(cfg).Elem(), "", &out)
+	return out
+}
+
+func collectLeaves(v reflect.Value, prefix string, out *[]leaf) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("config")
+		if name == "" {
+			continue
+		}
+
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+
+		if field.Type.Kind() == reflect.Struct {
+			collectLeaves(v.Field(i), path, out)
+			continue
+		}
+
+		*out = append(*out, leaf{
+			path:   path,
+			value:  v.Field(i),
+			secret: field.Tag.Get("secret") == "true",
+		})
+	}
+}
+
+// decode applies values keyed by dotted path to cfg. Unknown keys are rejected so
+// that typos in a config file do not go unnoticed.
+func decode(cfg *Config, values map[string]interface{}) error {
+	byPath := make(map[string]leaf)
+	sections := make(map[string]bool)
+	for _, l := range leaves(cfg) {
+		byPath[l.path] = l
+		for i := range l.path {
+			if l.path[i] == '.' {
+				sections[l.path[:i]] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		l, ok := byPath[key]
+		if !ok {
+			// An empty section, e.g. "audit:" with everything commented out
+			if sections[key] && values[key] == nil {
+				continue
+			}
+			return fmt.Errorf("unknown key %q", key)
+		}
+		if err := setFileValue(l.value, values[key]); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	return nil
+}
+
+// applyEnv overrides cfg with environment variables named AUTH_<PATH>, for example
+// AUTH_DATABASE_PASSWORD for database.password. PORT is honoured for server.port
+// when AUTH_SERVER_PORT is not set, as most platforms set it.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, l := range leaves(cfg) {
+		name := EnvName(l.path)
+		raw, ok := lookup(name)
+		if !ok && l.path == "server.port" {
+			name = "PORT"
+			raw, ok = lookup(name)
+		}
+		if !ok {
+			continue
+		}
+		if err := setValue(l.value, raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// EnvName returns the environment variable that overrides the key at path
+func EnvName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// setFileValue sets the field to a value decoded from a config file. Strings are
+// parsed like environment variables, so durations such as "15m" work; other scalars
+// must suit the field's type. A null value resets the field to its zero value.
+func setFileValue(field reflect.Value, value interface{}) error {
+	isList := field.Kind() == reflect.Slice
+
+	switch v := value.(type) {
+	case nil:
+		field.Set(reflect.Zero(field.Type()))
+		return nil
+	case string:
+		return setValue(field, v)
+	case bool, int, int64, uint64, float64:
+		if isList {
+			return fmt.Errorf("expected a list, got %v", v)
+		}
+		return setValue(field, fmt.Sprint(v))
+	case []interface{}:
+		if !isList {
+			return fmt.Errorf("expected a single value, got a list")
+		}
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			switch item.(type) {
+			case string, bool, int, int64, uint64, float64:
+				items = append(items, fmt.Sprint(item))
+			default:
+				return fmt.Errorf("list items must be single values, got %v", item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+		return nil
+	case map[string]interface{}:
+		return fmt.Errorf("expected a value, got a mapping")
+	}
+	return fmt.Errorf("unsupported value %v", value)
+}
+
+// setValue parses raw into the field according to its type
+func setValue(field reflect.Value, raw string) error {
+	raw = strings.TrimSpace(raw)
+
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		field.SetBool(b)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list type %s", field.Type())
+		}
+		items, err := parseList(raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported type %s", field.Type())
+	}
+
+	return nil
+}
+
+// parseList accepts a YAML flow sequence such as "[a, 'b, c']", in which items may
+// be quoted to contain commas, as well as a bare "a,b"
+func parseList(raw string) ([]string, error) {
+	items := []string{}
+	if strings.HasPrefix(raw, "[") {
+		if err := yaml.Unmarshal([]byte(raw), &items); err != nil {
+			return nil, fmt.Errorf("invalid list %q", raw)
+		}
+		if items == nil {
+			items = []string{}
+		}
+		return items, nil
+	}
+
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// flatten renders every leaf of cfg as text, in declaration order
+func flatten(cfg *Config) []flatField {
+	var out []flatField
+	for _, l := range leaves(cfg) {
+		var value string
+		switch {
+		case l.value.Type() == durationType:
+			value = time.Duration(l.value.Int()).String()
+		case l.value.Kind() == reflect.Slice:
+			value = "[" + strings.Join(l.value.Interface().([]string), ", ") + "]"
+		default:
+			value = fmt.Sprint(l.value.Interface())
+		}
+		out = append(out, flatField{path: l.path, value: value, secret: l.secret})
+	}
+	return out
+}
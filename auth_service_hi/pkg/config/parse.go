@@ -0,0 +1,62 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// parseYAML reads a YAML config file and returns its values keyed by dotted path
+func parseYAML(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := flattenTree(tree, "", values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// parseTOML reads a TOML config file and returns its values keyed by dotted path
+func parseTOML(data []byte) (map[string]interface{}, error) {
+	var tree map[string]interface{}
+	if err := toml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]interface{})
+	if err := flattenTree(tree, "", values); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flattenTree adds the values of a decoded document to values, keyed by the dotted
+// path of nested mappings. Empty mappings and null values are kept under their own
+// path so that decode can check them against the known keys.
+func flattenTree(tree map[string]interface{}, prefix string, values map[string]interface{}) error {
+	for key, value := range tree {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 {
+				values[path] = nil
+				continue
+			}
+			if err := flattenTree(v, path, values); err != nil {
+				return err
+			}
+		case map[interface{}]interface{}:
+			return fmt.Errorf("%s: keys must be strings", path)
+		default:
+			values[path] = value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// AuditRepository handles database operations for the audit log
+type AuditRepository struct {
+	db *sql.DB
+}
+
+// NewAuditRepository creates a new audit log repository
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// authEventColumns lists the auth_events columns in the order scanned by scanAuthEvent
+const authEventColumns = `id, event_type, outcome, actor_id, target_user_id, email, ip_address, user_agent, detail, created_at`
+
+// InsertAuthEvents appends events to the audit log in a single statement
+func (r *AuditRepository) InsertAuthEvents(ctx context.Context, events []models.AuthEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	const columns = 10
+	values := make([]string, 0, len(events))
+	args := make([]interface{}, 0, len(events)*columns)
+	for i, event := range events {
+		n := i * columns
+		values = append(values, fmt.Sprintf(
+			"($%d, $%d, $%d, NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), NULLIF($%d, ''), $%d)",
+			n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10,
+		))
+		args = append(args,
+			event.ID, event.Type, event.Outcome, event.ActorID, event.TargetUserID,
+			event.Email, event.IPAddress, event.UserAgent, event.Detail, event.CreatedAt,
+		)
+	}
+
+	query := `INSERT INTO auth_events (` + authEventColumns + `) VALUES ` + strings.Join(values, ", ")
+	if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to insert auth events: %w", err)
+	}
+
+	return nil
+}
+
+// ListAuthEvents returns one page of the events matching the filter, newest first, and
+// the total number of matches
+func (r *AuditRepository) ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) ([]models.AuthEvent, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.UserID != "" {
+		addCondition("(actor_id = $%[1]d OR target_user_id = $%[1]d)", filter.UserID)
+	}
+	if filter.Email != "" {
+		addCondition("LOWER(email) = LOWER($%d)", filter.Email)
+	}
+	if filter.Type != "" {
+		addCondition("event_type = $%d", filter.Type)
+	}
+	if filter.Outcome != "" {
+		addCondition("outcome = $%d", filter.Outcome)
+	}
+	if !filter.Since.IsZero() {
+		addCondition("created_at >= $%d", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		addCondition("created_at < $%d", filter.Until)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM auth_events `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count auth events: %w", err)
+	}
+
+	query := `
+	SELECT ` + authEventColumns + `
+	FROM auth_events
+	` + where + `
+	ORDER BY created_at DESC, id
+	LIMIT $` + fmt.Sprint(len(args)+1) + ` OFFSET $` + fmt.Sprint(len(args)+2)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list auth events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []models.AuthEvent
+	for rows.Next() {
+		event, err := scanAuthEvent(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan auth event: %w", err)
+		}
+		events = append(events, *event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list auth events: %w", err)
+	}
+
+	return events, total, nil
+}
+
+// scanAuthEvent reads an event selected with authEventColumns
+func scanAuthEvent(row rowScanner) (*models.AuthEvent, error) {
+	var event models.AuthEvent
+	var actorID, targetUserID, email, ipAddress, userAgent, detail sql.NullString
+	err := row.Scan(
+		&event.ID, &event.Type, &event.Outcome, &actorID, &targetUserID,
+		&email, &ipAddress, &userAgent, &detail, &event.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	event.ActorID = actorID.String
+	event.TargetUserID = targetUserID.String
+	event.Email = email.String
+	event.IPAddress = ipAddress.String
+	event.UserAgent = userAgent.String
+	event.Detail = detail.String
+
+	return &event, nil
+}
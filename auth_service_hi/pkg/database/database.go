@@ -3,10 +3,11 @@ package database
 import (
 	"database/sql"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
-	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/lib/pq" // PostgreSQL driver
 )
 
 // Config holds database configuration
@@ -17,13 +18,25 @@ type Config struct {
 	Password string
 	DBName   string
 	SSLMode  string
+
+	// Connection pool settings; zero values fall back to the defaults below
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
 }
 
+// Default connection pool settings
+const (
+	defaultMaxOpenConns    = 25
+	defaultMaxIdleConns    = 5
+	defaultConnMaxLifetime = 5 * time.Minute
+)
+
 // NewConnection establishes a new database connection
 func NewConnection(cfg *Config) (*sql.DB, error) {
 	// First connect to the postgres database to check if our database exists
 	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=postgres sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.SSLMode)
+		dsnValue(cfg.Host), cfg.Port, dsnValue(cfg.User), dsnValue(cfg.Password), dsnValue(cfg.SSLMode))
 
 	db, err := sql.Open("postgres", dsn)
 	if err != nil {
@@ -44,12 +57,12 @@ func NewConnection(cfg *Config) (*sql.DB, error) {
 
 	// Create database if it doesn't exist
 	if !exists {
-		log.Printf("Database %s does not exist, creating...", cfg.DBName)
-		_, err = db.Exec(fmt.Sprintf("CREATE DATABASE %s", cfg.DBName))
+		slog.Info("Database does not exist, creating it", "database", cfg.DBName)
+		_, err = db.Exec("CREATE DATABASE " + pq.QuoteIdentifier(cfg.DBName))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create database: %w", err)
 		}
-		log.Printf("Database %s created successfully", cfg.DBName)
+		slog.Info("Database created", "database", cfg.DBName)
 	}
 
 	// Close the connection to postgres
@@ -57,7 +70,7 @@ func NewConnection(cfg *Config) (*sql.DB, error) {
 
 	// Connect to our database
 	dsn = fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName, cfg.SSLMode)
+		dsnValue(cfg.Host), cfg.Port, dsnValue(cfg.User), dsnValue(cfg.Password), dsnValue(cfg.DBName), dsnValue(cfg.SSLMode))
 
 	db, err = sql.Open("postgres", dsn)
 	if err != nil {
@@ -70,44 +83,30 @@ func NewConnection(cfg *Config) (*sql.DB, error) {
 	}
 
 	// Configure connection pool
-	db.SetMaxOpenConns(25)
-	db.SetMaxIdleConns(5)
-	db.SetConnMaxLifetime(5 * time.Minute)
+	maxOpenConns := cfg.MaxOpenConns
+	if maxOpenConns == 0 {
+		maxOpenConns = defaultMaxOpenConns
+	}
+	maxIdleConns := cfg.MaxIdleConns
+	if maxIdleConns == 0 {
+		maxIdleConns = defaultMaxIdleConns
+	}
+	connMaxLifetime := cfg.ConnMaxLifetime
+	if connMaxLifetime == 0 {
+		connMaxLifetime = defaultConnMaxLifetime
+	}
+	db.SetMaxOpenConns(maxOpenConns)
+	db.SetMaxIdleConns(maxIdleConns)
+	db.SetConnMaxLifetime(connMaxLifetime)
 
-	log.Println("Successfully connected to database")
+	slog.Info("Connected to database")
 	return db, nil
 }
 
-// CreateTables creates the necessary tables if they don't exist
-func CreateTables(db *sql.DB) error {
-	query := `
-	CREATE TABLE IF NOT EXISTS users (
-		id VARCHAR(255) PRIMARY KEY,
-		email VARCHAR(255) UNIQUE NOT NULL,
-		password_hash VARCHAR(255) NOT NULL,
-		mfa_secret VARCHAR(255),
-		phone_number VARCHAR(20) NOT NULL,
-		name VARCHAR(255) NOT NULL,
-		role VARCHAR(20) NOT NULL,
-		email_verified BOOLEAN NOT NULL DEFAULT false,
-		phone_verified BOOLEAN NOT NULL DEFAULT false,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-		updated_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-	
-	CREATE TABLE IF NOT EXISTS sessions (
-		id VARCHAR(255) PRIMARY KEY,
-		user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-		expires_at TIMESTAMP NOT NULL,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW()
-	);
-	`
-	
-	_, err := db.Exec(query)
-	if err != nil {
-		return fmt.Errorf("failed to create tables: %w", err)
-	}
-	
-	log.Println("Successfully created database tables")
-	return nil
-}
\ No newline at end of file
+// dsnValue quotes a connection string value so that passwords and other values
+// containing spaces or quotes are passed through intact
+func dsnValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// invitationColumns lists the invitations columns in the order scanInvitation expects them
+const invitationColumns = `id, email, role, token_hash, invited_by, expires_at, accepted_at, accepted_by, revoked_at, created_at`
+
+// scanInvitation reads a single invitation selected with invitationColumns
+func scanInvitation(row rowScanner) (*models.Invitation, error) {
+	var invitation models.Invitation
+	var invitedBy, acceptedBy sql.NullString
+	var acceptedAt, revokedAt sql.NullTime
+	err := row.Scan(
+		&invitation.ID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitation.TokenHash,
+		&invitedBy,
+		&invitation.ExpiresAt,
+		&acceptedAt,
+		&acceptedBy,
+		&revokedAt,
+		&invitation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation.InvitedBy = invitedBy.String
+	invitation.AcceptedBy = acceptedBy.String
+	if acceptedAt.Valid {
+		invitation.AcceptedAt = &acceptedAt.Time
+	}
+	if revokedAt.Valid {
+		invitation.RevokedAt = &revokedAt.Time
+	}
+
+	return &invitation, nil
+}
+
+// CreateInvitation stores a newly issued invitation
+func (r *UserRepository) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	query := `
+	INSERT INTO invitations (id, email, role, token_hash, invited_by, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		invitation.ID,
+		invitation.Email,
+		invitation.Role,
+		invitation.TokenHash,
+		sql.NullString{String: invitation.InvitedBy, Valid: invitation.InvitedBy != ""},
+		invitation.ExpiresAt,
+		invitation.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create invitation: %w", err)
+	}
+
+	return nil
+}
+
+// GetInvitationByHash retrieves an invitation by the hash of its token
+func (r *UserRepository) GetInvitationByHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	query := `
+	SELECT ` + invitationColumns + `
+	FROM invitations
+	WHERE token_hash = $1
+	`
+
+	invitation, err := scanInvitation(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get invitation: %w", err)
+	}
+
+	return invitation, nil
+}
+
+// ListInvitations returns every invitation, newest first
+func (r *UserRepository) ListInvitations(ctx context.Context) ([]models.Invitation, error) {
+	query := `
+	SELECT ` + invitationColumns + `
+	FROM invitations
+	ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+	defer rows.Close()
+
+	var invitations []models.Invitation
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan invitation: %w", err)
+		}
+		invitations = append(invitations, *invitation)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list invitations: %w", err)
+	}
+
+	return invitations, nil
+}
+
+// RevokeInvitation withdraws an invitation that has not been used yet. It returns
+// false if no such invitation exists.
+func (r *UserRepository) RevokeInvitation(ctx context.Context, id string) (bool, error) {
+	query := `
+	UPDATE invitations
+	SET revoked_at = $1
+	WHERE id = $2 AND accepted_at IS NULL AND revoked_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke invitation: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// CreateUserWithInvitation creates a user and consumes the invitation in a single
+// transaction. It returns false without creating the user if the invitation was
+// already used, revoked or has expired, so one invitation can never create two accounts.
+func (r *UserRepository) CreateUserWithInvitation(ctx context.Context, user *models.User, invitationID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, insertUserQuery, insertUserArgs(user)...); err != nil {
+		return false, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+	UPDATE invitations
+	SET accepted_at = $1, accepted_by = $2
+	WHERE id = $3 AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > $1
+	`, time.Now(), user.ID, invitationID)
+	if err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to accept invitation: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit invitation: %w", err)
+	}
+
+	return true, nil
+}
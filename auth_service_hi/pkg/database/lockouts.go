@@ -0,0 +1,181 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// RecordFailedLogin counts a failed login and returns the number of consecutive
+// failures and of lockouts since the last successful login. The increment is a
+// single statement, so concurrent failures are all counted. Failures while the
+// account is locked are not counted and return zero failures.
+func (r *UserRepository) RecordFailedLogin(ctx context.Context, userID string) (int, int, error) {
+	query := `
+	UPDATE users
+	SET failed_login_count = failed_login_count + 1, last_failed_login_at = $1
+	WHERE id = $2 AND (locked_until IS NULL OR locked_until <= $1)
+	RETURNING failed_login_count, lockout_count
+	`
+
+	var failures, lockouts int
+	err := r.db.QueryRowContext(ctx, query, time.Now(), userID).Scan(&failures, &lockouts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, nil
+		}
+		return 0, 0, fmt.Errorf("failed to record failed login: %w", err)
+	}
+
+	return failures, lockouts, nil
+}
+
+// LockUser locks the account until lockout.LockedUntil and records the lockout, but
+// only if the user still has at least threshold failed logins. Locking resets the
+// count, so when concurrent failures all cross the threshold only one lockout is
+// applied. It returns whether the account was locked.
+func (r *UserRepository) LockUser(ctx context.Context, lockout *models.AccountLockout, threshold int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+	UPDATE users
+	SET locked_until = $1, failed_login_count = 0, lockout_count = lockout_count + 1
+	WHERE id = $2 AND failed_login_count >= $3
+	`, lockout.LockedUntil, lockout.UserID, threshold)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to lock user: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO account_lockouts (id, user_id, failed_attempts, locked_at, locked_until)
+	VALUES ($1, $2, $3, $4, $5)
+	`, lockout.ID, lockout.UserID, lockout.FailedAttempts, lockout.LockedAt, lockout.LockedUntil)
+	if err != nil {
+		return false, fmt.Errorf("failed to record lockout: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit lockout: %w", err)
+	}
+
+	return true, nil
+}
+
+// ResetFailedLogins clears the failed login and lockout counters after a successful login
+func (r *UserRepository) ResetFailedLogins(ctx context.Context, userID string) error {
+	query := `
+	UPDATE users
+	SET failed_login_count = 0, lockout_count = 0, locked_until = NULL
+	WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reset failed logins: %w", err)
+	}
+
+	return nil
+}
+
+// UnlockUser ends an active lockout early, clears the counters and records which
+// admin unlocked the account. It returns false if the account was not locked.
+func (r *UserRepository) UnlockUser(ctx context.Context, userID, unlockedBy string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `
+	UPDATE users
+	SET failed_login_count = 0, lockout_count = 0, locked_until = NULL
+	WHERE id = $1 AND locked_until > $2
+	`, userID, now)
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock user: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to unlock user: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE account_lockouts
+	SET unlocked_at = $1, unlocked_by = $2
+	WHERE user_id = $3 AND locked_until > $1 AND unlocked_at IS NULL
+	`, now, sql.NullString{String: unlockedBy, Valid: unlockedBy != ""}, userID)
+	if err != nil {
+		return false, fmt.Errorf("failed to record unlock: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit unlock: %w", err)
+	}
+
+	return true, nil
+}
+
+// ListLockouts returns the lockout history of a user, newest first
+func (r *UserRepository) ListLockouts(ctx context.Context, userID string) ([]models.AccountLockout, error) {
+	query := `
+	SELECT id, user_id, failed_attempts, locked_at, locked_until, unlocked_at, unlocked_by
+	FROM account_lockouts
+	WHERE user_id = $1
+	ORDER BY locked_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	var lockouts []models.AccountLockout
+	for rows.Next() {
+		var lockout models.AccountLockout
+		var unlockedAt sql.NullTime
+		var unlockedBy sql.NullString
+		err := rows.Scan(
+			&lockout.ID,
+			&lockout.UserID,
+			&lockout.FailedAttempts,
+			&lockout.LockedAt,
+			&lockout.LockedUntil,
+			&unlockedAt,
+			&unlockedBy,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan lockout: %w", err)
+		}
+		if unlockedAt.Valid {
+			lockout.UnlockedAt = &unlockedAt.Time
+		}
+		lockout.UnlockedBy = unlockedBy.String
+		lockouts = append(lockouts, lockout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list lockouts: %w", err)
+	}
+
+	return lockouts, nil
+}
@@ -0,0 +1,1006 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// MemoryStore is a thread-safe, in-memory implementation of UserStore, SessionStore,
+// OAuthStore, RoleStore, InvitationStore and AuditStore. It is meant for tests and for embedding the service
+// without a database; all data is lost when the process exits.
+type MemoryStore struct {
+	mu sync.RWMutex
+
+	users              map[string]*models.User // keyed by user ID
+	userIDsByEmail     map[string]string
+	verificationTokens map[string]*models.VerificationToken // keyed by token ID
+	sessions           map[string]*models.Session           // keyed by session ID
+	refreshTokens      map[string]*models.RefreshToken      // keyed by token ID
+	oauthClients       map[string]*models.OAuthClient       // keyed by client ID
+	authorizationCodes map[string]*models.AuthorizationCode // keyed by code hash
+	permissions        map[string]*models.Permission        // keyed by name
+	roles              map[models.UserRole]*models.Role     // keyed by name
+	invitations        map[string]*models.Invitation        // keyed by invitation ID
+	lockouts           []models.AccountLockout              // in the order they were recorded
+	authEvents         []models.AuthEvent                   // in the order they were recorded
+}
+
+// NewMemoryStore creates an in-memory store holding only the default roles and
+// permissions, like a freshly migrated database
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{
+		users:              make(map[string]*models.User),
+		userIDsByEmail:     make(map[string]string),
+		verificationTokens: make(map[string]*models.VerificationToken),
+		sessions:           make(map[string]*models.Session),
+		refreshTokens:      make(map[string]*models.RefreshToken),
+		oauthClients:       make(map[string]*models.OAuthClient),
+		authorizationCodes: make(map[string]*models.AuthorizationCode),
+		permissions:        make(map[string]*models.Permission),
+		roles:              make(map[models.UserRole]*models.Role),
+		invitations:        make(map[string]*models.Invitation),
+	}
+
+	now := time.Now()
+	for _, permission := range models.DefaultPermissions {
+		seeded := permission
+		seeded.CreatedAt = now
+		m.permissions[permission.Name] = &seeded
+	}
+	for _, role := range models.DefaultRoles {
+		seeded := copyRole(&role)
+		seeded.CreatedAt = now
+		m.roles[role.Name] = seeded
+	}
+
+	return m
+}
+
+// CreateUser stores a new user, enforcing unique IDs and emails like the users table
+func (m *MemoryStore) CreateUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.createUserLocked(user)
+}
+
+// createUserLocked stores a new user; the caller must hold the write lock
+func (m *MemoryStore) createUserLocked(user *models.User) error {
+	if _, exists := m.users[user.ID]; exists {
+		return fmt.Errorf("failed to create user: duplicate id %q", user.ID)
+	}
+	if _, exists := m.userIDsByEmail[user.Email]; exists {
+		return fmt.Errorf("failed to create user: duplicate email %q", user.Email)
+	}
+
+	stored := *user
+	m.users[user.ID] = &stored
+	m.userIDsByEmail[user.Email] = user.ID
+	return nil
+}
+
+// GetUserByEmail retrieves a user by their email address
+func (m *MemoryStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	id, ok := m.userIDsByEmail[email]
+	if !ok {
+		return nil, nil
+	}
+	user := *m.users[id]
+	return &user, nil
+}
+
+// GetUserByID retrieves a user by their ID
+func (m *MemoryStore) GetUserByID(ctx context.Context, id string) (*models.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.users[id]
+	if !ok {
+		return nil, nil
+	}
+	user := *stored
+	return &user, nil
+}
+
+// UpdateVerificationStatus updates the verification status of a user's email or phone
+func (m *MemoryStore) UpdateVerificationStatus(ctx context.Context, userID string, emailVerified, phoneVerified bool) error {
+	return m.updateUser(userID, func(user *models.User) {
+		user.EmailVerified = emailVerified
+		user.PhoneVerified = phoneVerified
+	})
+}
+
+// UpdatePassword replaces a user's password hash
+func (m *MemoryStore) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	return m.updateUser(userID, func(user *models.User) {
+		user.PasswordHash = passwordHash
+	})
+}
+
+// UpdateMFA stores a user's TOTP secret and whether enrollment has been confirmed
+func (m *MemoryStore) UpdateMFA(ctx context.Context, userID, secret string, enabled bool) error {
+	return m.updateUser(userID, func(user *models.User) {
+		user.MFASecret = secret
+		user.MFAEnabled = enabled
+	})
+}
+
+// UseTOTPStep records that a TOTP code from the given time step was accepted,
+// returning false if the step is not after the last one recorded
+func (m *MemoryStore) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok || user.MFALastStep >= step {
+		return false, nil
+	}
+	user.MFALastStep = step
+	return true, nil
+}
+
+// ListUsers returns one page of the users matching the filter, newest first, and the
+// total number of matches
+func (m *MemoryStore) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matches []models.User
+	for _, user := range m.users {
+		if filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		if filter.EmailVerified != nil && user.EmailVerified != *filter.EmailVerified {
+			continue
+		}
+		if filter.Disabled != nil && user.IsDisabled() != *filter.Disabled {
+			continue
+		}
+		if !filter.CreatedAfter.IsZero() && user.CreatedAt.Before(filter.CreatedAfter) {
+			continue
+		}
+		if !filter.CreatedBefore.IsZero() && !user.CreatedAt.Before(filter.CreatedBefore) {
+			continue
+		}
+		matches = append(matches, *user)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.After(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	total := len(matches)
+	start := (filter.Page - 1) * filter.PerPage
+	if start > total {
+		start = total
+	}
+	end := start + filter.PerPage
+	if end > total {
+		end = total
+	}
+	return matches[start:end], total, nil
+}
+
+// UpdateUserRole changes a user's role unless that would remove the last active admin
+func (m *MemoryStore) UpdateUserRole(ctx context.Context, userID string, role models.UserRole) (bool, error) {
+	return m.updateGuardingLastAdmin(userID, role == models.RoleAdmin, func(user *models.User) {
+		user.Role = role
+	})
+}
+
+// SetUserDisabled disables or re-enables an account unless that would remove the
+// last active admin
+func (m *MemoryStore) SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error) {
+	return m.updateGuardingLastAdmin(userID, !disabled, func(user *models.User) {
+		if !disabled {
+			user.DisabledAt = nil
+		} else if user.DisabledAt == nil {
+			now := time.Now()
+			user.DisabledAt = &now
+		}
+	})
+}
+
+// updateGuardingLastAdmin mirrors UserRepository.updateGuardingLastAdmin
+func (m *MemoryStore) updateGuardingLastAdmin(userID string, stillAdmin bool, fn func(user *models.User)) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[userID]
+	if !ok {
+		return true, nil
+	}
+
+	if user.Role == models.RoleAdmin && !user.IsDisabled() && !stillAdmin {
+		admins := 0
+		for _, other := range m.users {
+			if other.Role == models.RoleAdmin && !other.IsDisabled() {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return false, nil
+		}
+	}
+
+	fn(user)
+	user.UpdatedAt = time.Now()
+	return true, nil
+}
+
+// RecordFailedLogin counts a failed login and returns the number of consecutive
+// failures and of lockouts since the last successful login. Failures while the
+// account is locked are not counted.
+func (m *MemoryStore) RecordFailedLogin(ctx context.Context, userID string) (int, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	user, ok := m.users[userID]
+	if !ok || user.IsLocked(now) {
+		return 0, 0, nil
+	}
+
+	user.FailedLoginCount++
+	user.LastFailedLoginAt = &now
+	return user.FailedLoginCount, user.LockoutCount, nil
+}
+
+// LockUser locks the account and records the lockout if the user still has at least
+// threshold failed logins
+func (m *MemoryStore) LockUser(ctx context.Context, lockout *models.AccountLockout, threshold int) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[lockout.UserID]
+	if !ok || user.FailedLoginCount < threshold {
+		return false, nil
+	}
+
+	lockedUntil := lockout.LockedUntil
+	user.LockedUntil = &lockedUntil
+	user.FailedLoginCount = 0
+	user.LockoutCount++
+	m.lockouts = append(m.lockouts, *lockout)
+	return true, nil
+}
+
+// ResetFailedLogins clears the failed login and lockout counters
+func (m *MemoryStore) ResetFailedLogins(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[userID]; ok {
+		user.FailedLoginCount = 0
+		user.LockoutCount = 0
+		user.LockedUntil = nil
+	}
+	return nil
+}
+
+// UnlockUser ends an active lockout early and records which admin unlocked the account
+func (m *MemoryStore) UnlockUser(ctx context.Context, userID, unlockedBy string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	user, ok := m.users[userID]
+	if !ok || !user.IsLocked(now) {
+		return false, nil
+	}
+
+	user.FailedLoginCount = 0
+	user.LockoutCount = 0
+	user.LockedUntil = nil
+	for i := range m.lockouts {
+		lockout := &m.lockouts[i]
+		if lockout.UserID == userID && lockout.UnlockedAt == nil && now.Before(lockout.LockedUntil) {
+			lockout.UnlockedAt = &now
+			lockout.UnlockedBy = unlockedBy
+		}
+	}
+	return true, nil
+}
+
+// ListLockouts returns the lockout history of a user, newest first
+func (m *MemoryStore) ListLockouts(ctx context.Context, userID string) ([]models.AccountLockout, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var lockouts []models.AccountLockout
+	for i := len(m.lockouts) - 1; i >= 0; i-- {
+		if m.lockouts[i].UserID == userID {
+			lockouts = append(lockouts, m.lockouts[i])
+		}
+	}
+	return lockouts, nil
+}
+
+// updateUser applies fn to a stored user. Like an UPDATE matching no rows, a missing
+// user is not an error.
+func (m *MemoryStore) updateUser(userID string, fn func(user *models.User)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if user, ok := m.users[userID]; ok {
+		fn(user)
+		user.UpdatedAt = time.Now()
+	}
+	return nil
+}
+
+// SaveVerificationToken stores a newly issued verification token
+func (m *MemoryStore) SaveVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.verificationTokens {
+		if existing.TokenHash == token.TokenHash {
+			return fmt.Errorf("failed to save verification token: duplicate token hash")
+		}
+	}
+
+	stored := *token
+	stored.ConsumedAt = nil
+	m.verificationTokens[token.ID] = &stored
+	return nil
+}
+
+// ConsumeVerificationToken marks an unexpired, unused token as consumed and returns
+// the ID of the user it was issued to
+func (m *MemoryStore) ConsumeVerificationToken(ctx context.Context, tokenHash string, purpose models.VerificationPurpose) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.verificationTokens {
+		if token.TokenHash == tokenHash && token.Purpose == purpose &&
+			token.ConsumedAt == nil && token.ExpiresAt.After(now) {
+			token.ConsumedAt = &now
+			return token.UserID, nil
+		}
+	}
+	return "", nil
+}
+
+// CountVerificationTokensSince counts the tokens issued to a user for a purpose since the given time
+func (m *MemoryStore) CountVerificationTokensSince(ctx context.Context, userID string, purpose models.VerificationPurpose, since time.Time) (int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	count := 0
+	for _, token := range m.verificationTokens {
+		if token.UserID == userID && token.Purpose == purpose && !token.CreatedAt.Before(since) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetActiveVerificationToken retrieves the most recent unexpired, unused token issued
+// to a user for a purpose
+func (m *MemoryStore) GetActiveVerificationToken(ctx context.Context, userID string, purpose models.VerificationPurpose) (*models.VerificationToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	var latest *models.VerificationToken
+	for _, token := range m.verificationTokens {
+		if token.UserID != userID || token.Purpose != purpose ||
+			token.ConsumedAt != nil || !token.ExpiresAt.After(now) {
+			continue
+		}
+		if latest == nil || token.CreatedAt.After(latest.CreatedAt) {
+			latest = token
+		}
+	}
+	if latest == nil {
+		return nil, nil
+	}
+
+	token := *latest
+	return &token, nil
+}
+
+// IncrementVerificationAttempts records a failed attempt to use a token
+func (m *MemoryStore) IncrementVerificationAttempts(ctx context.Context, tokenID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if token, ok := m.verificationTokens[tokenID]; ok {
+		token.Attempts++
+	}
+	return nil
+}
+
+// ConsumeVerificationTokenByID marks a token as consumed. It returns false if the
+// token had already been consumed.
+func (m *MemoryStore) ConsumeVerificationTokenByID(ctx context.Context, tokenID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	token, ok := m.verificationTokens[tokenID]
+	if !ok || token.ConsumedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	token.ConsumedAt = &now
+	return true, nil
+}
+
+// SaveSession stores a session
+func (m *MemoryStore) SaveSession(ctx context.Context, session *models.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessions[session.ID]; exists {
+		return fmt.Errorf("failed to save session: duplicate id %q", session.ID)
+	}
+	stored := *session
+	m.sessions[session.ID] = &stored
+	return nil
+}
+
+// GetSession retrieves a session by ID. It returns nil if the session does not exist.
+func (m *MemoryStore) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	stored, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+	session := *stored
+	return &session, nil
+}
+
+// DeleteSession removes a session
+func (m *MemoryStore) DeleteSession(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+// DeleteUserSessions removes every session belonging to a user
+func (m *MemoryStore) DeleteUserSessions(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, session := range m.sessions {
+		if session.UserID == userID {
+			delete(m.sessions, id)
+		}
+	}
+	return nil
+}
+
+// CountActiveSessions returns the number of unexpired sessions per role of their user
+func (m *MemoryStore) CountActiveSessions(ctx context.Context) (map[models.UserRole]int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := time.Now()
+	counts := make(map[models.UserRole]int)
+	for _, session := range m.sessions {
+		user, ok := m.users[session.UserID]
+		if !ok || !session.ExpiresAt.After(now) {
+			continue
+		}
+		counts[user.Role]++
+	}
+	return counts, nil
+}
+
+// SaveRefreshToken stores a newly issued refresh token
+func (m *MemoryStore) SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.saveRefreshTokenLocked(token)
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value
+func (m *MemoryStore) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, stored := range m.refreshTokens {
+		if stored.TokenHash == tokenHash {
+			token := *stored
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+// RotateRefreshToken marks the current token as used and stores its replacement
+// atomically. It returns false if the current token was already rotated or revoked.
+func (m *MemoryStore) RotateRefreshToken(ctx context.Context, currentID string, next *models.RefreshToken) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, ok := m.refreshTokens[currentID]
+	if !ok || current.RotatedAt != nil || current.RevokedAt != nil {
+		return false, nil
+	}
+
+	if err := m.saveRefreshTokenLocked(next); err != nil {
+		return false, err
+	}
+
+	now := time.Now()
+	current.RotatedAt = &now
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token issued for a session
+func (m *MemoryStore) RevokeRefreshTokenFamily(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.refreshTokens {
+		if token.SessionID == sessionID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token issued to a user
+func (m *MemoryStore) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for _, token := range m.refreshTokens {
+		if token.UserID == userID && token.RevokedAt == nil {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// saveRefreshTokenLocked stores a refresh token; the caller must hold the write lock
+func (m *MemoryStore) saveRefreshTokenLocked(token *models.RefreshToken) error {
+	if _, exists := m.refreshTokens[token.ID]; exists {
+		return fmt.Errorf("failed to save refresh token: duplicate id %q", token.ID)
+	}
+	for _, existing := range m.refreshTokens {
+		if existing.TokenHash == token.TokenHash {
+			return fmt.Errorf("failed to save refresh token: duplicate token hash")
+		}
+	}
+
+	stored := *token
+	stored.RotatedAt = nil
+	stored.RevokedAt = nil
+	m.refreshTokens[token.ID] = &stored
+	return nil
+}
+
+// CreateOAuthClient registers a new client
+func (m *MemoryStore) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.oauthClients[client.ID]; exists {
+		return fmt.Errorf("failed to create OAuth client: duplicate id %q", client.ID)
+	}
+
+	m.oauthClients[client.ID] = copyOAuthClient(client)
+	return nil
+}
+
+// GetOAuthClient retrieves a client by ID
+func (m *MemoryStore) GetOAuthClient(ctx context.Context, id string) (*models.OAuthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	client, ok := m.oauthClients[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyOAuthClient(client), nil
+}
+
+// ListOAuthClients retrieves every registered client, oldest first
+func (m *MemoryStore) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	clients := make([]models.OAuthClient, 0, len(m.oauthClients))
+	for _, client := range m.oauthClients {
+		clients = append(clients, *copyOAuthClient(client))
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].CreatedAt.Before(clients[j].CreatedAt) })
+	return clients, nil
+}
+
+// DeleteOAuthClient removes a client and its authorization codes. It reports whether
+// the client existed.
+func (m *MemoryStore) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.oauthClients[id]; !exists {
+		return false, nil
+	}
+
+	delete(m.oauthClients, id)
+	for hash, code := range m.authorizationCodes {
+		if code.ClientID == id {
+			delete(m.authorizationCodes, hash)
+		}
+	}
+	return true, nil
+}
+
+// copyOAuthClient returns a deep copy so callers cannot modify stored slices
+func copyOAuthClient(client *models.OAuthClient) *models.OAuthClient {
+	copied := *client
+	copied.RedirectURIs = append([]string(nil), client.RedirectURIs...)
+	copied.Scopes = append([]string(nil), client.Scopes...)
+	copied.GrantTypes = append([]string(nil), client.GrantTypes...)
+	return &copied
+}
+
+// SaveAuthorizationCode stores a newly issued authorization code
+func (m *MemoryStore) SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.authorizationCodes[code.CodeHash]; exists {
+		return fmt.Errorf("failed to save authorization code: duplicate code")
+	}
+
+	stored := *code
+	m.authorizationCodes[code.CodeHash] = &stored
+	return nil
+}
+
+// ConsumeAuthorizationCode atomically marks an unused code as consumed and returns it
+func (m *MemoryStore) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, ok := m.authorizationCodes[codeHash]
+	if !ok || code.ConsumedAt != nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	code.ConsumedAt = &now
+	copied := *code
+	return &copied, nil
+}
+
+// ListPermissions retrieves every permission, sorted by name
+func (m *MemoryStore) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	permissions := make([]models.Permission, 0, len(m.permissions))
+	for _, permission := range m.permissions {
+		permissions = append(permissions, *permission)
+	}
+	sort.Slice(permissions, func(i, j int) bool { return permissions[i].Name < permissions[j].Name })
+	return permissions, nil
+}
+
+// CreatePermission stores a new permission
+func (m *MemoryStore) CreatePermission(ctx context.Context, permission *models.Permission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.permissions[permission.Name]; exists {
+		return fmt.Errorf("failed to create permission: duplicate name %q", permission.Name)
+	}
+
+	stored := *permission
+	m.permissions[permission.Name] = &stored
+	return nil
+}
+
+// DeletePermission removes a permission and revokes it from every role
+func (m *MemoryStore) DeletePermission(ctx context.Context, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.permissions[name]; !exists {
+		return false, nil
+	}
+
+	delete(m.permissions, name)
+	for _, role := range m.roles {
+		role.Permissions = removeString(role.Permissions, name)
+	}
+	return true, nil
+}
+
+// ListRoles retrieves every role with its permissions, built-in roles first
+func (m *MemoryStore) ListRoles(ctx context.Context) ([]models.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	roles := make([]models.Role, 0, len(m.roles))
+	for _, role := range m.roles {
+		roles = append(roles, *copyRole(role))
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		if roles[i].BuiltIn != roles[j].BuiltIn {
+			return roles[i].BuiltIn
+		}
+		return roles[i].Name < roles[j].Name
+	})
+	return roles, nil
+}
+
+// GetRole retrieves a role with its permissions
+func (m *MemoryStore) GetRole(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	role, ok := m.roles[name]
+	if !ok {
+		return nil, nil
+	}
+	return copyRole(role), nil
+}
+
+// CreateRole stores a new role and its permissions
+func (m *MemoryStore) CreateRole(ctx context.Context, role *models.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.roles[role.Name]; exists {
+		return fmt.Errorf("failed to create role: duplicate name %q", role.Name)
+	}
+	if err := m.checkPermissionsLocked(role.Permissions); err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	m.roles[role.Name] = copyRole(role)
+	return nil
+}
+
+// SetRolePermissions replaces the permissions granted to a role, unless that would
+// leave no role holding one of models.AdminPermissions
+func (m *MemoryStore) SetRolePermissions(ctx context.Context, name models.UserRole, permissions []string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	role, ok := m.roles[name]
+	if !ok {
+		return false, fmt.Errorf("failed to set role permissions: unknown role %q", name)
+	}
+	if err := m.checkPermissionsLocked(permissions); err != nil {
+		return false, fmt.Errorf("failed to set role permissions: %w", err)
+	}
+
+	holders := make(map[string][]models.UserRole)
+	for _, other := range m.roles {
+		for _, permission := range other.Permissions {
+			holders[permission] = append(holders[permission], other.Name)
+		}
+	}
+	if removesLastAdminPermission(name, permissions, holders) {
+		return false, nil
+	}
+
+	role.Permissions = sortedUnique(permissions)
+	return true, nil
+}
+
+// DeleteRole removes a custom role that is not assigned to any user
+func (m *MemoryStore) DeleteRole(ctx context.Context, name models.UserRole) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	role, ok := m.roles[name]
+	if !ok || role.BuiltIn {
+		return false, nil
+	}
+	for _, user := range m.users {
+		if user.Role == name {
+			return false, nil
+		}
+	}
+
+	delete(m.roles, name)
+	for id, invitation := range m.invitations {
+		if invitation.Role == name {
+			delete(m.invitations, id)
+		}
+	}
+	return true, nil
+}
+
+// CreateInvitation stores a newly issued invitation
+func (m *MemoryStore) CreateInvitation(ctx context.Context, invitation *models.Invitation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.invitations[invitation.ID]; exists {
+		return fmt.Errorf("failed to create invitation: duplicate id %q", invitation.ID)
+	}
+	if _, exists := m.roles[invitation.Role]; !exists {
+		return fmt.Errorf("failed to create invitation: unknown role %q", invitation.Role)
+	}
+
+	stored := *invitation
+	m.invitations[invitation.ID] = &stored
+	return nil
+}
+
+// GetInvitationByHash retrieves an invitation by the hash of its token
+func (m *MemoryStore) GetInvitationByHash(ctx context.Context, tokenHash string) (*models.Invitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, invitation := range m.invitations {
+		if invitation.TokenHash == tokenHash {
+			found := *invitation
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+// ListInvitations returns every invitation, newest first
+func (m *MemoryStore) ListInvitations(ctx context.Context) ([]models.Invitation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	invitations := make([]models.Invitation, 0, len(m.invitations))
+	for _, invitation := range m.invitations {
+		invitations = append(invitations, *invitation)
+	}
+	sort.Slice(invitations, func(i, j int) bool {
+		return invitations[i].CreatedAt.After(invitations[j].CreatedAt)
+	})
+	return invitations, nil
+}
+
+// RevokeInvitation withdraws an invitation that has not been used yet
+func (m *MemoryStore) RevokeInvitation(ctx context.Context, id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	invitation, ok := m.invitations[id]
+	if !ok || invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+		return false, nil
+	}
+
+	now := time.Now()
+	invitation.RevokedAt = &now
+	return true, nil
+}
+
+// CreateUserWithInvitation creates a user and consumes the invitation under one lock
+func (m *MemoryStore) CreateUserWithInvitation(ctx context.Context, user *models.User, invitationID string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	invitation, ok := m.invitations[invitationID]
+	if !ok || !invitation.IsUsable(now) {
+		return false, nil
+	}
+
+	if err := m.createUserLocked(user); err != nil {
+		return false, err
+	}
+
+	invitation.AcceptedAt = &now
+	invitation.AcceptedBy = user.ID
+	return true, nil
+}
+
+// checkPermissionsLocked mirrors the role_permissions foreign key. The caller must
+// hold m.mu.
+func (m *MemoryStore) checkPermissionsLocked(permissions []string) error {
+	for _, name := range permissions {
+		if _, ok := m.permissions[name]; !ok {
+			return fmt.Errorf("unknown permission %q", name)
+		}
+	}
+	return nil
+}
+
+// copyRole returns a deep copy with sorted, de-duplicated permissions
+func copyRole(role *models.Role) *models.Role {
+	copied := *role
+	copied.Permissions = sortedUnique(role.Permissions)
+	return &copied
+}
+
+func sortedUnique(values []string) []string {
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = removeString(result, value)
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func removeString(values []string, value string) []string {
+	result := values[:0:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// InsertAuthEvents appends events to the audit log
+func (m *MemoryStore) InsertAuthEvents(ctx context.Context, events []models.AuthEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.authEvents = append(m.authEvents, events...)
+	return nil
+}
+
+// ListAuthEvents returns one page of the events matching the filter, newest first
+func (m *MemoryStore) ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) ([]models.AuthEvent, int, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var matches []models.AuthEvent
+	for _, event := range m.authEvents {
+		if filter.UserID != "" && event.ActorID != filter.UserID && event.TargetUserID != filter.UserID {
+			continue
+		}
+		if filter.Email != "" && !strings.EqualFold(event.Email, filter.Email) {
+			continue
+		}
+		if filter.Type != "" && event.Type != filter.Type {
+			continue
+		}
+		if filter.Outcome != "" && event.Outcome != filter.Outcome {
+			continue
+		}
+		if !filter.Since.IsZero() && event.CreatedAt.Before(filter.Since) {
+			continue
+		}
+		if !filter.Until.IsZero() && !event.CreatedAt.Before(filter.Until) {
+			continue
+		}
+		matches = append(matches, event)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.After(matches[j].CreatedAt)
+		}
+		return matches[i].ID < matches[j].ID
+	})
+
+	total := len(matches)
+	start := (filter.Page - 1) * filter.PerPage
+	if start > total {
+		start = total
+	}
+	end := start + filter.PerPage
+	if end > total {
+		end = total
+	}
+	return matches[start:end], total, nil
+}
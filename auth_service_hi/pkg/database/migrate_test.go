@@ -0,0 +1,193 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	file := func(contents string) *fstest.MapFile {
+		return &fstest.MapFile{Data: []byte(contents)}
+	}
+
+	tests := []struct {
+		name    string
+		files   fstest.MapFS
+		want    []Migration
+		wantErr string
+	}{
+		{
+			name: "sorted by version with paired scripts",
+			files: fstest.MapFS{
+				"migrations/0010_add_index.up.sql":      file("CREATE INDEX"),
+				"migrations/0002_add_column.up.sql":     file("ALTER TABLE ADD"),
+				"migrations/0002_add_column.down.sql":   file("ALTER TABLE DROP"),
+				"migrations/0001_create_table.up.sql":   file("CREATE TABLE"),
+				"migrations/0001_create_table.down.sql": file("DROP TABLE"),
+				"migrations/README.md":                  file("ignored"),
+			},
+			want: []Migration{
+				{Version: 1, Name: "create_table", Up: "CREATE TABLE", Down: "DROP TABLE"},
+				{Version: 2, Name: "add_column", Up: "ALTER TABLE ADD", Down: "ALTER TABLE DROP"},
+				{Version: 10, Name: "add_index", Up: "CREATE INDEX"},
+			},
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"migrations/0001_create_users.up.sql": file("CREATE TABLE users"),
+				"migrations/0001_create_roles.up.sql": file("CREATE TABLE roles"),
+			},
+			wantErr: "migration version 1 used by both",
+		},
+		{
+			name: "down script without up script",
+			files: fstest.MapFS{
+				"migrations/0001_create_table.down.sql": file("DROP TABLE"),
+			},
+			wantErr: "migration 1_create_table has no up script",
+		},
+		{
+			name: "missing name",
+			files: fstest.MapFS{
+				"migrations/0001.up.sql": file("CREATE TABLE"),
+			},
+			wantErr: `invalid migration file name "0001.up.sql"`,
+		},
+		{
+			name: "non-numeric version",
+			files: fstest.MapFS{
+				"migrations/first_create_table.up.sql": file("CREATE TABLE"),
+			},
+			wantErr: `invalid migration version in "first_create_table.up.sql"`,
+		},
+		{
+			name:    "no migrations directory",
+			files:   fstest.MapFS{},
+			wantErr: "failed to read migrations",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadMigrations(tt.files)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadMigrations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("loadMigrations() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("migration %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEmbeddedMigrations(t *testing.T) {
+	migrations, err := loadMigrations(migrationFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Versions run from 1 without gaps and every migration can be reverted
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d_%s: version %d, want %d", migration.Version, migration.Name, migration.Version, i+1)
+		}
+		if strings.TrimSpace(migration.Down) == "" {
+			t.Errorf("migration %04d_%s has no down script", migration.Version, migration.Name)
+		}
+	}
+}
+
+func TestPending(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "create_table", Up: "CREATE TABLE"},
+		{Version: 2, Name: "add_column", Up: "ALTER TABLE"},
+		{Version: 3, Name: "add_index", Up: "CREATE INDEX"},
+	}
+
+	tests := []struct {
+		name    string
+		applied []int
+		want    int
+	}{
+		{"fresh database", nil, 3},
+		{"partly migrated", []int{1, 2}, 1},
+		{"fully migrated", []int{1, 2, 3}, 0},
+		// A version this binary does not know about, applied by a newer release
+		{"ahead of the binary", []int{1, 2, 3, 4}, 0},
+		{"out of order", []int{2}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := sql.OpenDB(appliedConnector(tt.applied))
+			defer db.Close()
+
+			m := &Migrator{db: db, migrations: migrations}
+			got, err := m.Pending(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Pending() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// appliedConnector is a database/sql driver whose schema_migrations table holds the
+// given versions. It supports only the query appliedVersions runs.
+type appliedConnector []int
+
+func (c appliedConnector) Connect(context.Context) (driver.Conn, error) { return appliedConn(c), nil }
+func (c appliedConnector) Driver() driver.Driver                        { return nil }
+
+type appliedConn []int
+
+func (c appliedConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepared statements not supported")
+}
+func (c appliedConn) Close() error              { return nil }
+func (c appliedConn) Begin() (driver.Tx, error) { return nil, errors.New("transactions not supported") }
+
+func (c appliedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if query != `SELECT version, applied_at FROM schema_migrations` {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	return &appliedRows{versions: c}, nil
+}
+
+type appliedRows struct {
+	versions []int
+}
+
+func (r *appliedRows) Columns() []string { return []string{"version", "applied_at"} }
+func (r *appliedRows) Close() error      { return nil }
+
+func (r *appliedRows) Next(dest []driver.Value) error {
+	if len(r.versions) == 0 {
+		return io.EOF
+	}
+	dest[0] = int64(r.versions[0])
+	dest[1] = time.Now()
+	r.versions = r.versions[1:]
+	return nil
+}
@@ -0,0 +1,21 @@
+-- IF NOT EXISTS lets deployments created by the old CreateTables adopt this migration
+CREATE TABLE IF NOT EXISTS users (
+	id VARCHAR(255) PRIMARY KEY,
+	email VARCHAR(255) UNIQUE NOT NULL,
+	password_hash VARCHAR(255) NOT NULL,
+	mfa_secret VARCHAR(255),
+	phone_number VARCHAR(20) NOT NULL,
+	name VARCHAR(255) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	email_verified BOOLEAN NOT NULL DEFAULT false,
+	phone_verified BOOLEAN NOT NULL DEFAULT false,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS sessions (
+	id VARCHAR(255) PRIMARY KEY,
+	user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	expires_at TIMESTAMP NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
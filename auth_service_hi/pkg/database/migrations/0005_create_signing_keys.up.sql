@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS signing_keys (
+	id VARCHAR(255) PRIMARY KEY,
+	algorithm VARCHAR(20) NOT NULL,
+	private_key_pem TEXT NOT NULL,
+	status VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	retired_at TIMESTAMP
+);
+
+-- At most one key may sign new tokens at any time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_single_active ON signing_keys(status) WHERE status = 'active';
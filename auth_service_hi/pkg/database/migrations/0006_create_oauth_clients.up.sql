@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS oauth_clients (
+	id VARCHAR(255) PRIMARY KEY,
+	name VARCHAR(255) NOT NULL,
+	redirect_uris TEXT[] NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS authorization_codes (
+	code_hash VARCHAR(64) PRIMARY KEY,
+	client_id VARCHAR(255) NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+	user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	session_id VARCHAR(255) NOT NULL,
+	redirect_uri TEXT NOT NULL,
+	scope TEXT NOT NULL,
+	nonce TEXT NOT NULL DEFAULT '',
+	code_challenge VARCHAR(128) NOT NULL,
+	code_challenge_method VARCHAR(10) NOT NULL,
+	auth_time TIMESTAMP NOT NULL,
+	expires_at TIMESTAMP NOT NULL,
+	consumed_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_authorization_codes_expires_at ON authorization_codes(expires_at);
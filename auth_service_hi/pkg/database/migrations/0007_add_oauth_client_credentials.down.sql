@@ -0,0 +1,4 @@
+ALTER TABLE oauth_clients ALTER COLUMN redirect_uris DROP DEFAULT;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS grant_types;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS scopes;
+ALTER TABLE oauth_clients DROP COLUMN IF EXISTS secret_hash;
@@ -0,0 +1,5 @@
+-- Machine clients authenticate with a secret and have no redirect URIs
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS secret_hash VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS scopes TEXT[] NOT NULL DEFAULT '{}';
+ALTER TABLE oauth_clients ADD COLUMN IF NOT EXISTS grant_types TEXT[] NOT NULL DEFAULT '{authorization_code,refresh_token}';
+ALTER TABLE oauth_clients ALTER COLUMN redirect_uris SET DEFAULT '{}';
@@ -0,0 +1,51 @@
+CREATE TABLE IF NOT EXISTS permissions (
+	name VARCHAR(100) PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS roles (
+	name VARCHAR(50) PRIMARY KEY,
+	description TEXT NOT NULL DEFAULT '',
+	built_in BOOLEAN NOT NULL DEFAULT FALSE,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions (
+	role_name VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+	permission_name VARCHAR(100) NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+	PRIMARY KEY (role_name, permission_name)
+);
+
+-- Keep in sync with models.DefaultPermissions and models.DefaultRoles
+INSERT INTO permissions (name, description) VALUES
+	('orders:read', 'View orders'),
+	('orders:create', 'Place orders'),
+	('orders:refund', 'Refund orders'),
+	('products:manage', 'Create and edit products'),
+	('consultations:manage', 'Manage healing consultations'),
+	('users:read', 'View user accounts'),
+	('users:manage', 'Manage user accounts'),
+	('roles:manage', 'Manage roles and permissions'),
+	('keys:rotate', 'Rotate token signing keys')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO roles (name, description, built_in) VALUES
+	('customer', 'Shops and books consultations', TRUE),
+	('healer', 'Provides consultations', TRUE),
+	('vendor', 'Sells products', TRUE),
+	('admin', 'Full access', TRUE)
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+	('customer', 'orders:read'),
+	('customer', 'orders:create'),
+	('healer', 'orders:read'),
+	('healer', 'consultations:manage'),
+	('vendor', 'orders:read'),
+	('vendor', 'products:manage')
+ON CONFLICT DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name)
+SELECT 'admin', name FROM permissions
+ON CONFLICT DO NOTHING;
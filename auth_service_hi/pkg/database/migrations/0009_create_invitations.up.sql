@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS invitations (
+	id VARCHAR(255) PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	role VARCHAR(50) NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+	token_hash VARCHAR(64) UNIQUE NOT NULL,
+	invited_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+	expires_at TIMESTAMP NOT NULL,
+	accepted_at TIMESTAMP,
+	accepted_by VARCHAR(255) REFERENCES users(id) ON DELETE SET NULL,
+	revoked_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitations_email ON invitations(email);
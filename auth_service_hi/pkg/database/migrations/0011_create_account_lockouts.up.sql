@@ -0,0 +1,16 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS failed_login_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS last_failed_login_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS lockout_count INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS locked_until TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS account_lockouts (
+	id VARCHAR(255) PRIMARY KEY,
+	user_id VARCHAR(255) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+	failed_attempts INTEGER NOT NULL,
+	locked_at TIMESTAMP NOT NULL,
+	locked_until TIMESTAMP NOT NULL,
+	unlocked_at TIMESTAMP,
+	unlocked_by VARCHAR(255)
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_lockouts_user_id ON account_lockouts(user_id, locked_at);
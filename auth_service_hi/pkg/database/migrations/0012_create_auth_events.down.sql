@@ -0,0 +1,4 @@
+DELETE FROM permissions WHERE name = 'audit:read';
+
+DROP TABLE IF EXISTS auth_events;
+DROP FUNCTION IF EXISTS reject_auth_event_changes();
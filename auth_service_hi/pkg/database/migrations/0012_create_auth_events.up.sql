@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS auth_events (
+	id VARCHAR(255) PRIMARY KEY,
+	event_type VARCHAR(50) NOT NULL,
+	outcome VARCHAR(20) NOT NULL,
+	actor_id VARCHAR(255),
+	target_user_id VARCHAR(255),
+	email VARCHAR(255),
+	ip_address VARCHAR(45),
+	user_agent TEXT,
+	detail TEXT,
+	created_at TIMESTAMP NOT NULL
+);
+
+-- No foreign keys: events outlive the users they mention
+CREATE INDEX IF NOT EXISTS idx_auth_events_target_user_id ON auth_events(target_user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_actor_id ON auth_events(actor_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_email ON auth_events(email, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_created_at ON auth_events(created_at);
+
+-- The audit log is append-only
+CREATE OR REPLACE FUNCTION reject_auth_event_changes() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'auth_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS auth_events_append_only ON auth_events;
+CREATE TRIGGER auth_events_append_only
+	BEFORE UPDATE OR DELETE ON auth_events
+	FOR EACH ROW EXECUTE FUNCTION reject_auth_event_changes();
+
+-- Keep in sync with models.DefaultPermissions
+INSERT INTO permissions (name, description) VALUES
+	('audit:read', 'View the authentication audit log')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role_name, permission_name) VALUES
+	('admin', 'audit:read')
+ON CONFLICT DO NOTHING;
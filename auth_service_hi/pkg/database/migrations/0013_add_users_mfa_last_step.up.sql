@@ -0,0 +1,3 @@
+-- Last TOTP time step accepted for each user, so that a code cannot be replayed
+-- within its validity window
+ALTER TABLE users ADD COLUMN IF NOT EXISTS mfa_last_step BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DELETE FROM signing_keys WHERE status = 'pending';
+
+DROP INDEX IF EXISTS idx_signing_keys_single_pending;
@@ -0,0 +1,2 @@
+-- At most one key may wait to be activated at any time
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signing_keys_single_pending ON signing_keys(status) WHERE status = 'pending';
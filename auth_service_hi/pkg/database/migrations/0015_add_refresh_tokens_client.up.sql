@@ -0,0 +1,4 @@
+-- Refresh tokens issued through OpenID Connect are bound to the client they were
+-- issued to, and remember the scopes it was granted
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS client_id VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS scope TEXT NOT NULL DEFAULT '';
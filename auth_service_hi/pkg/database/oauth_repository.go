@@ -0,0 +1,203 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/lib/pq"
+)
+
+// OAuthRepository handles database operations for OAuth clients and authorization codes
+type OAuthRepository struct {
+	db *sql.DB
+}
+
+// oauthClientColumns lists the oauth_clients columns in the order scanOAuthClient expects
+const oauthClientColumns = `id, name, secret_hash, redirect_uris, scopes, grant_types, created_at`
+
+// NewOAuthRepository creates a new OAuth repository
+func NewOAuthRepository(db *sql.DB) *OAuthRepository {
+	return &OAuthRepository{db: db}
+}
+
+// CreateOAuthClient registers a new client
+func (r *OAuthRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	query := `
+	INSERT INTO oauth_clients (id, name, secret_hash, redirect_uris, scopes, grant_types, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		client.ID,
+		client.Name,
+		client.SecretHash,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.Scopes),
+		pq.Array(client.GrantTypes),
+		client.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create OAuth client: %w", err)
+	}
+
+	return nil
+}
+
+// GetOAuthClient retrieves a client by ID
+func (r *OAuthRepository) GetOAuthClient(ctx context.Context, id string) (*models.OAuthClient, error) {
+	query := `
+	SELECT ` + oauthClientColumns + `
+	FROM oauth_clients
+	WHERE id = $1
+	`
+
+	client, err := scanOAuthClient(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get OAuth client: %w", err)
+	}
+
+	return client, nil
+}
+
+// ListOAuthClients retrieves every registered client, oldest first
+func (r *OAuthRepository) ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error) {
+	query := `
+	SELECT ` + oauthClientColumns + `
+	FROM oauth_clients
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list OAuth clients: %w", err)
+	}
+	defer rows.Close()
+
+	var clients []models.OAuthClient
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan OAuth client: %w", err)
+		}
+		clients = append(clients, *client)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list OAuth clients: %w", err)
+	}
+
+	return clients, nil
+}
+
+// DeleteOAuthClient removes a client and, through the foreign key, its authorization
+// codes. It reports whether the client existed.
+func (r *OAuthRepository) DeleteOAuthClient(ctx context.Context, id string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM oauth_clients WHERE id = $1`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete OAuth client: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete OAuth client: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// scanOAuthClient reads a client selected with oauthClientColumns
+func scanOAuthClient(row rowScanner) (*models.OAuthClient, error) {
+	var client models.OAuthClient
+	err := row.Scan(
+		&client.ID,
+		&client.Name,
+		&client.SecretHash,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.Scopes),
+		pq.Array(&client.GrantTypes),
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// SaveAuthorizationCode stores a newly issued authorization code
+func (r *OAuthRepository) SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	query := `
+	INSERT INTO authorization_codes (code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+		code_challenge, code_challenge_method, auth_time, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err := r.db.ExecContext(ctx,
+		query,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.SessionID,
+		code.RedirectURI,
+		code.Scope,
+		code.Nonce,
+		code.CodeChallenge,
+		code.CodeChallengeMethod,
+		code.AuthTime,
+		code.ExpiresAt,
+		code.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save authorization code: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeAuthorizationCode atomically marks an unused code as consumed and returns
+// it. It returns nil if the code does not exist or has already been exchanged, so a
+// code can never be redeemed twice, even by concurrent requests.
+func (r *OAuthRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error) {
+	query := `
+	UPDATE authorization_codes
+	SET consumed_at = NOW()
+	WHERE code_hash = $1 AND consumed_at IS NULL
+	RETURNING code_hash, client_id, user_id, session_id, redirect_uri, scope, nonce,
+		code_challenge, code_challenge_method, auth_time, expires_at, consumed_at, created_at
+	`
+
+	var code models.AuthorizationCode
+	var consumedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, codeHash).Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.SessionID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.CodeChallengeMethod,
+		&code.AuthTime,
+		&code.ExpiresAt,
+		&consumedAt,
+		&code.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to consume authorization code: %w", err)
+	}
+
+	if consumedAt.Valid {
+		code.ConsumedAt = &consumedAt.Time
+	}
+
+	return &code, nil
+}
@@ -0,0 +1,288 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/lib/pq"
+)
+
+// RoleRepository handles database operations for roles and permissions
+type RoleRepository struct {
+	db *sql.DB
+}
+
+// NewRoleRepository creates a new role repository
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// roleQuery selects roles together with the names of their permissions
+const roleQuery = `
+	SELECT r.name, r.description, r.built_in, r.created_at,
+		COALESCE(array_agg(rp.permission_name ORDER BY rp.permission_name)
+			FILTER (WHERE rp.permission_name IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_name = r.name
+	`
+
+// scanRole reads a role selected with roleQuery
+func scanRole(row rowScanner) (*models.Role, error) {
+	var role models.Role
+	err := row.Scan(&role.Name, &role.Description, &role.BuiltIn, &role.CreatedAt, pq.Array(&role.Permissions))
+	if err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// ListPermissions retrieves every permission, sorted by name
+func (r *RoleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	query := `
+	SELECT name, description, created_at
+	FROM permissions
+	ORDER BY name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	var permissions []models.Permission
+	for rows.Next() {
+		var permission models.Permission
+		if err := rows.Scan(&permission.Name, &permission.Description, &permission.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan permission: %w", err)
+		}
+		permissions = append(permissions, permission)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// CreatePermission stores a new permission
+func (r *RoleRepository) CreatePermission(ctx context.Context, permission *models.Permission) error {
+	query := `
+	INSERT INTO permissions (name, description, created_at)
+	VALUES ($1, $2, $3)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, permission.Name, permission.Description, permission.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create permission: %w", err)
+	}
+
+	return nil
+}
+
+// DeletePermission removes a permission and revokes it from every role. It returns
+// false if the permission does not exist.
+func (r *RoleRepository) DeletePermission(ctx context.Context, name string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM permissions WHERE name = $1`, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete permission: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete permission: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ListRoles retrieves every role with its permissions, built-in roles first
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	query := roleQuery + `
+	GROUP BY r.name
+	ORDER BY r.built_in DESC, r.name
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roles []models.Role
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, *role)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+// GetRole retrieves a role with its permissions
+func (r *RoleRepository) GetRole(ctx context.Context, name models.UserRole) (*models.Role, error) {
+	query := roleQuery + `
+	WHERE r.name = $1
+	GROUP BY r.name
+	`
+
+	role, err := scanRole(r.db.QueryRowContext(ctx, query, name))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	return role, nil
+}
+
+// CreateRole stores a new role and its permissions in a single transaction
+func (r *RoleRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO roles (name, description, built_in, created_at)
+	VALUES ($1, $2, $3, $4)
+	`, role.Name, role.Description, role.BuiltIn, role.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	if err := insertRolePermissions(ctx, tx, role.Name, role.Permissions); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit role: %w", err)
+	}
+
+	return nil
+}
+
+// SetRolePermissions replaces the permissions granted to a role. It returns false
+// without changing anything if the role is the last one holding one of
+// models.AdminPermissions and the new permissions leave it out. The grants of the
+// admin permissions are locked first, so two roles losing them at the same time
+// cannot both succeed.
+func (r *RoleRepository) SetRolePermissions(ctx context.Context, name models.UserRole, permissions []string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT role_name, permission_name
+	FROM role_permissions
+	WHERE permission_name = ANY($1)
+	FOR UPDATE
+	`, pq.Array(models.AdminPermissions))
+	if err != nil {
+		return false, fmt.Errorf("failed to lock admin permissions: %w", err)
+	}
+
+	holders := make(map[string][]models.UserRole)
+	for rows.Next() {
+		var role models.UserRole
+		var permission string
+		if err := rows.Scan(&role, &permission); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan admin permission: %w", err)
+		}
+		holders[permission] = append(holders[permission], role)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to lock admin permissions: %w", err)
+	}
+
+	if removesLastAdminPermission(name, permissions, holders) {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM role_permissions WHERE role_name = $1`, name); err != nil {
+		return false, fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+
+	if err := insertRolePermissions(ctx, tx, name, permissions); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit role permissions: %w", err)
+	}
+
+	return true, nil
+}
+
+// removesLastAdminPermission reports whether replacing the permissions of a role
+// would take one of models.AdminPermissions away from the only role that holds it.
+// holders lists the roles currently granted each admin permission.
+func removesLastAdminPermission(name models.UserRole, permissions []string, holders map[string][]models.UserRole) bool {
+	for _, permission := range models.AdminPermissions {
+		kept := false
+		for _, p := range permissions {
+			kept = kept || p == permission
+		}
+		roles := holders[permission]
+		if !kept && len(roles) == 1 && roles[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// insertRolePermissions grants permissions to a role inside a transaction
+func insertRolePermissions(ctx context.Context, tx *sql.Tx, name models.UserRole, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `
+	INSERT INTO role_permissions (role_name, permission_name)
+	SELECT $1, unnest($2::text[])
+	ON CONFLICT DO NOTHING
+	`, name, pq.Array(permissions))
+	if err != nil {
+		return fmt.Errorf("failed to grant role permissions: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRole removes a custom role. Built-in roles and roles that are still
+// assigned to a user are never deleted; it returns false in that case or if the
+// role does not exist.
+func (r *RoleRepository) DeleteRole(ctx context.Context, name models.UserRole) (bool, error) {
+	query := `
+	DELETE FROM roles
+	WHERE name = $1 AND NOT built_in
+		AND NOT EXISTS (SELECT 1 FROM users WHERE role = $1)
+	`
+
+	result, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,260 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// SessionRepository handles database operations for sessions and refresh tokens
+type SessionRepository struct {
+	db *sql.DB
+}
+
+// NewSessionRepository creates a new session repository
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// SaveSession stores a session in the database
+func (r *SessionRepository) SaveSession(ctx context.Context, session *models.Session) error {
+	query := `
+	INSERT INTO sessions (id, user_id, expires_at, created_at)
+	VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.ExecContext(ctx, query, session.ID, session.UserID, session.ExpiresAt, session.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+
+	return nil
+}
+
+// GetSession retrieves a session by ID. It returns nil if the session does not exist.
+func (r *SessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	query := `
+	SELECT id, user_id, expires_at, created_at
+	FROM sessions
+	WHERE id = $1
+	`
+
+	var session models.Session
+	err := r.db.QueryRowContext(ctx, query, sessionID).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.ExpiresAt,
+		&session.CreatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return &session, nil
+}
+
+// DeleteSession removes a session
+func (r *SessionRepository) DeleteSession(ctx context.Context, sessionID string) error {
+	query := `
+	DELETE FROM sessions
+	WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteUserSessions removes every session belonging to a user
+func (r *SessionRepository) DeleteUserSessions(ctx context.Context, userID string) error {
+	query := `
+	DELETE FROM sessions
+	WHERE user_id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete user sessions: %w", err)
+	}
+
+	return nil
+}
+
+// CountActiveSessions returns the number of unexpired sessions per role of their user
+func (r *SessionRepository) CountActiveSessions(ctx context.Context) (map[models.UserRole]int, error) {
+	query := `
+	SELECT u.role, COUNT(*)
+	FROM sessions s
+	JOIN users u ON u.id = s.user_id
+	WHERE s.expires_at > $1
+	GROUP BY u.role
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+	defer rows.Close()
+
+	counts := make(map[models.UserRole]int)
+	for rows.Next() {
+		var role models.UserRole
+		var count int
+		if err := rows.Scan(&role, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan session count: %w", err)
+		}
+		counts[role] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+
+	return counts, nil
+}
+
+// SaveRefreshToken stores a newly issued refresh token
+func (r *SessionRepository) SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	query := `
+	INSERT INTO refresh_tokens (id, user_id, session_id, client_id, scope, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID,
+		token.UserID,
+		token.SessionID,
+		token.ClientID,
+		token.Scope,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	return nil
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value
+func (r *SessionRepository) GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	query := `
+	SELECT id, user_id, session_id, client_id, scope, token_hash, expires_at, rotated_at, revoked_at, created_at
+	FROM refresh_tokens
+	WHERE token_hash = $1
+	`
+
+	var token models.RefreshToken
+	var rotatedAt, revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.SessionID,
+		&token.ClientID,
+		&token.Scope,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&rotatedAt,
+		&revokedAt,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+
+	if rotatedAt.Valid {
+		token.RotatedAt = &rotatedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return &token, nil
+}
+
+// RotateRefreshToken marks the current token as used and stores its replacement in a
+// single transaction. It returns false without saving anything if the current token
+// was already rotated or revoked, which happens when two requests race on one token.
+func (r *SessionRepository) RotateRefreshToken(ctx context.Context, currentID string, next *models.RefreshToken) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+	UPDATE refresh_tokens
+	SET rotated_at = $1
+	WHERE id = $2 AND rotated_at IS NULL AND revoked_at IS NULL
+	`, time.Now(), currentID)
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to rotate refresh token: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	INSERT INTO refresh_tokens (id, user_id, session_id, client_id, scope, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`, next.ID, next.UserID, next.SessionID, next.ClientID, next.Scope, next.TokenHash, next.ExpiresAt, next.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to save refresh token: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily revokes every refresh token issued for a session
+func (r *SessionRepository) RevokeRefreshTokenFamily(ctx context.Context, sessionID string) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = $1
+	WHERE session_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), sessionID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every refresh token issued to a user
+func (r *SessionRepository) RevokeUserRefreshTokens(ctx context.Context, userID string) error {
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = $1
+	WHERE user_id = $2 AND revoked_at IS NULL
+	`
+
+	_, err := r.db.ExecContext(ctx, query, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	return nil
+}
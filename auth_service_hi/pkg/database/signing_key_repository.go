@@ -0,0 +1,174 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// SigningKeyRepository handles database operations for JWT signing keys
+type SigningKeyRepository struct {
+	db *sql.DB
+}
+
+// NewSigningKeyRepository creates a new signing key repository
+func NewSigningKeyRepository(db *sql.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// ListSigningKeys retrieves every stored signing key, oldest first
+func (r *SigningKeyRepository) ListSigningKeys() ([]models.SigningKeyRecord, error) {
+	query := `
+	SELECT id, algorithm, private_key_pem, status, created_at, retired_at
+	FROM signing_keys
+	ORDER BY created_at
+	`
+
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.SigningKeyRecord
+	for rows.Next() {
+		var key models.SigningKeyRecord
+		var retiredAt sql.NullTime
+		if err := rows.Scan(&key.ID, &key.Algorithm, &key.PrivateKeyPEM, &key.Status, &key.CreatedAt, &retiredAt); err != nil {
+			return nil, fmt.Errorf("failed to scan signing key: %w", err)
+		}
+		if retiredAt.Valid {
+			key.RetiredAt = &retiredAt.Time
+		}
+		keys = append(keys, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// CreateInitialSigningKey stores key as the active key unless another instance has
+// already created one. It returns whether the key was stored.
+func (r *SigningKeyRepository) CreateInitialSigningKey(key *models.SigningKeyRecord) (bool, error) {
+	query := `
+	INSERT INTO signing_keys (id, algorithm, private_key_pem, status, created_at)
+	SELECT $1, $2, $3, $4, $5
+	WHERE NOT EXISTS (SELECT 1 FROM signing_keys WHERE status = $4)
+	ON CONFLICT DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, key.ID, key.Algorithm, key.PrivateKeyPEM, models.SigningKeyActive, key.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to create signing key: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// CreatePendingSigningKey stores key as the pending key unless a rotation is already
+// pending. It returns whether the key was stored.
+func (r *SigningKeyRepository) CreatePendingSigningKey(key *models.SigningKeyRecord) (bool, error) {
+	query := `
+	INSERT INTO signing_keys (id, algorithm, private_key_pem, status, created_at)
+	SELECT $1, $2, $3, $4, $5
+	WHERE NOT EXISTS (SELECT 1 FROM signing_keys WHERE status = $4)
+	ON CONFLICT DO NOTHING
+	`
+
+	result, err := r.db.Exec(query, key.ID, key.Algorithm, key.PrivateKeyPEM, models.SigningKeyPending, key.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create pending signing key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to create pending signing key: %w", err)
+	}
+
+	return rows > 0, nil
+}
+
+// ActivateSigningKey retires the active key and makes the pending key with the given
+// ID active in a single transaction. It returns false if that key is no longer
+// pending, e.g. because another instance activated it first.
+func (r *SigningKeyRepository) ActivateSigningKey(id string) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+	UPDATE signing_keys
+	SET status = $1, retired_at = $2
+	WHERE status = $3
+	`, models.SigningKeyRetired, time.Now(), models.SigningKeyActive)
+	if err != nil {
+		return false, fmt.Errorf("failed to retire signing key: %w", err)
+	}
+
+	result, err := tx.Exec(`
+	UPDATE signing_keys
+	SET status = $1
+	WHERE id = $2 AND status = $3
+	`, models.SigningKeyActive, id, models.SigningKeyPending)
+	if err != nil {
+		return false, fmt.Errorf("failed to activate signing key: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to activate signing key: %w", err)
+	}
+	if rows == 0 {
+		return false, nil
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit signing key rotation: %w", err)
+	}
+
+	return true, nil
+}
+
+// ReplaceSigningKeyPEM overwrites the stored private key of a key, e.g. with its
+// encrypted form, unless it no longer holds current because another instance
+// replaced it first
+func (r *SigningKeyRepository) ReplaceSigningKeyPEM(id, current, replacement string) error {
+	query := `
+	UPDATE signing_keys
+	SET private_key_pem = $1
+	WHERE id = $2 AND private_key_pem = $3
+	`
+
+	if _, err := r.db.Exec(query, replacement, id, current); err != nil {
+		return fmt.Errorf("failed to update signing key: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteRetiredSigningKeys removes keys retired before the given time
+func (r *SigningKeyRepository) DeleteRetiredSigningKeys(before time.Time) (int64, error) {
+	query := `
+	DELETE FROM signing_keys
+	WHERE status = $1 AND retired_at < $2
+	`
+
+	result, err := r.db.Exec(query, models.SigningKeyRetired, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete retired signing keys: %w", err)
+	}
+
+	return result.RowsAffected()
+}
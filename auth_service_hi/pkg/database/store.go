@@ -0,0 +1,107 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// UserStore persists users and the single-use verification tokens issued to them. The
+// role and disabled updates never leave the service without an active admin.
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id string) (*models.User, error)
+	UpdateVerificationStatus(ctx context.Context, userID string, emailVerified, phoneVerified bool) error
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	UpdateMFA(ctx context.Context, userID, secret string, enabled bool) error
+	UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error)
+
+	ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, int, error)
+	UpdateUserRole(ctx context.Context, userID string, role models.UserRole) (bool, error)
+	SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error)
+
+	RecordFailedLogin(ctx context.Context, userID string) (int, int, error)
+	LockUser(ctx context.Context, lockout *models.AccountLockout, threshold int) (bool, error)
+	ResetFailedLogins(ctx context.Context, userID string) error
+	UnlockUser(ctx context.Context, userID, unlockedBy string) (bool, error)
+	ListLockouts(ctx context.Context, userID string) ([]models.AccountLockout, error)
+
+	SaveVerificationToken(ctx context.Context, token *models.VerificationToken) error
+	ConsumeVerificationToken(ctx context.Context, tokenHash string, purpose models.VerificationPurpose) (string, error)
+	CountVerificationTokensSince(ctx context.Context, userID string, purpose models.VerificationPurpose, since time.Time) (int, error)
+	GetActiveVerificationToken(ctx context.Context, userID string, purpose models.VerificationPurpose) (*models.VerificationToken, error)
+	IncrementVerificationAttempts(ctx context.Context, tokenID string) error
+	ConsumeVerificationTokenByID(ctx context.Context, tokenID string) (bool, error)
+}
+
+// SessionStore persists login sessions and their refresh tokens
+type SessionStore interface {
+	SaveSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, sessionID string) (*models.Session, error)
+	DeleteSession(ctx context.Context, sessionID string) error
+	DeleteUserSessions(ctx context.Context, userID string) error
+	CountActiveSessions(ctx context.Context) (map[models.UserRole]int, error)
+
+	SaveRefreshToken(ctx context.Context, token *models.RefreshToken) error
+	GetRefreshTokenByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, currentID string, next *models.RefreshToken) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, sessionID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID string) error
+}
+
+// OAuthStore persists OpenID Connect clients and the authorization codes issued to them
+type OAuthStore interface {
+	CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error
+	GetOAuthClient(ctx context.Context, id string) (*models.OAuthClient, error)
+	ListOAuthClients(ctx context.Context) ([]models.OAuthClient, error)
+	DeleteOAuthClient(ctx context.Context, id string) (bool, error)
+
+	SaveAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string) (*models.AuthorizationCode, error)
+}
+
+// RoleStore persists roles, permissions and the permissions granted to each role
+type RoleStore interface {
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	CreatePermission(ctx context.Context, permission *models.Permission) error
+	DeletePermission(ctx context.Context, name string) (bool, error)
+
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	GetRole(ctx context.Context, name models.UserRole) (*models.Role, error)
+	CreateRole(ctx context.Context, role *models.Role) error
+	SetRolePermissions(ctx context.Context, name models.UserRole, permissions []string) (bool, error)
+	DeleteRole(ctx context.Context, name models.UserRole) (bool, error)
+}
+
+// InvitationStore persists invitations to sign up with a privileged role
+type InvitationStore interface {
+	CreateInvitation(ctx context.Context, invitation *models.Invitation) error
+	GetInvitationByHash(ctx context.Context, tokenHash string) (*models.Invitation, error)
+	ListInvitations(ctx context.Context) ([]models.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) (bool, error)
+	CreateUserWithInvitation(ctx context.Context, user *models.User, invitationID string) (bool, error)
+}
+
+// AuditStore persists the append-only audit log
+type AuditStore interface {
+	InsertAuthEvents(ctx context.Context, events []models.AuthEvent) error
+	ListAuthEvents(ctx context.Context, filter models.AuthEventFilter) ([]models.AuthEvent, int, error)
+}
+
+// Compile-time checks that both implementations satisfy the interfaces
+var (
+	_ UserStore       = (*UserRepository)(nil)
+	_ SessionStore    = (*SessionRepository)(nil)
+	_ OAuthStore      = (*OAuthRepository)(nil)
+	_ RoleStore       = (*RoleRepository)(nil)
+	_ InvitationStore = (*UserRepository)(nil)
+	_ AuditStore      = (*AuditRepository)(nil)
+	_ UserStore       = (*MemoryStore)(nil)
+	_ SessionStore    = (*MemoryStore)(nil)
+	_ OAuthStore      = (*MemoryStore)(nil)
+	_ RoleStore       = (*MemoryStore)(nil)
+	_ InvitationStore = (*MemoryStore)(nil)
+	_ AuditStore      = (*MemoryStore)(nil)
+)
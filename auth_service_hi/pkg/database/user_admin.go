@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// ListUsers returns one page of the users matching the filter, newest first, and the
+// total number of matches
+func (r *UserRepository) ListUsers(ctx context.Context, filter models.UserFilter) ([]models.User, int, error) {
+	var conditions []string
+	var args []interface{}
+	addCondition := func(condition string, arg interface{}) {
+		args = append(args, arg)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.Role != "" {
+		addCondition("role = $%d", filter.Role)
+	}
+	if filter.EmailVerified != nil {
+		addCondition("email_verified = $%d", *filter.EmailVerified)
+	}
+	if filter.Disabled != nil {
+		if *filter.Disabled {
+			conditions = append(conditions, "disabled_at IS NOT NULL")
+		} else {
+			conditions = append(conditions, "disabled_at IS NULL")
+		}
+	}
+	if !filter.CreatedAfter.IsZero() {
+		addCondition("created_at >= $%d", filter.CreatedAfter)
+	}
+	if !filter.CreatedBefore.IsZero() {
+		addCondition("created_at < $%d", filter.CreatedBefore)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM users `+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	query := `
+	SELECT ` + userColumns + `
+	FROM users
+	` + where + `
+	ORDER BY created_at DESC, id
+	LIMIT $` + fmt.Sprint(len(args)+1) + ` OFFSET $` + fmt.Sprint(len(args)+2)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, *user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, total, nil
+}
+
+// UpdateUserRole changes a user's role. It returns false without changing anything if
+// the user is the last active admin and the new role is not admin.
+func (r *UserRepository) UpdateUserRole(ctx context.Context, userID string, role models.UserRole) (bool, error) {
+	return r.updateGuardingLastAdmin(ctx, userID, role == models.RoleAdmin, `
+	UPDATE users
+	SET role = $1, updated_at = $2
+	WHERE id = $3
+	`, role, time.Now(), userID)
+}
+
+// SetUserDisabled disables or re-enables a user's account. It returns false without
+// changing anything if disabling would leave no active admin.
+func (r *UserRepository) SetUserDisabled(ctx context.Context, userID string, disabled bool) (bool, error) {
+	// Disabling an already disabled account keeps the original timestamp
+	return r.updateGuardingLastAdmin(ctx, userID, !disabled, `
+	UPDATE users
+	SET disabled_at = CASE WHEN $1 THEN COALESCE(disabled_at, $2) ELSE NULL END, updated_at = $2
+	WHERE id = $3
+	`, disabled, time.Now(), userID)
+}
+
+// updateGuardingLastAdmin runs an update of a single user unless the user is an
+// active admin, the update leaves them without admin rights (stillAdmin is false),
+// and no other active admin exists. The rows of all active admins are locked first,
+// so two admins demoting each other at the same time cannot both succeed.
+func (r *UserRepository) updateGuardingLastAdmin(ctx context.Context, userID string, stillAdmin bool, query string, args ...interface{}) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+	SELECT id
+	FROM users
+	WHERE role = $1 AND disabled_at IS NULL
+	FOR UPDATE
+	`, models.RoleAdmin)
+	if err != nil {
+		return false, fmt.Errorf("failed to lock admins: %w", err)
+	}
+
+	admins := 0
+	targetIsAdmin := false
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return false, fmt.Errorf("failed to scan admin: %w", err)
+		}
+		admins++
+		if id == userID {
+			targetIsAdmin = true
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to lock admins: %w", err)
+	}
+
+	if targetIsAdmin && !stillAdmin && admins <= 1 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return false, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit user update: %w", err)
+	}
+
+	return true, nil
+}
@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"time"
@@ -8,6 +9,53 @@ import (
 	"github.com/herb-immortal/auth_service_hi/pkg/models"
 )
 
+// userColumns lists the users columns in the order scanUser expects them
+const userColumns = `id, email, password_hash, mfa_secret, mfa_enabled, mfa_last_step, phone_number, name, role, email_verified, phone_verified, disabled_at, created_at, updated_at, failed_login_count, last_failed_login_at, lockout_count, locked_until`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser reads a single user selected with userColumns
+func scanUser(row rowScanner) (*models.User, error) {
+	var user models.User
+	var disabledAt, lastFailedLoginAt, lockedUntil sql.NullTime
+	err := row.Scan(
+		&user.ID,
+		&user.Email,
+		&user.PasswordHash,
+		&user.MFASecret,
+		&user.MFAEnabled,
+		&user.MFALastStep,
+		&user.PhoneNumber,
+		&user.Name,
+		&user.Role,
+		&user.EmailVerified,
+		&user.PhoneVerified,
+		&disabledAt,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+		&user.FailedLoginCount,
+		&lastFailedLoginAt,
+		&user.LockoutCount,
+		&lockedUntil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if disabledAt.Valid {
+		user.DisabledAt = &disabledAt.Time
+	}
+	if lastFailedLoginAt.Valid {
+		user.LastFailedLoginAt = &lastFailedLoginAt.Time
+	}
+	if lockedUntil.Valid {
+		user.LockedUntil = &lockedUntil.Time
+	}
+	return &user, nil
+}
+
 // UserRepository handles database operations for users
 type UserRepository struct {
 	db *sql.DB
@@ -18,14 +66,15 @@ func NewUserRepository(db *sql.DB) *UserRepository {
 	return &UserRepository{db: db}
 }
 
-// CreateUser creates a new user in the database
-func (r *UserRepository) CreateUser(user *models.User) error {
-	query := `
-	INSERT INTO users (id, email, password_hash, mfa_secret, phone_number, name, role, created_at, updated_at)
-	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+// insertUserQuery inserts a user with the arguments returned by insertUserArgs
+const insertUserQuery = `
+	INSERT INTO users (id, email, password_hash, mfa_secret, phone_number, name, role, email_verified, phone_verified, created_at, updated_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
-	_, err := r.db.Exec(query,
+// insertUserArgs returns the arguments for insertUserQuery
+func insertUserArgs(user *models.User) []interface{} {
+	return []interface{}{
 		user.ID,
 		user.Email,
 		user.PasswordHash,
@@ -33,10 +82,16 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 		user.PhoneNumber,
 		user.Name,
 		user.Role,
+		user.EmailVerified,
+		user.PhoneVerified,
 		user.CreatedAt,
 		user.UpdatedAt,
-	)
+	}
+}
 
+// CreateUser creates a new user in the database
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) error {
+	_, err := r.db.ExecContext(ctx, insertUserQuery, insertUserArgs(user)...)
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -45,28 +100,14 @@ func (r *UserRepository) CreateUser(user *models.User) error {
 }
 
 // GetUserByEmail retrieves a user by their email address
-func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
-	SELECT id, email, password_hash, mfa_secret, phone_number, name, role, email_verified, phone_verified, created_at, updated_at
+	SELECT ` + userColumns + `
 	FROM users
 	WHERE email = $1
 	`
 
-	var user models.User
-	err := r.db.QueryRow(query, email).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.MFASecret,
-		&user.PhoneNumber,
-		&user.Name,
-		&user.Role,
-		&user.EmailVerified,
-		&user.PhoneVerified,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, email))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -74,32 +115,18 @@ func (r *UserRepository) GetUserByEmail(email string) (*models.User, error) {
 		return nil, fmt.Errorf("failed to get user by email: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // GetUserByID retrieves a user by their ID
-func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
+func (r *UserRepository) GetUserByID(ctx context.Context, id string) (*models.User, error) {
 	query := `
-	SELECT id, email, password_hash, mfa_secret, phone_number, name, role, email_verified, phone_verified, created_at, updated_at
+	SELECT ` + userColumns + `
 	FROM users
 	WHERE id = $1
 	`
 
-	var user models.User
-	err := r.db.QueryRow(query, id).Scan(
-		&user.ID,
-		&user.Email,
-		&user.PasswordHash,
-		&user.MFASecret,
-		&user.PhoneNumber,
-		&user.Name,
-		&user.Role,
-		&user.EmailVerified,
-		&user.PhoneVerified,
-		&user.CreatedAt,
-		&user.UpdatedAt,
-	)
-
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -107,18 +134,18 @@ func (r *UserRepository) GetUserByID(id string) (*models.User, error) {
 		return nil, fmt.Errorf("failed to get user by ID: %w", err)
 	}
 
-	return &user, nil
+	return user, nil
 }
 
 // UpdateVerificationStatus updates the verification status of a user's email or phone
-func (r *UserRepository) UpdateVerificationStatus(userID string, emailVerified, phoneVerified bool) error {
+func (r *UserRepository) UpdateVerificationStatus(ctx context.Context, userID string, emailVerified, phoneVerified bool) error {
 	query := `
 	UPDATE users
 	SET email_verified = $1, phone_verified = $2, updated_at = $3
 	WHERE id = $4
 	`
 
-	_, err := r.db.Exec(query, emailVerified, phoneVerified, time.Now(), userID)
+	_, err := r.db.ExecContext(ctx, query, emailVerified, phoneVerified, time.Now(), userID)
 	if err != nil {
 		return fmt.Errorf("failed to update verification status: %w", err)
 	}
@@ -126,54 +153,58 @@ func (r *UserRepository) UpdateVerificationStatus(userID string, emailVerified,
 	return nil
 }
 
-// SaveSession stores a session in the database
-func (r *UserRepository) SaveSession(sessionID, userID string, expiresAt time.Time) error {
+// UpdatePassword replaces a user's password hash
+func (r *UserRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
 	query := `
-	INSERT INTO sessions (id, user_id, expires_at)
-	VALUES ($1, $2, $3)
+	UPDATE users
+	SET password_hash = $1, updated_at = $2
+	WHERE id = $3
 	`
 
-	_, err := r.db.Exec(query, sessionID, userID, expiresAt)
+	_, err := r.db.ExecContext(ctx, query, passwordHash, time.Now(), userID)
 	if err != nil {
-		return fmt.Errorf("failed to save session: %w", err)
+		return fmt.Errorf("failed to update password: %w", err)
 	}
 
 	return nil
 }
 
-// GetSession retrieves a session by ID
-func (r *UserRepository) GetSession(sessionID string) (string, time.Time, error) {
+// UpdateMFA stores a user's TOTP secret and whether enrollment has been confirmed
+func (r *UserRepository) UpdateMFA(ctx context.Context, userID, secret string, enabled bool) error {
 	query := `
-	SELECT user_id, expires_at
-	FROM sessions
-	WHERE id = $1
+	UPDATE users
+	SET mfa_secret = $1, mfa_enabled = $2, updated_at = $3
+	WHERE id = $4
 	`
 
-	var userID string
-	var expiresAt time.Time
-
-	err := r.db.QueryRow(query, sessionID).Scan(&userID, &expiresAt)
+	_, err := r.db.ExecContext(ctx, query, secret, enabled, time.Now(), userID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return "", time.Time{}, nil
-		}
-		return "", time.Time{}, fmt.Errorf("failed to get session: %w", err)
+		return fmt.Errorf("failed to update MFA settings: %w", err)
 	}
 
-	return userID, expiresAt, nil
+	return nil
 }
 
-// DeleteSession removes a session
-func (r *UserRepository) DeleteSession(sessionID string) error {
+// UseTOTPStep records that a TOTP code from the given time step was accepted. It
+// returns false if the step is not after the last one recorded, i.e. the code was
+// already used. The check and update are one statement, so of two concurrent
+// logins with the same code only one succeeds.
+func (r *UserRepository) UseTOTPStep(ctx context.Context, userID string, step int64) (bool, error) {
 	query := `
-	DELETE FROM sessions
-	WHERE id = $1
+	UPDATE users
+	SET mfa_last_step = $1
+	WHERE id = $2 AND mfa_last_step < $1
 	`
 
-	_, err := r.db.Exec(query, sessionID)
+	result, err := r.db.ExecContext(ctx, query, step, userID)
 	if err != nil {
-		return fmt.Errorf("failed to delete session: %w", err)
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
 	}
 
-	return nil
-}
\ No newline at end of file
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record TOTP step: %w", err)
+	}
+
+	return rows > 0, nil
+}
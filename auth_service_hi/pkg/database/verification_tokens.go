@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// SaveVerificationToken stores a newly issued verification token
+func (r *UserRepository) SaveVerificationToken(ctx context.Context, token *models.VerificationToken) error {
+	query := `
+	INSERT INTO verification_tokens (id, user_id, purpose, token_hash, expires_at, created_at)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		token.ID,
+		token.UserID,
+		token.Purpose,
+		token.TokenHash,
+		token.ExpiresAt,
+		token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save verification token: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeVerificationToken marks an unexpired, unused token as consumed and returns
+// the ID of the user it was issued to. It returns an empty ID if no such token exists.
+// The update is a single statement, so a token can never be consumed twice.
+func (r *UserRepository) ConsumeVerificationToken(ctx context.Context, tokenHash string, purpose models.VerificationPurpose) (string, error) {
+	query := `
+	UPDATE verification_tokens
+	SET consumed_at = $1
+	WHERE token_hash = $2 AND purpose = $3 AND consumed_at IS NULL AND expires_at > $1
+	RETURNING user_id
+	`
+
+	var userID string
+	err := r.db.QueryRowContext(ctx, query, time.Now(), tokenHash, purpose).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to consume verification token: %w", err)
+	}
+
+	return userID, nil
+}
+
+// CountVerificationTokensSince counts the tokens issued to a user for a purpose since the given time
+func (r *UserRepository) CountVerificationTokensSince(ctx context.Context, userID string, purpose models.VerificationPurpose, since time.Time) (int, error) {
+	query := `
+	SELECT COUNT(*)
+	FROM verification_tokens
+	WHERE user_id = $1 AND purpose = $2 AND created_at >= $3
+	`
+
+	var count int
+	err := r.db.QueryRowContext(ctx, query, userID, purpose, since).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count verification tokens: %w", err)
+	}
+
+	return count, nil
+}
+
+// GetActiveVerificationToken retrieves the most recent unexpired, unused token issued
+// to a user for a purpose
+func (r *UserRepository) GetActiveVerificationToken(ctx context.Context, userID string, purpose models.VerificationPurpose) (*models.VerificationToken, error) {
+	query := `
+	SELECT id, user_id, purpose, token_hash, expires_at, attempts, created_at
+	FROM verification_tokens
+	WHERE user_id = $1 AND purpose = $2 AND consumed_at IS NULL AND expires_at > $3
+	ORDER BY created_at DESC
+	LIMIT 1
+	`
+
+	var token models.VerificationToken
+	err := r.db.QueryRowContext(ctx, query, userID, purpose, time.Now()).Scan(
+		&token.ID,
+		&token.UserID,
+		&token.Purpose,
+		&token.TokenHash,
+		&token.ExpiresAt,
+		&token.Attempts,
+		&token.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get verification token: %w", err)
+	}
+
+	return &token, nil
+}
+
+// IncrementVerificationAttempts records a failed attempt to use a token
+func (r *UserRepository) IncrementVerificationAttempts(ctx context.Context, tokenID string) error {
+	query := `
+	UPDATE verification_tokens
+	SET attempts = attempts + 1
+	WHERE id = $1
+	`
+
+	_, err := r.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return fmt.Errorf("failed to update verification attempts: %w", err)
+	}
+
+	return nil
+}
+
+// ConsumeVerificationTokenByID marks a token as consumed. It returns false if the
+// token had already been consumed.
+func (r *UserRepository) ConsumeVerificationTokenByID(ctx context.Context, tokenID string) (bool, error) {
+	query := `
+	UPDATE verification_tokens
+	SET consumed_at = $1
+	WHERE id = $2 AND consumed_at IS NULL
+	`
+
+	result, err := r.db.ExecContext(ctx, query, time.Now(), tokenID)
+	if err != nil {
+		return false, fmt.Errorf("failed to consume verification token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to consume verification token: %w", err)
+	}
+
+	return rows > 0, nil
+}
@@ -0,0 +1,156 @@
+// Package health serves liveness and readiness probes. /healthz only says the
+// process is up and serving; /readyz runs the registered dependency checks, each
+// under a timeout, and reports every check so a failing probe says which
+// dependency is at fault. Readiness also fails once the server starts draining for
+// shutdown, so load balancers stop sending new requests before it stops accepting
+// them.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Statuses reported for the service and for each check
+const (
+	StatusOK       = "ok"
+	StatusFailing  = "failing"
+	StatusDraining = "draining"
+)
+
+// Check reports whether a dependency is usable. It must return once ctx is done.
+type Check func(ctx context.Context) error
+
+// CheckResult is the outcome of one check. Probes are unauthenticated, so Error only
+// says how a check failed; the underlying error, which can name hosts, users or
+// files, is logged instead.
+type CheckResult struct {
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// Report is the body of a probe response
+type Report struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker runs the readiness checks
+type Checker struct {
+	timeout  time.Duration
+	checks   []namedCheck
+	draining atomic.Bool
+}
+
+// NewChecker creates a checker that gives each check timeout to finish
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Add registers a readiness check under name. Checks must all be added before the
+// handlers are served.
+func (c *Checker) Add(name string, check Check) {
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Drain makes readiness fail from now on; call it when shutdown begins
+func (c *Checker) Drain() {
+	c.draining.Store(true)
+}
+
+// Ready runs every check concurrently and reports whether all of them passed
+func (c *Checker) Ready(ctx context.Context) (*Report, bool) {
+	if c.draining.Load() {
+		return &Report{Status: StatusDraining}, false
+	}
+
+	results := make([]CheckResult, len(c.checks))
+	var wg sync.WaitGroup
+	for i, nc := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = c.run(ctx, nc)
+		}()
+	}
+	wg.Wait()
+
+	report := &Report{Status: StatusOK, Checks: make(map[string]CheckResult, len(c.checks))}
+	for i, nc := range c.checks {
+		report.Checks[nc.name] = results[i]
+		if results[i].Status != StatusOK {
+			report.Status = StatusFailing
+		}
+	}
+	return report, report.Status == StatusOK
+}
+
+// run runs one check under the timeout
+func (c *Checker) run(ctx context.Context, nc namedCheck) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := nc.check(ctx)
+	result := CheckResult{Status: StatusOK, DurationMS: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Status = StatusFailing
+		result.Error = "check failed"
+		if errors.Is(err, context.DeadlineExceeded) || ctx.Err() == context.DeadlineExceeded {
+			result.Error = "timed out after " + c.timeout.String()
+		}
+		slog.WarnContext(ctx, "Readiness check failed", "check", nc.name, "error", err)
+	}
+	return result
+}
+
+// LiveHandler answers the liveness probe. It succeeds whenever the process can
+// serve requests, including while draining, so the orchestrator does not restart
+// an instance that is shutting down or waiting on a dependency.
+func (c *Checker) LiveHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(w, r, http.StatusOK, &Report{Status: StatusOK})
+	})
+}
+
+// ReadyHandler answers the readiness probe with 200 when every check passes and
+// 503 otherwise
+func (c *Checker) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, ready := c.Ready(r.Context())
+		status := http.StatusOK
+		if !ready {
+			status = http.StatusServiceUnavailable
+		}
+		respond(w, r, status, report)
+	})
+}
+
+// respond writes a probe response. Probes must never be cached.
+func respond(w http.ResponseWriter, r *http.Request, status int, report *Report) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, _ := json.Marshal(report)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if r.Method == http.MethodGet {
+		w.Write(body)
+	}
+}
@@ -0,0 +1,56 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadyHandler(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Add("ok", func(ctx context.Context) error { return nil })
+	checker.Add("database", func(ctx context.Context) error {
+		return errors.New(`pq: password authentication failed for user "auth" at db.internal:5432`)
+	})
+	checker.Add("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	w := httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", w.Code, http.StatusServiceUnavailable)
+	}
+	if body := w.Body.String(); strings.Contains(body, "db.internal") || strings.Contains(body, "password") {
+		t.Errorf("response exposes the check error: %s", body)
+	}
+
+	var report Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]CheckResult{
+		"ok":       {Status: StatusOK},
+		"database": {Status: StatusFailing, Error: "check failed"},
+		"slow":     {Status: StatusFailing, Error: "timed out after 10ms"},
+	}
+	for name, result := range want {
+		got := report.Checks[name]
+		if got.Status != result.Status || got.Error != result.Error {
+			t.Errorf("check %s = %+v, want status %q and error %q", name, got, result.Status, result.Error)
+		}
+	}
+
+	checker.Drain()
+	w = httptest.NewRecorder()
+	checker.ReadyHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), StatusDraining) {
+		t.Errorf("while draining: status = %d, body %s", w.Code, w.Body.String())
+	}
+}
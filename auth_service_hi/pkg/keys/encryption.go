@@ -0,0 +1,73 @@
+package keys
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// encryptedPrefix marks a private key sealed with the key encryption key. Values
+// without it are plaintext PEM, as stored before encryption was configured.
+const encryptedPrefix = "enc:v1:"
+
+// KeyEncryptionKeySize is the length of the AES-256 key encryption key in bytes
+const KeyEncryptionKeySize = 32
+
+// ErrNoKeyEncryptionKey is returned when the store holds encrypted keys but no key
+// encryption key is configured
+var ErrNoKeyEncryptionKey = errors.New("signing keys are encrypted but no key encryption key is configured")
+
+// sealer encrypts private keys at rest with AES-256-GCM. The key ID is authenticated
+// with each key, so a ciphertext copied to another row does not decrypt.
+type sealer struct {
+	aead cipher.AEAD
+}
+
+// newSealer creates a sealer from a KeyEncryptionKeySize-byte key
+func newSealer(kek []byte) (*sealer, error) {
+	if len(kek) != KeyEncryptionKeySize {
+		return nil, fmt.Errorf("key encryption key must be %d bytes, got %d", KeyEncryptionKeySize, len(kek))
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &sealer{aead: aead}, nil
+}
+
+// seal encrypts the PEM of the key with the given ID
+func (s *sealer) seal(id string, pemData []byte) (string, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := s.aead.Seal(nonce, nonce, pemData, []byte(id))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// open decrypts a value produced by seal for the key with the given ID
+func (s *sealer) open(id, value string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < s.aead.NonceSize() {
+		return nil, errors.New("malformed encrypted signing key")
+	}
+	nonce, ciphertext := sealed[:s.aead.NonceSize()], sealed[s.aead.NonceSize():]
+	pemData, err := s.aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return nil, errors.New("failed to decrypt signing key; is the key encryption key correct?")
+	}
+	return pemData, nil
+}
+
+// isEncrypted reports whether a stored private key was sealed
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix)
+}
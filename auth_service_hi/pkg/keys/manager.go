@@ -0,0 +1,353 @@
+package keys
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+// Store persists signing keys so that every instance of the service signs with the
+// same active key and can verify tokens signed by any other instance
+type Store interface {
+	ListSigningKeys() ([]models.SigningKeyRecord, error)
+	CreateInitialSigningKey(key *models.SigningKeyRecord) (bool, error)
+	CreatePendingSigningKey(key *models.SigningKeyRecord) (bool, error)
+	ActivateSigningKey(id string) (bool, error)
+	ReplaceSigningKeyPEM(id, current, replacement string) error
+	DeleteRetiredSigningKeys(before time.Time) (int64, error)
+}
+
+var (
+	// ErrNoActiveKey is returned when the store holds no active signing key
+	ErrNoActiveKey = errors.New("no active signing key")
+	// ErrRotationPending is returned by Rotate while an earlier rotation waits to activate
+	ErrRotationPending = errors.New("a signing key rotation is already pending")
+)
+
+// reloadInterval limits how often a token with an unknown kid reloads the ring
+const reloadInterval = 10 * time.Second
+
+// Manager keeps a utils.KeyRing in sync with the keys in a Store. A rotation first
+// publishes the new key as pending, so that it only verifies, and activates it once
+// activationDelay has passed: by then every instance and every verifier caching the
+// JWKS knows the key, and tokens it signs are accepted everywhere. Retired keys stay
+// in the ring for verifyWindow after rotation, which should be at least the access
+// token lifetime so that tokens signed just before a rotation remain valid.
+type Manager struct {
+	store           Store
+	algorithm       string
+	verifyWindow    time.Duration
+	activationDelay time.Duration
+
+	// Encryption of private keys at rest; see encryption.go
+	sealer    *sealer
+	sealerErr error
+
+	mu          sync.Mutex
+	ring        *utils.KeyRing
+	refreshedAt time.Time // last successful Refresh
+}
+
+// Option configures optional Manager features
+type Option func(*Manager)
+
+// WithKeyEncryptionKey encrypts private keys with AES-256-GCM under kek, which must
+// be KeyEncryptionKeySize bytes, before they are stored. Keys stored in plaintext
+// before are encrypted by the next Load.
+func WithKeyEncryptionKey(kek []byte) Option {
+	return func(m *Manager) {
+		m.sealer, m.sealerErr = newSealer(kek)
+	}
+}
+
+// NewManager creates a key manager that generates keys for the given JWS algorithm
+func NewManager(store Store, algorithm string, verifyWindow, activationDelay time.Duration, opts ...Option) *Manager {
+	m := &Manager{
+		store:           store,
+		algorithm:       algorithm,
+		verifyWindow:    verifyWindow,
+		activationDelay: activationDelay,
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Load creates the first active key if the store has none, encrypts keys stored in
+// plaintext if a key encryption key is configured, activates a pending key that is
+// due, then loads the store into a key ring. The returned ring is updated in place by
+// Refresh and Rotate, and reloads itself when asked for an unknown key.
+func (m *Manager) Load() (*utils.KeyRing, error) {
+	if m.sealerErr != nil {
+		return nil, m.sealerErr
+	}
+
+	records, err := m.store.ListSigningKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.encryptStored(records); err != nil {
+		return nil, err
+	}
+
+	if findRecord(records, models.SigningKeyActive) == nil {
+		record, err := m.generate(models.SigningKeyActive)
+		if err != nil {
+			return nil, err
+		}
+		created, err := m.store.CreateInitialSigningKey(record)
+		if err != nil {
+			return nil, err
+		}
+		if created {
+			slog.Info("Created signing key", "kid", record.ID, "alg", record.Algorithm)
+		}
+	}
+
+	if _, err := m.Activate(); err != nil {
+		return nil, err
+	}
+
+	if err := m.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return m.Ring(), nil
+}
+
+// Ring returns the key ring, or nil before the first successful Load
+func (m *Manager) Ring() *utils.KeyRing {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ring
+}
+
+// Refresh reloads the key ring from the store, picking up rotations made by other
+// instances and dropping retired keys whose verification window has passed. Pending
+// keys are loaded to verify only.
+func (m *Manager) Refresh() error {
+	records, err := m.store.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	var active *utils.SigningKey
+	var verifyOnly []*utils.SigningKey
+	cutoff := time.Now().Add(-m.verifyWindow)
+
+	for _, record := range records {
+		if record.Status == models.SigningKeyRetired && record.RetiredAt != nil && record.RetiredAt.Before(cutoff) {
+			continue
+		}
+
+		pemData, err := m.privateKeyPEM(record)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", record.ID, err)
+		}
+		key, err := utils.ParseSigningKeyPEM(record.ID, pemData)
+		if err != nil {
+			return fmt.Errorf("failed to load signing key %s: %w", record.ID, err)
+		}
+
+		if record.Status == models.SigningKeyActive {
+			active = key
+		} else {
+			verifyOnly = append(verifyOnly, key)
+		}
+	}
+
+	if active == nil {
+		return ErrNoActiveKey
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.ring == nil {
+		m.ring = utils.NewKeyRing(active, verifyOnly...)
+		m.ring.SetReloader(m.Refresh, reloadInterval)
+	} else {
+		m.ring.Replace(active, verifyOnly)
+	}
+	m.refreshedAt = time.Now()
+
+	return nil
+}
+
+// CheckFresh returns an error unless the key ring was refreshed from the store
+// within maxAge. A ring that has gone stale misses rotations made by other
+// instances, so it may sign with a retired key and reject tokens signed with the
+// new one.
+func (m *Manager) CheckFresh(maxAge time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ring == nil {
+		return errors.New("signing keys have not been loaded")
+	}
+	if age := time.Since(m.refreshedAt); age > maxAge {
+		return fmt.Errorf("signing keys were last refreshed %s ago", age.Round(time.Second))
+	}
+	return nil
+}
+
+// Rotate generates a new key and publishes it as pending. It returns the key and the
+// time from which Activate makes it the active key, retiring the current one.
+func (m *Manager) Rotate() (*utils.SigningKey, time.Time, error) {
+	record, err := m.generate(models.SigningKeyPending)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	created, err := m.store.CreatePendingSigningKey(record)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !created {
+		return nil, time.Time{}, ErrRotationPending
+	}
+
+	if err := m.Refresh(); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return m.Ring().Key(record.ID), record.CreatedAt.Add(m.activationDelay), nil
+}
+
+// Activate makes the pending key active, retiring the current one, once it has been
+// published for the activation delay. The retired key keeps verifying tokens until
+// its verification window has passed. It returns whether a key was activated.
+func (m *Manager) Activate() (bool, error) {
+	records, err := m.store.ListSigningKeys()
+	if err != nil {
+		return false, err
+	}
+
+	pending := findRecord(records, models.SigningKeyPending)
+	if pending == nil || time.Since(pending.CreatedAt) < m.activationDelay {
+		return false, nil
+	}
+
+	activated, err := m.store.ActivateSigningKey(pending.ID)
+	if err != nil {
+		return false, err
+	}
+	if activated {
+		slog.Info("Activated signing key", "kid", pending.ID, "alg", pending.Algorithm)
+	}
+	return activated, nil
+}
+
+// Keys returns every stored key, oldest first
+func (m *Manager) Keys() ([]models.SigningKeyRecord, error) {
+	return m.store.ListSigningKeys()
+}
+
+// Prune deletes retired keys whose verification window has passed
+func (m *Manager) Prune() (int64, error) {
+	return m.store.DeleteRetiredSigningKeys(time.Now().Add(-m.verifyWindow))
+}
+
+// Run activates a pending key when it is due, refreshes the key ring and prunes
+// expired keys every interval until ctx is cancelled
+func (m *Manager) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := m.Activate(); err != nil {
+				slog.ErrorContext(ctx, "Failed to activate signing key", "error", err)
+			}
+			if err := m.Refresh(); err != nil {
+				slog.ErrorContext(ctx, "Failed to refresh signing keys", "error", err)
+				continue
+			}
+			if _, err := m.Prune(); err != nil {
+				slog.ErrorContext(ctx, "Failed to prune signing keys", "error", err)
+			}
+		}
+	}
+}
+
+// generate creates a new key with the given status, ready to be stored
+func (m *Manager) generate(status models.SigningKeyStatus) (*models.SigningKeyRecord, error) {
+	key, err := utils.GenerateSigningKey(m.algorithm)
+	if err != nil {
+		return nil, err
+	}
+
+	pemData, err := key.MarshalPEM()
+	if err != nil {
+		return nil, err
+	}
+
+	stored := string(pemData)
+	if m.sealer != nil {
+		if stored, err = m.sealer.seal(key.ID, pemData); err != nil {
+			return nil, err
+		}
+	}
+
+	return &models.SigningKeyRecord{
+		ID:            key.ID,
+		Algorithm:     key.Method.Alg(),
+		PrivateKeyPEM: stored,
+		Status:        status,
+		CreatedAt:     time.Now(),
+	}, nil
+}
+
+// privateKeyPEM returns the PEM of a stored key, decrypting it if it was sealed
+func (m *Manager) privateKeyPEM(record models.SigningKeyRecord) ([]byte, error) {
+	if !isEncrypted(record.PrivateKeyPEM) {
+		return []byte(record.PrivateKeyPEM), nil
+	}
+	if m.sealer == nil {
+		return nil, ErrNoKeyEncryptionKey
+	}
+	return m.sealer.open(record.ID, record.PrivateKeyPEM)
+}
+
+// encryptStored seals keys that were stored in plaintext before a key encryption key
+// was configured
+func (m *Manager) encryptStored(records []models.SigningKeyRecord) error {
+	if m.sealer == nil {
+		return nil
+	}
+
+	for _, record := range records {
+		if isEncrypted(record.PrivateKeyPEM) {
+			continue
+		}
+		sealed, err := m.sealer.seal(record.ID, []byte(record.PrivateKeyPEM))
+		if err != nil {
+			return err
+		}
+		if err := m.store.ReplaceSigningKeyPEM(record.ID, record.PrivateKeyPEM, sealed); err != nil {
+			return err
+		}
+		slog.Info("Encrypted stored signing key", "kid", record.ID)
+	}
+	return nil
+}
+
+// findRecord returns the key with the given status among records, if any
+func findRecord(records []models.SigningKeyRecord, status models.SigningKeyStatus) *models.SigningKeyRecord {
+	for i := range records {
+		if records[i].Status == status {
+			return &records[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package keys
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+// memoryStore is a Store with the same conditional semantics as the signing_keys table
+type memoryStore struct {
+	mu      sync.Mutex
+	records []models.SigningKeyRecord
+}
+
+func (s *memoryStore) ListSigningKeys() ([]models.SigningKeyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SigningKeyRecord(nil), s.records...), nil
+}
+
+func (s *memoryStore) create(key *models.SigningKeyRecord, status models.SigningKeyStatus) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, record := range s.records {
+		if record.Status == status {
+			return false
+		}
+	}
+	stored := *key
+	stored.Status = status
+	s.records = append(s.records, stored)
+	return true
+}
+
+func (s *memoryStore) CreateInitialSigningKey(key *models.SigningKeyRecord) (bool, error) {
+	return s.create(key, models.SigningKeyActive), nil
+}
+
+func (s *memoryStore) CreatePendingSigningKey(key *models.SigningKeyRecord) (bool, error) {
+	return s.create(key, models.SigningKeyPending), nil
+}
+
+func (s *memoryStore) ActivateSigningKey(id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := -1
+	for i, record := range s.records {
+		if record.ID == id && record.Status == models.SigningKeyPending {
+			pending = i
+		}
+	}
+	if pending < 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	for i := range s.records {
+		if s.records[i].Status == models.SigningKeyActive {
+			s.records[i].Status = models.SigningKeyRetired
+			s.records[i].RetiredAt = &now
+		}
+	}
+	s.records[pending].Status = models.SigningKeyActive
+	return true, nil
+}
+
+func (s *memoryStore) ReplaceSigningKeyPEM(id, current, replacement string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.records {
+		if s.records[i].ID == id && s.records[i].PrivateKeyPEM == current {
+			s.records[i].PrivateKeyPEM = replacement
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) DeleteRetiredSigningKeys(before time.Time) (int64, error) {
+	return 0, nil
+}
+
+// backdatePending makes the pending key look as if it was published d ago
+func (s *memoryStore) backdatePending(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.records {
+		if s.records[i].Status == models.SigningKeyPending {
+			s.records[i].CreatedAt = s.records[i].CreatedAt.Add(-d)
+		}
+	}
+}
+
+// newInstance loads the store into a new manager and token manager, like a service
+// instance starting up
+func newInstance(t *testing.T, store Store) (*Manager, *utils.TokenManager) {
+	t.Helper()
+
+	manager := NewManager(store, "EdDSA", time.Hour, 10*time.Minute)
+	ring, err := manager.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return manager, utils.NewTokenManagerWithKeyRing(ring, "test", time.Minute)
+}
+
+// signToken issues an access token with the instance's active key
+func signToken(t *testing.T, tokens *utils.TokenManager) string {
+	t.Helper()
+
+	token, _, err := tokens.GenerateToken("user_1", models.RoleCustomer, "session_1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestRotatePublishesPendingKey(t *testing.T) {
+	store := &memoryStore{}
+	manager, tokens := newInstance(t, store)
+	original := tokens.KeyRing().Active()
+
+	pending, activatesAt, err := manager.Rotate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Until(activatesAt) < 9*time.Minute {
+		t.Errorf("activates at %s, want about 10 minutes from now", activatesAt)
+	}
+	if tokens.KeyRing().Active().ID != original.ID {
+		t.Error("Rotate() changed the active key before the activation delay")
+	}
+
+	published := false
+	for _, jwk := range tokens.JWKS().Keys {
+		published = published || jwk.KeyID == pending.ID
+	}
+	if !published {
+		t.Error("pending key is not in the JWKS")
+	}
+
+	if _, _, err := manager.Rotate(); !errors.Is(err, ErrRotationPending) {
+		t.Errorf("second Rotate() error = %v, want %v", err, ErrRotationPending)
+	}
+
+	if activated, err := manager.Activate(); err != nil || activated {
+		t.Fatalf("Activate() before the delay = %v, %v; want false", activated, err)
+	}
+
+	store.backdatePending(10 * time.Minute)
+	if activated, err := manager.Activate(); err != nil || !activated {
+		t.Fatalf("Activate() after the delay = %v, %v; want true", activated, err)
+	}
+	if err := manager.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if got := tokens.KeyRing().Active().ID; got != pending.ID {
+		t.Errorf("active key = %s, want the pending key %s", got, pending.ID)
+	}
+	if tokens.KeyRing().Key(original.ID) == nil {
+		t.Error("the retired key no longer verifies")
+	}
+}
+
+func TestUnknownKeyReloadsRing(t *testing.T) {
+	store := &memoryStore{}
+	rotator, rotatorTokens := newInstance(t, store)
+	_, otherTokens := newInstance(t, store)
+
+	// Rotate and activate on one instance without the other refreshing
+	if _, _, err := rotator.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	store.backdatePending(10 * time.Minute)
+	if _, err := rotator.Activate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := rotator.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := otherTokens.ValidateToken(signToken(t, rotatorTokens)); err != nil {
+		t.Fatalf("ValidateToken() on the other instance error = %v, want the ring to reload", err)
+	}
+}
+
+func TestKeyEncryption(t *testing.T) {
+	kek := bytes.Repeat([]byte{1}, KeyEncryptionKeySize)
+	otherKEK := bytes.Repeat([]byte{2}, KeyEncryptionKeySize)
+
+	// storedPlaintext reports whether any stored private key is readable PEM
+	storedPlaintext := func(store *memoryStore) bool {
+		records, _ := store.ListSigningKeys()
+		for _, record := range records {
+			if strings.Contains(record.PrivateKeyPEM, "PRIVATE KEY") {
+				return true
+			}
+		}
+		return false
+	}
+
+	t.Run("new keys are stored encrypted", func(t *testing.T) {
+		store := &memoryStore{}
+		manager := NewManager(store, "EdDSA", time.Hour, 10*time.Minute, WithKeyEncryptionKey(kek))
+		if _, err := manager.Load(); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := manager.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+		if storedPlaintext(store) {
+			t.Error("a private key was stored in plaintext")
+		}
+
+		// Another instance with the same key can load them
+		if _, err := NewManager(store, "EdDSA", time.Hour, 10*time.Minute, WithKeyEncryptionKey(kek)).Load(); err != nil {
+			t.Errorf("Load() with the same key error = %v", err)
+		}
+		if _, err := NewManager(store, "EdDSA", time.Hour, 10*time.Minute).Load(); !errors.Is(err, ErrNoKeyEncryptionKey) {
+			t.Errorf("Load() without a key error = %v, want %v", err, ErrNoKeyEncryptionKey)
+		}
+		if _, err := NewManager(store, "EdDSA", time.Hour, 10*time.Minute, WithKeyEncryptionKey(otherKEK)).Load(); err == nil {
+			t.Error("Load() with the wrong key succeeded")
+		}
+	})
+
+	t.Run("plaintext keys are encrypted on load", func(t *testing.T) {
+		store := &memoryStore{}
+		_, tokens := newInstance(t, store)
+		token := signToken(t, tokens)
+		if !storedPlaintext(store) {
+			t.Fatal("expected the key to be stored in plaintext without a key encryption key")
+		}
+
+		manager := NewManager(store, "EdDSA", time.Hour, 10*time.Minute, WithKeyEncryptionKey(kek))
+		ring, err := manager.Load()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if storedPlaintext(store) {
+			t.Error("Load() left a private key in plaintext")
+		}
+		if _, err := utils.NewTokenManagerWithKeyRing(ring, "test", time.Minute).ValidateToken(token); err != nil {
+			t.Errorf("token signed before encryption: error = %v", err)
+		}
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		if _, err := NewManager(&memoryStore{}, "EdDSA", time.Hour, 10*time.Minute, WithKeyEncryptionKey([]byte("short"))).Load(); err == nil {
+			t.Error("Load() with a short key encryption key succeeded")
+		}
+	})
+}
+
+// failingStore fails to list keys while failing is set
+type failingStore struct {
+	*memoryStore
+	failing bool
+}
+
+func (s *failingStore) ListSigningKeys() ([]models.SigningKeyRecord, error) {
+	if s.failing {
+		return nil, errors.New("database unavailable")
+	}
+	return s.memoryStore.ListSigningKeys()
+}
+
+func TestCheckFresh(t *testing.T) {
+	if err := NewManager(&memoryStore{}, "EdDSA", time.Hour, time.Minute).CheckFresh(time.Hour); err == nil {
+		t.Error("CheckFresh() before Load succeeded")
+	}
+
+	store := &failingStore{memoryStore: &memoryStore{}}
+	manager, _ := newInstance(t, store)
+	if err := manager.CheckFresh(time.Hour); err != nil {
+		t.Fatalf("CheckFresh() after Load: %v", err)
+	}
+
+	// Failed refreshes leave the ring as it was, and it grows stale
+	store.failing = true
+	if err := manager.Refresh(); err == nil {
+		t.Fatal("Refresh() succeeded with a failing store")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if err := manager.CheckFresh(time.Millisecond); err == nil {
+		t.Error("CheckFresh() succeeded after the refreshes failed")
+	}
+
+	store.failing = false
+	if err := manager.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+	if err := manager.CheckFresh(time.Hour); err != nil {
+		t.Errorf("CheckFresh() after a successful refresh: %v", err)
+	}
+}
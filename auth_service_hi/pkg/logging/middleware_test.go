@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestValidRequestID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{"", false},
+		{"a", true},
+		{"0f3c9a2b7d1e4f56", true},
+		{"req-2024.01_02", true},
+		{"3F2504E0-4F89-11D3-9A0C-0305E82C3301", true},
+		{strings.Repeat("a", maxRequestIDLength), true},
+		{strings.Repeat("a", maxRequestIDLength+1), false},
+		{"has space", false},
+		{"line\nbreak", false},
+		{`quote"`, false},
+		{"semi;colon", false},
+		{"slash/", false},
+		{"ünïcode", false},
+	}
+
+	for _, tt := range tests {
+		if got := validRequestID(tt.id); got != tt.want {
+			t.Errorf("validRequestID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
+var generatedID = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   string
+		wantKept bool
+	}{
+		{"no header", "", false},
+		{"valid ID from a proxy", "upstream-1234", true},
+		{"invalid ID", "bad id\r\nX-Injected: 1", false},
+		{"overlong ID", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				seen = RequestID(r.Context())
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(RequestIDHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			id := w.Header().Get(RequestIDHeader)
+			if tt.wantKept {
+				if id != tt.header {
+					t.Errorf("%s = %q, want the client's %q", RequestIDHeader, id, tt.header)
+				}
+			} else if !generatedID.MatchString(id) {
+				t.Errorf("%s = %q, want 16 generated hex digits", RequestIDHeader, id)
+			}
+			if seen != id {
+				t.Errorf("request context carries %q, response header %q", seen, id)
+			}
+		})
+	}
+}
+
+func TestMiddlewareGeneratesDistinctIDs(t *testing.T) {
+	handler := Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		id := w.Header().Get(RequestIDHeader)
+		if seen[id] {
+			t.Fatalf("request ID %q generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatText, slog.LevelInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "Handled request")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(RequestIDHeader, "trace-42")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	if !strings.Contains(buf.String(), "request_id=trace-42") {
+		t.Errorf("log line %q does not carry the request ID", buf.String())
+	}
+}
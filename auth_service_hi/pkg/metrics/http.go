@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// HTTPMetrics counts and times HTTP requests by method, route and status
+type HTTPMetrics struct {
+	routes   *http.ServeMux
+	requests *CounterVec
+	duration *HistogramVec
+}
+
+// NewHTTPMetrics registers the HTTP request metrics. Requests are labelled with
+// the routes pattern that matches them rather than their path, so that made-up
+// paths cannot create unbounded series.
+func NewHTTPMetrics(registry *Registry, routes *http.ServeMux) *HTTPMetrics {
+	return &HTTPMetrics{
+		routes: routes,
+		requests: registry.NewCounterVec("http_requests_total",
+			"HTTP requests handled, by method, route and status.",
+			"method", "route", "status"),
+		duration: registry.NewHistogramVec("http_request_duration_seconds",
+			"Time taken to handle HTTP requests, by method, route and status.",
+			DefBuckets, "method", "route", "status"),
+	}
+}
+
+// Middleware records every request passed to next
+func (m *HTTPMetrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := m.routes.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		method := r.Method
+		switch method {
+		case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+			http.MethodDelete, http.MethodOptions:
+		default:
+			method = "other"
+		}
+		status := strconv.Itoa(recorder.status)
+
+		m.requests.Inc(method, route, status)
+		m.duration.Observe(time.Since(start).Seconds(), method, route, status)
+	})
+}
+
+// statusRecorder remembers the status code written to a response
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if !r.wroteHeader {
+		r.status = code
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
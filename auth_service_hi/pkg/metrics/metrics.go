@@ -0,0 +1,317 @@
+// Package metrics keeps counters, gauges and histograms in memory and serves them
+// in the Prometheus text exposition format. It covers what the service needs
+// without a client library: labelled counters and histograms updated as things
+// happen, and gauges and counters read from a function at scrape time.
+package metrics
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets are histogram bucket upper bounds in seconds suited to request latency
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is anything the registry can write
+type metric interface {
+	write(w io.Writer)
+}
+
+// Registry holds the registered metrics
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+	names   map[string]bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+// register adds m under name. Registering a name twice is a programming error.
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: %s registered twice", name))
+	}
+	r.names[name] = true
+	r.metrics = append(r.metrics, m)
+}
+
+// WriteTo writes every metric in the text exposition format, in registration order
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	registered := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range registered {
+		m.write(&buf)
+	}
+	return buf.WriteTo(w)
+}
+
+// Handler serves the metrics. If token is not empty, scrapes must send it as a
+// bearer token.
+func (r *Registry) Handler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if token != "" {
+			sent := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteTo(w)
+	})
+}
+
+// series is one set of label values of a vector
+type series[T any] struct {
+	values []string
+	value  T
+}
+
+// vec keeps the series of a labelled metric keyed by their label values
+type vec[T any] struct {
+	name   string
+	help   string
+	labels []string
+
+	mu     sync.Mutex
+	series map[string]*series[T]
+}
+
+func newVec[T any](name, help string, labels []string) vec[T] {
+	return vec[T]{name: name, help: help, labels: labels, series: make(map[string]*series[T])}
+}
+
+// get returns the series for values, creating it with init if needed. The caller
+// holds v.mu.
+func (v *vec[T]) get(values []string, init func() T) *series[T] {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s takes %d label value(s), got %d", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{values: append([]string(nil), values...), value: init()}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted returns the series ordered by label values. The caller holds v.mu.
+func (v *vec[T]) sorted() []*series[T] {
+	keys := make([]string, 0, len(v.series))
+	for key := range v.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	sorted := make([]*series[T], 0, len(keys))
+	for _, key := range keys {
+		sorted = append(sorted, v.series[key])
+	}
+	return sorted
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	vec[float64]
+}
+
+// NewCounterVec registers a counter with the given label names
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec[float64](name, help, labels)}
+	r.register(name, c)
+	return c
+}
+
+// Inc adds one to the series with the given label values
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the series with the given label values
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.get(values, func() float64 { return 0 }).value += delta
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, s := range c.sorted() {
+		writeSample(w, c.name, c.labels, s.values, "", "", s.value)
+	}
+}
+
+// histogram is the state of one histogram series
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	vec[*histogram]
+	buckets []float64
+}
+
+// NewHistogramVec registers a histogram with the given bucket upper bounds, in
+// increasing order, and label names
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{vec: newVec[*histogram](name, help, labels), buckets: buckets}
+	r.register(name, h)
+	return h
+}
+
+// Observe records value in the series with the given label values
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s := h.get(values, func() *histogram {
+		return &histogram{counts: make([]uint64, len(h.buckets))}
+	}).value
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.value.counts[i]
+			writeSample(w, h.name+"_bucket", h.labels, s.values, "le", formatFloat(bound), float64(cumulative))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, s.values, "le", "+Inf", float64(s.value.count))
+		writeSample(w, h.name+"_sum", h.labels, s.values, "", "", s.value.sum)
+		writeSample(w, h.name+"_count", h.labels, s.values, "", "", float64(s.value.count))
+	}
+}
+
+// funcMetric is a gauge or counter read at scrape time
+type funcMetric struct {
+	name  string
+	help  string
+	kind  string
+	label string
+	fn    func() map[string]float64
+}
+
+// NewGaugeFunc registers a gauge whose value is read from fn at every scrape
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "gauge", fn: single(fn)})
+}
+
+// NewCounterFunc registers a counter whose value is read from fn at every scrape,
+// for totals that are already counted elsewhere
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "counter", fn: single(fn)})
+}
+
+// NewGaugeVecFunc registers a gauge with one label whose series are read from fn
+// at every scrape, keyed by label value. A nil map writes no series.
+func (r *Registry) NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) {
+	r.register(name, &funcMetric{name: name, help: help, kind: "gauge", label: label, fn: fn})
+}
+
+// single adapts an unlabelled value function
+func single(fn func() float64) func() map[string]float64 {
+	return func() map[string]float64 {
+		return map[string]float64{"": fn()}
+	}
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	values := f.fn()
+
+	writeHeader(w, f.name, f.help, f.kind)
+	if f.label == "" {
+		if value, ok := values[""]; ok {
+			writeSample(w, f.name, nil, nil, "", "", value)
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		writeSample(w, f.name, []string{f.label}, []string{key}, "", "", values[key])
+	}
+}
+
+// writeHeader writes the HELP and TYPE lines of a metric
+func writeHeader(w io.Writer, name, help, kind string) {
+	help = strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// writeSample writes one sample line. extraLabel, if not empty, is added after
+// the series labels, as histograms do with le.
+func writeSample(w io.Writer, name string, labels, values []string, extraLabel, extraValue string, value float64) {
+	io.WriteString(w, name)
+
+	if len(labels) > 0 || extraLabel != "" {
+		pairs := make([]string, 0, len(labels)+1)
+		for i, label := range labels {
+			pairs = append(pairs, label+`="`+escapeLabelValue(values[i])+`"`)
+		}
+		if extraLabel != "" {
+			pairs = append(pairs, extraLabel+`="`+extraValue+`"`)
+		}
+		io.WriteString(w, "{"+strings.Join(pairs, ",")+"}")
+	}
+
+	io.WriteString(w, " "+formatFloat(value)+"\n")
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueEscaper.Replace(value)
+}
+
+// formatFloat formats a sample value, spelling infinities and NaN as Prometheus does
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// exposition returns everything registry writes
+func exposition(t *testing.T, registry *Registry) string {
+	t.Helper()
+
+	var b strings.Builder
+	if _, err := registry.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+// checkExposition compares the written metrics with the expected text
+func checkExposition(t *testing.T, registry *Registry, want string) {
+	t.Helper()
+
+	if got := exposition(t, registry); got != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCounterVec(t *testing.T) {
+	registry := NewRegistry()
+	logins := registry.NewCounterVec("logins_total", "Login attempts.", "result", "role")
+	logins.Inc("success", "customer")
+	logins.Inc("success", "customer")
+	logins.Add(0.5, "invalid_credentials", "admin")
+	logins.Inc("success", "admin")
+
+	// Series are sorted by label values, whatever order they were created in
+	checkExposition(t, registry, `# HELP logins_total Login attempts.
+# TYPE logins_total counter
+logins_total{result="invalid_credentials",role="admin"} 0.5
+logins_total{result="success",role="admin"} 1
+logins_total{result="success",role="customer"} 2
+`)
+}
+
+func TestHistogramVec(t *testing.T) {
+	registry := NewRegistry()
+	latency := registry.NewHistogramVec("latency_seconds", "Request latency.", []float64{0.1, 0.5, 1}, "route")
+	latency.Observe(0.05, "/a")
+	latency.Observe(0.1, "/a") // Bucket bounds are inclusive
+	latency.Observe(0.7, "/a")
+	latency.Observe(3, "/a") // Only counted in +Inf
+
+	checkExposition(t, registry, `# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{route="/a",le="0.1"} 2
+latency_seconds_bucket{route="/a",le="0.5"} 2
+latency_seconds_bucket{route="/a",le="1"} 3
+latency_seconds_bucket{route="/a",le="+Inf"} 4
+latency_seconds_sum{route="/a"} 3.85
+latency_seconds_count{route="/a"} 4
+`)
+}
+
+func TestFuncMetrics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGaugeFunc("open_connections", "Open connections.", func() float64 { return 3 })
+	registry.NewCounterFunc("waits_total", "Waits.", func() float64 { return 1.5e9 })
+	registry.NewGaugeVecFunc("sessions", "Sessions by role.", "role", func() map[string]float64 {
+		return map[string]float64{"healer": 2, "admin": 1}
+	})
+	registry.NewGaugeVecFunc("unavailable", "Nothing to report.", "role", func() map[string]float64 {
+		return nil
+	})
+	registry.NewGaugeFunc("special", "Infinite.", func() float64 { return math.Inf(1) })
+
+	// Metrics are written in registration order; a nil map writes only the header
+	checkExposition(t, registry, `# HELP open_connections Open connections.
+# TYPE open_connections gauge
+open_connections 3
+# HELP waits_total Waits.
+# TYPE waits_total counter
+waits_total 1.5e+09
+# HELP sessions Sessions by role.
+# TYPE sessions gauge
+sessions{role="admin"} 1
+sessions{role="healer"} 2
+# HELP unavailable Nothing to report.
+# TYPE unavailable gauge
+# HELP special Infinite.
+# TYPE special gauge
+special +Inf
+`)
+}
+
+func TestEscaping(t *testing.T) {
+	registry := NewRegistry()
+	c := registry.NewCounterVec("escaped_total", "Help with a \\ backslash\nand a newline.", "value")
+	c.Inc(`quote " backslash \ newline` + "\n")
+
+	checkExposition(t, registry, `# HELP escaped_total Help with a \\ backslash\nand a newline.
+# TYPE escaped_total counter
+escaped_total{value="quote \" backslash \\ newline\n"} 1
+`)
+}
+
+func TestRegisterTwicePanics(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("requests_total", "Requests.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a name twice did not panic")
+		}
+	}()
+	registry.NewGaugeFunc("requests_total", "Requests.", func() float64 { return 0 })
+}
+
+func TestHTTPMetricsRoute(t *testing.T) {
+	registry := NewRegistry()
+	routes := http.NewServeMux()
+	routes.HandleFunc("GET /api/users/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	routes.HandleFunc("POST /api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	m := NewHTTPMetrics(registry, routes)
+	handler := m.Middleware(routes)
+
+	requests := []struct{ method, path string }{
+		{http.MethodGet, "/api/users/1"},
+		{http.MethodGet, "/api/users/2"},
+		{http.MethodPost, "/api/auth/login"},
+		{http.MethodGet, "/made-up/path"},
+		{"PROPFIND", "/api/users/1"},
+	}
+	for _, req := range requests {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(req.method, req.path, nil))
+	}
+
+	// Requests are labelled with the matching pattern, not the path, and unknown
+	// methods are folded into other
+	out := exposition(t, registry)
+	total := out[:strings.Index(out, "# HELP http_request_duration_seconds")]
+	want := `# HELP http_requests_total HTTP requests handled, by method, route and status.
+# TYPE http_requests_total counter
+http_requests_total{method="GET",route="GET /api/users/{id}",status="200"} 2
+http_requests_total{method="GET",route="unmatched",status="404"} 1
+http_requests_total{method="POST",route="POST /api/auth/login",status="401"} 1
+http_requests_total{method="other",route="unmatched",status="405"} 1
+`
+	if total != want {
+		t.Errorf("exposition mismatch\ngot:\n%s\nwant:\n%s", total, want)
+	}
+	if !strings.Contains(out, `http_request_duration_seconds_count{method="GET",route="GET /api/users/{id}",status="200"} 2`) {
+		t.Errorf("request durations not recorded:\n%s", out)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewGaugeFunc("up", "Up.", func() float64 { return 1 })
+
+	tests := []struct {
+		name          string
+		token         string
+		authorization string
+		wantStatus    int
+	}{
+		{"no token configured", "", "", http.StatusOK},
+		{"correct token", "scrape-token", "Bearer scrape-token", http.StatusOK},
+		{"missing token", "scrape-token", "", http.StatusUnauthorized},
+		{"wrong token", "scrape-token", "Bearer other-token", http.StatusUnauthorized},
+		{"token prefix", "scrape-token", "Bearer scrape", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			registry.Handler(tt.token).ServeHTTP(w, r)
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusUnauthorized {
+				if w.Header().Get("WWW-Authenticate") != "Bearer" {
+					t.Error("401 without WWW-Authenticate: Bearer")
+				}
+				if strings.Contains(w.Body.String(), "up 1") {
+					t.Error("rejected scrape received metrics")
+				}
+				return
+			}
+			if got := w.Header().Get("Content-Type"); got != "text/plain; version=0.0.4; charset=utf-8" {
+				t.Errorf("Content-Type = %q", got)
+			}
+			if !strings.Contains(w.Body.String(), "\nup 1\n") {
+				t.Errorf("body = %q, want the up gauge", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// UserFilter selects a page of users for the admin API. Zero values match everything.
+type UserFilter struct {
+	Role          UserRole  // Only users with this role
+	EmailVerified *bool     // Only users whose email is (or is not) verified
+	Disabled      *bool     // Only disabled (or enabled) accounts
+	CreatedAfter  time.Time // Only users created at or after this time
+	CreatedBefore time.Time // Only users created before this time
+	Page          int       // 1-based page number
+	PerPage       int       // Users per page
+}
+
+// UserListResponse is a page of users together with the total number of matches
+type UserListResponse struct {
+	Users   []User `json:"users"`
+	Total   int    `json:"total"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+}
+
+// ChangeRoleRequest represents the data needed to change a user's role
+type ChangeRoleRequest struct {
+	UserID string   `json:"user_id" binding:"required"`
+	Role   UserRole `json:"role" binding:"required"`
+}
+
+// UserActionRequest identifies the user an admin action applies to
+type UserActionRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// AccountLockout records one lockout of an account after repeated failed logins
+type AccountLockout struct {
+	ID             string     `json:"id" db:"id"`                             // Lockout record ID
+	UserID         string     `json:"user_id" db:"user_id"`                   // Locked account
+	FailedAttempts int        `json:"failed_attempts" db:"failed_attempts"`   // Consecutive failures that triggered it
+	LockedAt       time.Time  `json:"locked_at" db:"locked_at"`               // When the lockout started
+	LockedUntil    time.Time  `json:"locked_until" db:"locked_until"`         // When it ends on its own
+	UnlockedAt     *time.Time `json:"unlocked_at" db:"unlocked_at"`           // Set if an admin ended it early
+	UnlockedBy     string     `json:"unlocked_by,omitempty" db:"unlocked_by"` // Admin who ended it early
+}
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// AuthEventType identifies what an audit event records
+type AuthEventType string
+
+const (
+	EventSignup                 AuthEventType = "signup"
+	EventLogin                  AuthEventType = "login"
+	EventLogout                 AuthEventType = "logout"
+	EventLogoutAll              AuthEventType = "logout_all"
+	EventPasswordResetRequested AuthEventType = "password_reset_requested"
+	EventPasswordReset          AuthEventType = "password_reset"
+	EventMFAEnabled             AuthEventType = "mfa_enabled"
+	EventMFADisabled            AuthEventType = "mfa_disabled"
+
+	// Admin actions
+	EventRoleChanged         AuthEventType = "role_changed"
+	EventUserDisabled        AuthEventType = "user_disabled"
+	EventUserEnabled         AuthEventType = "user_enabled"
+	EventPasswordResetForced AuthEventType = "password_reset_forced"
+	EventSessionsRevoked     AuthEventType = "sessions_revoked"
+	EventUserUnlocked        AuthEventType = "user_unlocked"
+	EventInvitationCreated   AuthEventType = "invitation_created"
+	EventInvitationRevoked   AuthEventType = "invitation_revoked"
+	EventRoleCreated         AuthEventType = "role_created"
+	EventRoleUpdated         AuthEventType = "role_updated"
+	EventRoleDeleted         AuthEventType = "role_deleted"
+	EventPermissionCreated   AuthEventType = "permission_created"
+	EventPermissionDeleted   AuthEventType = "permission_deleted"
+	EventKeysRotated         AuthEventType = "keys_rotated"
+)
+
+// AuthEventOutcome is whether the audited action succeeded
+type AuthEventOutcome string
+
+const (
+	OutcomeSuccess AuthEventOutcome = "success"
+	OutcomeFailure AuthEventOutcome = "failure"
+)
+
+// AuthEvent is one entry of the append-only audit log
+type AuthEvent struct {
+	ID           string           `json:"id" db:"id"`                                   // Event ID
+	Type         AuthEventType    `json:"type" db:"event_type"`                         // What happened
+	Outcome      AuthEventOutcome `json:"outcome" db:"outcome"`                         // Whether it succeeded
+	ActorID      string           `json:"actor_id,omitempty" db:"actor_id"`             // Signed-in user who acted, if any
+	TargetUserID string           `json:"target_user_id,omitempty" db:"target_user_id"` // User the action applied to, if known
+	Email        string           `json:"email,omitempty" db:"email"`                   // Email address given, e.g. on a failed login
+	IPAddress    string           `json:"ip_address,omitempty" db:"ip_address"`         // Client IP
+	UserAgent    string           `json:"user_agent,omitempty" db:"user_agent"`         // Client User-Agent header
+	Detail       string           `json:"detail,omitempty" db:"detail"`                 // Failure reason or what changed
+	CreatedAt    time.Time        `json:"created_at" db:"created_at"`                   // When it happened
+}
+
+// AuthEventFilter selects a page of audit events. Zero values match everything.
+type AuthEventFilter struct {
+	UserID  string           // Events where this user is the actor or the target
+	Email   string           // Only events for this email address
+	Type    AuthEventType    // Only events of this type
+	Outcome AuthEventOutcome // Only successes or only failures
+	Since   time.Time        // Only events at or after this time
+	Until   time.Time        // Only events before this time
+	Page    int              // 1-based page number
+	PerPage int              // Events per page
+}
+
+// AuthEventListResponse is a page of audit events together with the total number of matches
+type AuthEventListResponse struct {
+	Events  []AuthEvent `json:"events"`
+	Total   int         `json:"total"`
+	Page    int         `json:"page"`
+	PerPage int         `json:"per_page"`
+}
@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+)
+
+// Invitation allows one person to sign up with a role that cannot be chosen freely,
+// such as admin. The invitation is consumed when the account is created.
+type Invitation struct {
+	ID         string     `json:"id" db:"id"`                             // Invitation ID
+	Email      string     `json:"email" db:"email"`                       // Address the invitation was sent to
+	Role       UserRole   `json:"role" db:"role"`                         // Role the new account receives
+	TokenHash  string     `json:"-" db:"token_hash"`                      // SHA-256 of the invitation token
+	InvitedBy  string     `json:"invited_by,omitempty" db:"invited_by"`   // Admin who created it; empty from the CLI
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`             // Invitation expiration time
+	AcceptedAt *time.Time `json:"accepted_at" db:"accepted_at"`           // Set once an account was created with it
+	AcceptedBy string     `json:"accepted_by,omitempty" db:"accepted_by"` // ID of the account created with it
+	RevokedAt  *time.Time `json:"revoked_at" db:"revoked_at"`             // Set when an admin withdraws it
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`             // Creation timestamp
+}
+
+// IsUsable reports whether the invitation can still be used to sign up
+func (i *Invitation) IsUsable(now time.Time) bool {
+	return i.AcceptedAt == nil && i.RevokedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// CreateInvitationRequest represents the data needed to invite someone
+type CreateInvitationRequest struct {
+	Email          string   `json:"email" binding:"required,email"`
+	Role           UserRole `json:"role" binding:"required"`
+	ExpiresInHours int      `json:"expires_in_hours,omitempty"` // Defaults to 72
+}
+
+// InvitationResponse is returned once when an invitation is created. The link
+// carries the only copy of the token.
+type InvitationResponse struct {
+	Invitation
+	Link string `json:"link"`
+}
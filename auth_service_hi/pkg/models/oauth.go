@@ -0,0 +1,104 @@
+package models
+
+import (
+	"time"
+)
+
+// OAuth 2.0 grant types a client may be allowed to use
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// OAuthClient represents a registered application. Web apps sign users in through
+// the OpenID Connect endpoints; machine clients authenticate with a secret and
+// obtain tokens for themselves with the client credentials grant.
+type OAuthClient struct {
+	ID           string    `json:"client_id" db:"id"`                // Public client identifier
+	Name         string    `json:"name" db:"name"`                   // Shown on the hosted login page
+	SecretHash   string    `json:"-" db:"secret_hash"`               // SHA-256 of the client secret; empty for public clients
+	RedirectURIs []string  `json:"redirect_uris" db:"redirect_uris"` // Exact redirect URIs the client may use
+	Scopes       []string  `json:"scopes" db:"scopes"`               // Scopes a machine client may request
+	GrantTypes   []string  `json:"grant_types" db:"grant_types"`     // Grant types the client may use
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`       // Registration timestamp
+}
+
+// IsConfidential reports whether the client must authenticate with a secret
+func (c *OAuthClient) IsConfidential() bool {
+	return c.SecretHash != ""
+}
+
+// AllowsGrantType reports whether the client may use the given grant type
+func (c *OAuthClient) AllowsGrantType(grantType string) bool {
+	return containsString(c.GrantTypes, grantType)
+}
+
+// AllowsScope reports whether a machine client may request the given scope
+func (c *OAuthClient) AllowsScope(scope string) bool {
+	return containsString(c.Scopes, scope)
+}
+
+// AllowsRedirectURI reports whether uri exactly matches a registered redirect URI
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsString(c.RedirectURIs, uri)
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationCode represents a stored, single-use OAuth 2.0 authorization code
+type AuthorizationCode struct {
+	CodeHash            string     `json:"-" db:"code_hash"`                                 // SHA-256 of the code
+	ClientID            string     `json:"client_id" db:"client_id"`                         // Client the code was issued to
+	UserID              string     `json:"user_id" db:"user_id"`                             // User who signed in
+	SessionID           string     `json:"session_id" db:"session_id"`                       // Session the code belongs to
+	RedirectURI         string     `json:"redirect_uri" db:"redirect_uri"`                   // Must be repeated at the token endpoint
+	Scope               string     `json:"scope" db:"scope"`                                 // Space-separated granted scopes
+	Nonce               string     `json:"nonce" db:"nonce"`                                 // Copied into the ID token
+	CodeChallenge       string     `json:"-" db:"code_challenge"`                            // PKCE challenge
+	CodeChallengeMethod string     `json:"code_challenge_method" db:"code_challenge_method"` // PKCE method, always S256
+	AuthTime            time.Time  `json:"auth_time" db:"auth_time"`                         // When the user authenticated
+	ExpiresAt           time.Time  `json:"expires_at" db:"expires_at"`                       // Expiry timestamp
+	ConsumedAt          *time.Time `json:"consumed_at" db:"consumed_at"`                     // Set once the code has been exchanged
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`                       // Issue timestamp
+}
+
+// ClientCredentialsResponse is returned once when a machine client is registered;
+// the secret is not stored and cannot be shown again
+type ClientCredentialsResponse struct {
+	ClientID     string   `json:"client_id"`
+	ClientSecret string   `json:"client_secret"`
+	Scopes       []string `json:"scopes"`
+}
+
+// TokenResponse is the OAuth 2.0 token endpoint response
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IntrospectionResponse is the token introspection response (RFC 7662 section 2.2).
+// Only Active is set for tokens that are invalid, expired or revoked.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Subject   string   `json:"sub,omitempty"`
+	Role      UserRole `json:"role,omitempty"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	TokenID   string   `json:"jti,omitempty"`
+}
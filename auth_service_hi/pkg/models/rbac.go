@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+)
+
+// Permission is a named right such as "orders:refund", checked with
+// AuthService.HasPermission. Names have the form <resource>:<action>.
+type Permission struct {
+	Name        string    `json:"name" db:"name"`               // e.g. "orders:refund"
+	Description string    `json:"description" db:"description"` // Human-readable summary
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`   // Creation timestamp
+}
+
+// Role is a named set of permissions. Every user has exactly one role, stored in
+// User.Role. Built-in roles are seeded by migrations and cannot be deleted.
+type Role struct {
+	Name        UserRole  `json:"name" db:"name"`               // e.g. "vendor_staff"
+	Description string    `json:"description" db:"description"` // Human-readable summary
+	BuiltIn     bool      `json:"built_in" db:"built_in"`       // One of the four original roles
+	Permissions []string  `json:"permissions"`                  // Names of granted permissions
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`   // Creation timestamp
+}
+
+// CreatePermissionRequest represents the data needed to define a permission
+type CreatePermissionRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+}
+
+// CreateRoleRequest represents the data needed to define a role
+type CreateRoleRequest struct {
+	Name        UserRole `json:"name" binding:"required"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// SetRolePermissionsRequest replaces the permissions granted to a role
+type SetRolePermissionsRequest struct {
+	Name        UserRole `json:"name" binding:"required"`
+	Permissions []string `json:"permissions"`
+}
+
+// DefaultPermissions are the permissions seeded by the migrations
+var DefaultPermissions = []Permission{
+	{Name: "orders:read", Description: "View orders"},
+	{Name: "orders:create", Description: "Place orders"},
+	{Name: "orders:refund", Description: "Refund orders"},
+	{Name: "products:manage", Description: "Create and edit products"},
+	{Name: "consultations:manage", Description: "Manage healing consultations"},
+	{Name: "users:read", Description: "View user accounts"},
+	{Name: "users:manage", Description: "Manage user accounts"},
+	{Name: "roles:manage", Description: "Manage roles and permissions"},
+	{Name: "keys:rotate", Description: "Rotate token signing keys"},
+	{Name: "audit:read", Description: "View the authentication audit log"},
+}
+
+// AdminPermissions are needed to manage users and roles through the admin API. Each
+// must stay granted to at least one role, or nobody could grant them again.
+var AdminPermissions = []string{"users:manage", "roles:manage"}
+
+// DefaultRoles are the built-in roles seeded by the RBAC migration. Admins are
+// granted every default permission.
+var DefaultRoles = []Role{
+	{Name: RoleCustomer, Description: "Shops and books consultations", BuiltIn: true, Permissions: []string{"orders:read", "orders:create"}},
+	{Name: RoleHealer, Description: "Provides consultations", BuiltIn: true, Permissions: []string{"orders:read", "consultations:manage"}},
+	{Name: RoleVendor, Description: "Sells products", BuiltIn: true, Permissions: []string{"orders:read", "products:manage"}},
+	{Name: RoleAdmin, Description: "Full access", BuiltIn: true, Permissions: defaultPermissionNames()},
+}
+
+func defaultPermissionNames() []string {
+	names := make([]string, 0, len(DefaultPermissions))
+	for _, permission := range DefaultPermissions {
+		names = append(names, permission.Name)
+	}
+	return names
+}
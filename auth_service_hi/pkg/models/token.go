@@ -0,0 +1,100 @@
+package models
+
+import (
+	"time"
+)
+
+// Session represents a login. It lasts as long as the refresh tokens issued for it.
+type Session struct {
+	ID        string    `json:"id" db:"id"`                 // Session ID, the sid claim of access tokens
+	UserID    string    `json:"user_id" db:"user_id"`       // User who signed in
+	ExpiresAt time.Time `json:"expires_at" db:"expires_at"` // Session expiration time
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // When the user authenticated; the auth_time of ID tokens
+}
+
+// RefreshToken represents a stored refresh token. Tokens issued from the same
+// login share a SessionID, which identifies the token family for rotation. Tokens
+// issued to an OpenID Connect client are bound to it and can only be redeemed by it.
+type RefreshToken struct {
+	ID        string     `json:"id" db:"id"`                 // Token record ID
+	UserID    string     `json:"user_id" db:"user_id"`       // Owner of the token
+	SessionID string     `json:"session_id" db:"session_id"` // Session (token family) the token belongs to
+	ClientID  string     `json:"client_id" db:"client_id"`   // OAuth client the token was issued to; empty for first-party logins
+	Scope     string     `json:"scope" db:"scope"`           // Scopes granted to the client
+	TokenHash string     `json:"-" db:"token_hash"`          // SHA-256 of the opaque token
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"` // Absolute expiry of the token family
+	RotatedAt *time.Time `json:"rotated_at" db:"rotated_at"` // Set once the token has been exchanged
+	RevokedAt *time.Time `json:"revoked_at" db:"revoked_at"` // Set when the family is revoked
+	CreatedAt time.Time  `json:"created_at" db:"created_at"` // Issue timestamp
+}
+
+// RefreshRequest represents the data needed to rotate a refresh token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// VerificationPurpose identifies what a verification token may be used for
+type VerificationPurpose string
+
+const (
+	PurposeEmailVerification VerificationPurpose = "email_verification"
+	PurposePhoneVerification VerificationPurpose = "phone_verification"
+	PurposePasswordReset     VerificationPurpose = "password_reset"
+)
+
+// VerificationToken represents a stored single-use token or code sent to a user
+type VerificationToken struct {
+	ID         string              `json:"id" db:"id"`                   // Token record ID
+	UserID     string              `json:"user_id" db:"user_id"`         // User the token was issued to
+	Purpose    VerificationPurpose `json:"purpose" db:"purpose"`         // What the token can be used for
+	TokenHash  string              `json:"-" db:"token_hash"`            // SHA-256 of the token value
+	ExpiresAt  time.Time           `json:"expires_at" db:"expires_at"`   // Token expiration time
+	Attempts   int                 `json:"attempts" db:"attempts"`       // Failed attempts to use the token
+	ConsumedAt *time.Time          `json:"consumed_at" db:"consumed_at"` // Set once the token has been used
+	CreatedAt  time.Time           `json:"created_at" db:"created_at"`   // Issue timestamp
+}
+
+// VerifyEmailRequest represents the data needed to verify an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResendVerificationRequest represents a request for a new verification email
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyPhoneRequest represents the data needed to confirm a phone number
+type VerifyPhoneRequest struct {
+	Code string `json:"code" binding:"required,len=6"`
+}
+
+// ForgotPasswordRequest represents a request for a password reset email
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents the data needed to set a new password
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+// SigningKeyStatus is the lifecycle state of a JWT signing key
+type SigningKeyStatus string
+
+const (
+	SigningKeyPending SigningKeyStatus = "pending" // Published for verification, not yet signing
+	SigningKeyActive  SigningKeyStatus = "active"  // Signs new tokens
+	SigningKeyRetired SigningKeyStatus = "retired" // Only verifies tokens issued before rotation
+)
+
+// SigningKeyRecord represents a persisted JWT signing key
+type SigningKeyRecord struct {
+	ID            string           `json:"kid" db:"id"`                // Key ID placed in the kid header
+	Algorithm     string           `json:"alg" db:"algorithm"`         // JWS algorithm, e.g. EdDSA
+	PrivateKeyPEM string           `json:"-" db:"private_key_pem"`     // PKCS#8 PEM private key, sealed with the key encryption key if one is set
+	Status        SigningKeyStatus `json:"status" db:"status"`         // pending, active or retired
+	CreatedAt     time.Time        `json:"created_at" db:"created_at"` // Creation timestamp
+	RetiredAt     *time.Time       `json:"retired_at" db:"retired_at"` // When the key stopped signing
+}
@@ -16,17 +16,36 @@ const (
 
 // User represents the basic user model that all user types will embed
 type User struct {
-	ID           string    `json:"id" db:"id"`                       // UUID with role prefix like "cust_123"
-	Email        string    `json:"email" db:"email"`                 // Email address (unique)
-	PasswordHash string    `json:"-" db:"password_hash"`             // Bcrypt hashed password
-	MFASecret    string    `json:"-" db:"mfa_secret"`                // Encrypted MFA secret
-	PhoneNumber  string    `json:"phone_number" db:"phone_number"`   // Phone number
-	Name         string    `json:"name" db:"name"`                   // User's name
-	Role         UserRole  `json:"role" db:"role"`                   // User role (customer, admin, etc.)
-	EmailVerified bool      `json:"email_verified" db:"email_verified"` // Whether email has been verified
-	PhoneVerified bool      `json:"phone_verified" db:"phone_verified"` // Whether phone has been verified
-	CreatedAt    time.Time `json:"created_at" db:"created_at"`       // Account creation timestamp
-	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`       // Account last update timestamp
+	ID            string     `json:"id" db:"id"`                             // UUID with role prefix like "cust_123"
+	Email         string     `json:"email" db:"email"`                       // Email address (unique)
+	PasswordHash  string     `json:"-" db:"password_hash"`                   // Bcrypt hashed password
+	MFASecret     string     `json:"-" db:"mfa_secret"`                      // Encrypted MFA secret
+	MFAEnabled    bool       `json:"mfa_enabled" db:"mfa_enabled"`           // Whether TOTP enrollment has been confirmed
+	MFALastStep   int64      `json:"-" db:"mfa_last_step"`                   // Last TOTP time step accepted; older codes are replays
+	PhoneNumber   string     `json:"phone_number" db:"phone_number"`         // Phone number
+	Name          string     `json:"name" db:"name"`                         // User's name
+	Role          UserRole   `json:"role" db:"role"`                         // User role (customer, admin, etc.)
+	EmailVerified bool       `json:"email_verified" db:"email_verified"`     // Whether email has been verified
+	PhoneVerified bool       `json:"phone_verified" db:"phone_verified"`     // Whether phone has been verified
+	DisabledAt    *time.Time `json:"disabled_at,omitempty" db:"disabled_at"` // Set while an admin has disabled the account
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`             // Account creation timestamp
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`             // Account last update timestamp
+
+	// Failed login tracking for account lockout
+	FailedLoginCount  int        `json:"failed_login_count" db:"failed_login_count"`               // Consecutive failed logins since the last lockout
+	LastFailedLoginAt *time.Time `json:"last_failed_login_at,omitempty" db:"last_failed_login_at"` // Time of the most recent failed login
+	LockoutCount      int        `json:"lockout_count" db:"lockout_count"`                         // Lockouts since the last successful login; each doubles the next
+	LockedUntil       *time.Time `json:"locked_until,omitempty" db:"locked_until"`                 // Logins are refused until this time
+}
+
+// IsLocked reports whether the account is locked out at the given time
+func (u *User) IsLocked(now time.Time) bool {
+	return u.LockedUntil != nil && now.Before(*u.LockedUntil)
+}
+
+// IsDisabled reports whether an admin has disabled the account
+func (u *User) IsDisabled() bool {
+	return u.DisabledAt != nil
 }
 
 // SignupRequest represents the data needed for signup
@@ -35,7 +54,10 @@ type SignupRequest struct {
 	Email       string   `json:"email" binding:"required,email"`
 	Password    string   `json:"password" binding:"required,min=8"`
 	PhoneNumber string   `json:"phone_number" binding:"required"`
-	Role        UserRole `json:"role" binding:"required"`
+	Role        UserRole `json:"role"`
+
+	// Required for roles that cannot be chosen freely; the role may then be omitted
+	InvitationToken string `json:"invitation_token,omitempty"`
 }
 
 // LoginRequest represents the data needed for login
@@ -47,8 +69,30 @@ type LoginRequest struct {
 
 // AuthResponse represents the data returned after successful authentication
 type AuthResponse struct {
-	Token        string    `json:"token"`         // JWT token
-	RefreshToken string    `json:"refresh_token"` // Refresh token (optional)
-	ExpiresAt    time.Time `json:"expires_at"`    // Token expiration time
-	User         User      `json:"user"`          // User information
-}
\ No newline at end of file
+	Token            string    `json:"token"`              // JWT access token
+	RefreshToken     string    `json:"refresh_token"`      // Opaque single-use refresh token
+	ExpiresAt        time.Time `json:"expires_at"`         // Access token expiration time
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"` // Refresh token expiration time
+	SessionID        string    `json:"-"`                  // Session the tokens belong to
+	User             User      `json:"user"`               // User information
+}
+
+// MFAEnrollmentResponse carries the secret and provisioning URI for an authenticator app
+type MFAEnrollmentResponse struct {
+	Secret     string `json:"secret"`      // Base32 TOTP secret for manual entry
+	OTPAuthURI string `json:"otpauth_uri"` // otpauth:// URI to render as a QR code
+}
+
+// MFACodeRequest represents a request carrying a single TOTP code
+type MFACodeRequest struct {
+	OTPCode string `json:"otp_code" binding:"required"`
+}
+
+// ContactInfo is the subset of a user's details that other users may see
+type ContactInfo struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Role        UserRole `json:"role"`
+	Email       string   `json:"email"`
+	PhoneNumber string   `json:"phone_number"`
+}
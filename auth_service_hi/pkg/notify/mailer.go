@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"log/slog"
+)
+
+// Mailer sends transactional email such as verification links
+type Mailer interface {
+	SendEmail(to, subject, body string) error
+}
+
+// LogMailer is a development Mailer that writes messages to the log instead of sending them
+type LogMailer struct{}
+
+// NewLogMailer creates a new log mailer
+func NewLogMailer() *LogMailer {
+	return &LogMailer{}
+}
+
+// SendEmail logs the message
+func (m *LogMailer) SendEmail(to, subject, body string) error {
+	slog.Info("Email", "to", to, "subject", subject, "body", body)
+	return nil
+}
@@ -0,0 +1,58 @@
+package notify
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// SMSSender sends text messages such as phone verification codes
+type SMSSender interface {
+	SendSMS(to, message string) error
+}
+
+// LogSMSSender is a development SMSSender that writes messages to the log
+type LogSMSSender struct{}
+
+// NewLogSMSSender creates a new log SMS sender
+func NewLogSMSSender() *LogSMSSender {
+	return &LogSMSSender{}
+}
+
+// SendSMS logs the message
+func (s *LogSMSSender) SendSMS(to, message string) error {
+	slog.Info("SMS", "to", to, "message", message)
+	return nil
+}
+
+// FileSMSSender is a development SMSSender that appends messages to a file, which
+// is convenient for picking up codes in manual or automated tests
+type FileSMSSender struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFileSMSSender creates an SMS sender that writes to the file at path
+func NewFileSMSSender(path string) *FileSMSSender {
+	return &FileSMSSender{path: path}
+}
+
+// SendSMS appends the message to the file
+func (s *FileSMSSender) SendSMS(to, message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open SMS log file: %w", err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "%s\t%s\t%s\n", time.Now().Format(time.RFC3339), to, message); err != nil {
+		return fmt.Errorf("failed to write SMS log file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,70 @@
+package oidc
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. Profile, email and
+// phone claims are only included when the matching scope was granted.
+type IDTokenClaims struct {
+	Nonce    string `json:"nonce,omitempty"`
+	AuthTime int64  `json:"auth_time,omitempty"`
+	UserClaims
+	jwt.RegisteredClaims
+}
+
+// UserClaims are the standard claims describing a user, returned in ID tokens and
+// by the userinfo endpoint
+type UserClaims struct {
+	Name                string          `json:"name,omitempty"`
+	Role                models.UserRole `json:"role,omitempty"`
+	Email               string          `json:"email,omitempty"`
+	EmailVerified       *bool           `json:"email_verified,omitempty"`
+	PhoneNumber         string          `json:"phone_number,omitempty"`
+	PhoneNumberVerified *bool           `json:"phone_number_verified,omitempty"`
+}
+
+// userClaims builds the claims for the given scopes from the user model
+func userClaims(user *models.User, scope string) UserClaims {
+	var claims UserClaims
+
+	if hasScope(scope, ScopeProfile) {
+		claims.Name = user.Name
+		claims.Role = user.Role
+	}
+	if hasScope(scope, ScopeEmail) {
+		emailVerified := user.EmailVerified
+		claims.Email = user.Email
+		claims.EmailVerified = &emailVerified
+	}
+	if hasScope(scope, ScopePhone) && user.PhoneNumber != "" {
+		phoneVerified := user.PhoneVerified
+		claims.PhoneNumber = user.PhoneNumber
+		claims.PhoneNumberVerified = &phoneVerified
+	}
+
+	return claims
+}
+
+// idToken signs an ID token for the user with the token manager's active key
+func (p *Provider) idToken(user *models.User, clientID string, code *models.AuthorizationCode) (string, error) {
+	now := time.Now()
+
+	claims := &IDTokenClaims{
+		Nonce:      code.Nonce,
+		AuthTime:   code.AuthTime.Unix(),
+		UserClaims: userClaims(user, code.Scope),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    p.issuer,
+			Subject:   user.ID,
+			Audience:  jwt.ClaimStrings{clientID},
+			ExpiresAt: jwt.NewNumericDate(now.Add(p.tokenManager.TokenTTL())),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return p.tokenManager.SignClaims(claims)
+}
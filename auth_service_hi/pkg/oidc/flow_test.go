@@ -0,0 +1,354 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// testVerifier is the RFC 7636 appendix B verifier for the challenge in authorizeForm
+const testVerifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+// signIn submits the hosted login form and returns the authorization code from the
+// redirect back to the client
+func signIn(t *testing.T, p *Provider, form url.Values) string {
+	t.Helper()
+
+	form.Set(csrfFieldName, "csrf-token")
+	r := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: "csrf-token"})
+
+	w := httptest.NewRecorder()
+	p.AuthorizeHandler(w, r)
+	return codeFromRedirect(t, w)
+}
+
+// codeFromRedirect returns the authorization code from a redirect to the client
+func codeFromRedirect(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	if w.Code != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d: %s", w.Code, http.StatusFound, w.Body.String())
+	}
+	location, err := url.Parse(w.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := location.Query().Get("state"); got != "state-123" {
+		t.Errorf("state = %q, want %q", got, "state-123")
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("redirect %s has no authorization code", location)
+	}
+	return code
+}
+
+// postToken sends a token request and returns the recorded response
+func postToken(p *Provider, form url.Values) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	w := httptest.NewRecorder()
+	p.TokenHandler(w, r)
+	return w
+}
+
+// codeExchange returns the token request that redeems code for client
+func codeExchange(client *models.OAuthClient, code string) url.Values {
+	return url.Values{
+		"grant_type":    {models.GrantAuthorizationCode},
+		"client_id":     {client.ID},
+		"code":          {code},
+		"redirect_uri":  {"https://app.example.com/callback"},
+		"code_verifier": {testVerifier},
+	}
+}
+
+// exchangeTokens redeems code for client and decodes the token response
+func exchangeTokens(t *testing.T, p *Provider, client *models.OAuthClient, code string) *models.TokenResponse {
+	t.Helper()
+
+	w := postToken(p, codeExchange(client, code))
+	if w.Code != http.StatusOK {
+		t.Fatalf("token status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+	var tokens models.TokenResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &tokens); err != nil {
+		t.Fatal(err)
+	}
+	return &tokens
+}
+
+// wantTokenError checks that a token request failed with the given OAuth error code
+func wantTokenError(t *testing.T, w *httptest.ResponseRecorder, code string) {
+	t.Helper()
+
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || body.Error != code {
+		t.Fatalf("token response = %d %s, want %d %s", w.Code, body.Error, http.StatusBadRequest, code)
+	}
+}
+
+// parseIDToken verifies an ID token's signature and returns its claims
+func parseIDToken(t *testing.T, idToken string) *IDTokenClaims {
+	t.Helper()
+
+	claims := &IDTokenClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(testSecret), nil
+	})
+	if err != nil {
+		t.Fatalf("invalid ID token: %v", err)
+	}
+	return claims
+}
+
+// getUserInfo calls the userinfo endpoint with an access token
+func getUserInfo(p *Provider, accessToken string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/oauth/userinfo", nil)
+	r.Header.Set("Authorization", "Bearer "+accessToken)
+
+	w := httptest.NewRecorder()
+	p.UserInfoHandler(w, r)
+	return w
+}
+
+// registerClient stores another client with the same redirect URI as the test client
+func registerClient(t *testing.T, p *Provider, name string) *models.OAuthClient {
+	t.Helper()
+
+	client, err := NewClient(name, []string{"https://app.example.com/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.store.CreateOAuthClient(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+
+	form := authorizeForm(client)
+	form.Set("scope", "openid email")
+	form.Set("nonce", "nonce-456")
+	before := time.Now().Add(-time.Second)
+	tokens := exchangeTokens(t, p, client, signIn(t, p, form))
+
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.IDToken == "" {
+		t.Fatalf("token response is missing tokens: %+v", tokens)
+	}
+	if tokens.Scope != "openid email" {
+		t.Errorf("scope = %q, want %q", tokens.Scope, "openid email")
+	}
+
+	claims := parseIDToken(t, tokens.IDToken)
+	if claims.Issuer != "https://auth.example.com" {
+		t.Errorf("iss = %q, want %q", claims.Issuer, "https://auth.example.com")
+	}
+	if claims.Subject != "customer_1" {
+		t.Errorf("sub = %q, want %q", claims.Subject, "customer_1")
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != client.ID {
+		t.Errorf("aud = %v, want [%s]", claims.Audience, client.ID)
+	}
+	if claims.Nonce != "nonce-456" {
+		t.Errorf("nonce = %q, want %q", claims.Nonce, "nonce-456")
+	}
+	if claims.AuthTime < before.Unix() || claims.AuthTime > time.Now().Unix() {
+		t.Errorf("auth_time = %d, want the time of the login", claims.AuthTime)
+	}
+	if claims.Email != "user@example.com" {
+		t.Errorf("email = %q, want %q", claims.Email, "user@example.com")
+	}
+	if claims.Name != "" {
+		t.Errorf("name = %q without the profile scope", claims.Name)
+	}
+
+	t.Run("userinfo returns the granted claims", func(t *testing.T) {
+		w := getUserInfo(p, tokens.AccessToken)
+		if w.Code != http.StatusOK {
+			t.Fatalf("userinfo status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		var userInfo map[string]interface{}
+		if err := json.Unmarshal(w.Body.Bytes(), &userInfo); err != nil {
+			t.Fatal(err)
+		}
+		if userInfo["sub"] != "customer_1" || userInfo["email"] != "user@example.com" {
+			t.Errorf("userinfo = %v, want sub and email", userInfo)
+		}
+		for _, claim := range []string{"name", "role", "phone_number"} {
+			if _, ok := userInfo[claim]; ok {
+				t.Errorf("userinfo returned %s, which was not granted", claim)
+			}
+		}
+	})
+
+	t.Run("refresh keeps the granted scope", func(t *testing.T) {
+		w := postToken(p, url.Values{
+			"grant_type":    {models.GrantRefreshToken},
+			"client_id":     {client.ID},
+			"refresh_token": {tokens.RefreshToken},
+		})
+		if w.Code != http.StatusOK {
+			t.Fatalf("refresh status = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+		}
+		var refreshed models.TokenResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &refreshed); err != nil {
+			t.Fatal(err)
+		}
+
+		w = getUserInfo(p, refreshed.AccessToken)
+		if w.Code != http.StatusOK || strings.Contains(w.Body.String(), `"name"`) {
+			t.Errorf("userinfo after refresh = %d %s, want only the granted claims", w.Code, w.Body.String())
+		}
+	})
+}
+
+func TestAuthorizationCodeReplay(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+
+	code := signIn(t, p, authorizeForm(client))
+	exchangeTokens(t, p, client, code)
+
+	wantTokenError(t, postToken(p, codeExchange(client, code)), "invalid_grant")
+}
+
+func TestAuthorizationCodeWrongClient(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+	other := registerClient(t, p, "Other App")
+
+	code := signIn(t, p, authorizeForm(client))
+	wantTokenError(t, postToken(p, codeExchange(other, code)), "invalid_grant")
+
+	// The failed attempt consumed the code, so it cannot be retried
+	wantTokenError(t, postToken(p, codeExchange(client, code)), "invalid_grant")
+}
+
+func TestAuthorizationCodeWrongVerifier(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+
+	form := codeExchange(client, signIn(t, p, authorizeForm(client)))
+	form.Set("code_verifier", strings.Repeat("a", 43))
+	wantTokenError(t, postToken(p, form), "invalid_grant")
+}
+
+func TestRefreshTokenBoundToClient(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+	other := registerClient(t, p, "Other App")
+
+	tokens := exchangeTokens(t, p, client, signIn(t, p, authorizeForm(client)))
+	firstParty, err := p.authService.Login(context.Background(), models.LoginRequest{
+		Email:    "user@example.com",
+		Password: testPassword,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		client       *models.OAuthClient
+		refreshToken string
+	}{
+		{"another client's token", other, tokens.RefreshToken},
+		{"first-party token", client, firstParty.RefreshToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wantTokenError(t, postToken(p, url.Values{
+				"grant_type":    {models.GrantRefreshToken},
+				"client_id":     {tt.client.ID},
+				"refresh_token": {tt.refreshToken},
+			}), "invalid_grant")
+		})
+	}
+
+	// Neither rejected attempt used up the token
+	w := postToken(p, url.Values{
+		"grant_type":    {models.GrantRefreshToken},
+		"client_id":     {client.ID},
+		"refresh_token": {tokens.RefreshToken},
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("refresh by the owning client = %d, want %d: %s", w.Code, http.StatusOK, w.Body.String())
+	}
+
+	// A client's refresh token cannot be redeemed at the first-party endpoint either
+	if _, err := p.authService.Refresh(context.Background(), tokens.RefreshToken, ""); err != auth.ErrInvalidRefreshToken {
+		t.Errorf("first-party refresh of a client token: err = %v, want %v", err, auth.ErrInvalidRefreshToken)
+	}
+}
+
+func TestUserInfoRequiresOpenIDScope(t *testing.T) {
+	p, _, _ := newTestProvider(t)
+
+	firstParty, err := p.authService.Login(context.Background(), models.LoginRequest{
+		Email:    "user@example.com",
+		Password: testPassword,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := getUserInfo(p, firstParty.Token)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("userinfo with a first-party token = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(w.Header().Get("WWW-Authenticate"), "insufficient_scope") {
+		t.Errorf("WWW-Authenticate = %q, want insufficient_scope", w.Header().Get("WWW-Authenticate"))
+	}
+}
+
+func TestSingleSignOnAuthTime(t *testing.T) {
+	p, client, store := newTestProvider(t)
+
+	// A session that started an hour ago, with an access token minted just now
+	// by a refresh
+	signedIn := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := store.SaveSession(context.Background(), &models.Session{
+		ID:        "session_1",
+		UserID:    "customer_1",
+		ExpiresAt: time.Now().Add(time.Hour),
+		CreatedAt: signedIn,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	accessToken, _, err := p.tokenManager.GenerateToken("customer_1", models.RoleCustomer, "session_1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+authorizeForm(client).Encode(), nil)
+	cookies := httptest.NewRecorder()
+	auth.SetAuthCookies(cookies, &models.AuthResponse{Token: accessToken})
+	for _, cookie := range cookies.Result().Cookies() {
+		r.AddCookie(cookie)
+	}
+
+	w := httptest.NewRecorder()
+	p.AuthorizeHandler(w, r)
+	tokens := exchangeTokens(t, p, client, codeFromRedirect(t, w))
+
+	if got := parseIDToken(t, tokens.IDToken).AuthTime; got != signedIn.Unix() {
+		t.Errorf("auth_time = %d, want the session's login time %d", got, signedIn.Unix())
+	}
+}
@@ -0,0 +1,485 @@
+package oidc
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+// authorizeRequest holds the parameters of an authorization request. They are read
+// from the query string on GET and carried through the login form on POST.
+type authorizeRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+}
+
+// parseAuthorizeRequest reads the authorization parameters from a parsed form
+func parseAuthorizeRequest(form url.Values) *authorizeRequest {
+	return &authorizeRequest{
+		ClientID:            form.Get("client_id"),
+		RedirectURI:         form.Get("redirect_uri"),
+		ResponseType:        form.Get("response_type"),
+		Scope:               normalizeScope(form.Get("scope")),
+		State:               form.Get("state"),
+		Nonce:               form.Get("nonce"),
+		CodeChallenge:       form.Get("code_challenge"),
+		CodeChallengeMethod: form.Get("code_challenge_method"),
+		Prompt:              form.Get("prompt"),
+	}
+}
+
+// validate checks the parameters that are reported back to the client. It returns
+// an OAuth error code and description, or empty strings if the request is valid.
+func (req *authorizeRequest) validate() (string, string) {
+	if req.ResponseType != "code" {
+		return "unsupported_response_type", "only the authorization code flow is supported"
+	}
+	if !hasScope(req.Scope, ScopeOpenID) {
+		return "invalid_scope", "the openid scope is required"
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != pkceMethodS256 {
+		return "invalid_request", "PKCE with code_challenge_method=S256 is required"
+	}
+	switch req.Prompt {
+	case "", "none", "login":
+	default:
+		return "invalid_request", "unsupported prompt value"
+	}
+	return "", ""
+}
+
+// hiddenFields returns the parameters to carry through the login form
+func (req *authorizeRequest) hiddenFields() []hiddenField {
+	return []hiddenField{
+		{"client_id", req.ClientID},
+		{"redirect_uri", req.RedirectURI},
+		{"response_type", req.ResponseType},
+		{"scope", req.Scope},
+		{"state", req.State},
+		{"nonce", req.Nonce},
+		{"code_challenge", req.CodeChallenge},
+		{"code_challenge_method", req.CodeChallengeMethod},
+	}
+}
+
+// DiscoveryHandler serves the OpenID Provider metadata document
+func (p *Provider) DiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		auth.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	auth.RespondWithJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.issuer,
+		"authorization_endpoint":                p.issuer + "/oauth/authorize",
+		"token_endpoint":                        p.issuer + "/oauth/token",
+		"userinfo_endpoint":                     p.issuer + "/oauth/userinfo",
+		"introspection_endpoint":                p.issuer + "/oauth/introspect",
+		"jwks_uri":                              p.issuer + "/.well-known/jwks.json",
+		"response_types_supported":              []string{"code"},
+		"grant_types_supported":                 []string{models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.tokenManager.KeyRing().Active().Method.Alg()},
+		"scopes_supported":                      supportedScopes,
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce",
+			"name", "role", "email", "email_verified", "phone_number", "phone_number_verified",
+		},
+		"code_challenge_methods_supported":               []string{pkceMethodS256},
+		"token_endpoint_auth_methods_supported":          []string{"none", "client_secret_basic", "client_secret_post"},
+		"prompt_values_supported":                        []string{"none", "login"},
+		"authorization_response_iss_parameter_supported": true,
+	})
+}
+
+// AuthorizeHandler starts the authorization code flow. GET shows the hosted login
+// page, or redirects straight back with a code if the browser already has a valid
+// session; POST checks the submitted credentials.
+func (p *Provider) AuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		auth.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		p.renderError(w, r, http.StatusBadRequest, "The sign-in request is malformed.")
+		return
+	}
+	req := parseAuthorizeRequest(r.Form)
+
+	// Never redirect to an unverified URI; show the error to the user instead
+	client, err := p.lookupClient(r.Context(), req.ClientID, req.RedirectURI)
+	switch err {
+	case nil:
+	case ErrUnknownClient, ErrInvalidRedirectURI:
+		p.renderError(w, r, http.StatusBadRequest, "The application that sent you here is not allowed to sign in: "+err.Error()+".")
+		return
+	default:
+		slog.ErrorContext(r.Context(), "Failed to look up OAuth client", "error", err)
+		p.renderError(w, r, http.StatusInternalServerError, "Something went wrong, please try again.")
+		return
+	}
+
+	if !client.AllowsGrantType(models.GrantAuthorizationCode) {
+		p.redirectWithError(w, r, req, "unauthorized_client", "the client may not use the authorization code flow")
+		return
+	}
+
+	if code, description := req.validate(); code != "" {
+		p.redirectWithError(w, r, req, code, description)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		p.handleLogin(w, r, client, req)
+		return
+	}
+
+	// Single sign-on: reuse the session from an earlier login on this page. The
+	// access token is re-minted on every refresh, so the time the user signed in
+	// comes from the session.
+	if req.Prompt != "login" {
+		if token := auth.SessionCookieToken(r); token != "" {
+			if user, claims, err := p.authService.ValidateToken(r.Context(), token); err == nil {
+				if session, err := p.authService.GetSession(r.Context(), claims.SessionID); err == nil {
+					p.completeAuthorization(w, r, req, user.ID, session.ID, session.CreatedAt)
+					return
+				}
+			}
+		}
+	}
+
+	if req.Prompt == "none" {
+		p.redirectWithError(w, r, req, "login_required", "the user is not signed in")
+		return
+	}
+
+	p.renderLogin(w, r, http.StatusOK, &loginPage{Client: client, Fields: req.hiddenFields()})
+}
+
+// handleLogin checks the credentials posted from the hosted login page. The form
+// must carry the browser's CSRF token, so that another site cannot sign the user in
+// to an account of its choosing.
+func (p *Provider) handleLogin(w http.ResponseWriter, r *http.Request, client *models.OAuthClient, req *authorizeRequest) {
+	loginReq := models.LoginRequest{
+		Email:    strings.TrimSpace(r.PostForm.Get("email")),
+		Password: r.PostForm.Get("password"),
+		OTPCode:  strings.TrimSpace(r.PostForm.Get("otp_code")),
+	}
+
+	if !validCSRFToken(r) {
+		slog.WarnContext(r.Context(), "Hosted login rejected: missing or invalid CSRF token", "client_id", client.ID)
+		p.renderLogin(w, r, http.StatusForbidden, &loginPage{
+			Client: client,
+			Fields: req.hiddenFields(),
+			Email:  loginReq.Email,
+			Error:  "Your sign-in form has expired. Please try again.",
+		})
+		return
+	}
+
+	authResponse, err := p.authService.Login(r.Context(), loginReq)
+	if err != nil {
+		p.authService.Audit(r, models.AuthEvent{
+			Type:   models.EventLogin,
+			Email:  loginReq.Email,
+			Detail: "hosted login for client " + client.ID,
+		}, err)
+
+		page := &loginPage{Client: client, Fields: req.hiddenFields(), Email: loginReq.Email}
+		status := http.StatusUnauthorized
+
+		var locked *auth.AccountLockedError
+		if errors.As(err, &locked) {
+			page.Error = "Too many failed attempts. Try again after " + locked.Until.UTC().Format("15:04 MST") + "."
+			w.Header().Set("Retry-After", strconv.Itoa(locked.RetryAfter()))
+			p.renderLogin(w, r, http.StatusLocked, page)
+			return
+		}
+
+		switch err {
+		case auth.ErrInvalidCredentials:
+			page.Error = "Invalid email or password."
+		case auth.ErrOTPRequired:
+			page.Error = "Enter the code from your authenticator app."
+			page.ShowOTP = true
+		case auth.ErrInvalidOTP:
+			page.Error = "Invalid authentication code."
+			page.ShowOTP = true
+		case auth.ErrVerificationRequired:
+			page.Error = "Please verify your email address before signing in."
+			status = http.StatusForbidden
+		case auth.ErrAccountDisabled:
+			page.Error = "This account has been disabled."
+			status = http.StatusForbidden
+		default:
+			slog.ErrorContext(r.Context(), "Hosted login failed", "error", err)
+			page.Error = "Something went wrong, please try again."
+			status = http.StatusInternalServerError
+		}
+
+		p.renderLogin(w, r, status, page)
+		return
+	}
+
+	p.authService.Audit(r, models.AuthEvent{
+		Type:         models.EventLogin,
+		ActorID:      authResponse.User.ID,
+		TargetUserID: authResponse.User.ID,
+		Email:        authResponse.User.Email,
+		Detail:       "hosted login for client " + client.ID,
+	}, nil)
+
+	// Remember the login so that other applications can sign in without a prompt
+	auth.SetAuthCookies(w, authResponse)
+
+	p.completeAuthorization(w, r, req, authResponse.User.ID, authResponse.SessionID, time.Now())
+}
+
+// completeAuthorization issues a code and sends the browser back to the client
+func (p *Provider) completeAuthorization(w http.ResponseWriter, r *http.Request, req *authorizeRequest, userID, sessionID string, authTime time.Time) {
+	code, err := p.issueCode(r.Context(), req, userID, sessionID, authTime)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to issue authorization code", "error", err)
+		p.redirectWithError(w, r, req, "server_error", "failed to issue authorization code")
+		return
+	}
+
+	p.redirect(w, r, req, url.Values{"code": {code}})
+}
+
+// redirectWithError reports an authorization error to the client (RFC 6749 section 4.1.2.1)
+func (p *Provider) redirectWithError(w http.ResponseWriter, r *http.Request, req *authorizeRequest, code, description string) {
+	p.redirect(w, r, req, url.Values{"error": {code}, "error_description": {description}})
+}
+
+// redirect sends the browser to the client's redirect URI with the given parameters,
+// the state, and the issuer (RFC 9207) added to its query string
+func (p *Provider) redirect(w http.ResponseWriter, r *http.Request, req *authorizeRequest, params url.Values) {
+	target, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		p.renderError(w, r, http.StatusBadRequest, "The redirect URI is invalid.")
+		return
+	}
+
+	query := target.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	query.Set("iss", p.issuer)
+	target.RawQuery = query.Encode()
+
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// TokenHandler exchanges an authorization code or a refresh token for tokens, and
+// issues tokens to machine clients with the client credentials grant
+func (p *Provider) TokenHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		auth.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		respondWithTokenError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	client, err := p.authenticateClient(r)
+	switch err {
+	case nil:
+	case ErrInvalidClient:
+		if r.Header.Get("Authorization") != "" {
+			w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+		}
+		respondWithTokenError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	default:
+		slog.ErrorContext(r.Context(), "Failed to authenticate OAuth client", "error", err)
+		respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if grantType != "" && !client.AllowsGrantType(grantType) {
+		respondWithTokenError(w, http.StatusBadRequest, "unauthorized_client", "the client may not use this grant type")
+		return
+	}
+
+	switch grantType {
+	case models.GrantAuthorizationCode:
+		tokens, err := p.exchangeCode(
+			r.Context(),
+			r.PostForm.Get("code"),
+			client.ID,
+			r.PostForm.Get("redirect_uri"),
+			r.PostForm.Get("code_verifier"),
+		)
+		switch err {
+		case nil:
+			auth.RespondWithJSON(w, http.StatusOK, tokens)
+		case ErrInvalidGrant, ErrInvalidCodeVerifier:
+			respondWithTokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "Failed to exchange authorization code", "error", err)
+			respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+	case models.GrantClientCredentials:
+		tokens, err := p.clientCredentials(client, r.PostForm.Get("scope"))
+		switch err {
+		case nil:
+			auth.RespondWithJSON(w, http.StatusOK, tokens)
+		case ErrInvalidScope:
+			respondWithTokenError(w, http.StatusBadRequest, "invalid_scope", err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "Failed to issue client token", "error", err)
+			respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+	case models.GrantRefreshToken:
+		// Refresh tokens are bound to the client they were issued to (RFC 6749 section 6)
+		authResponse, err := p.authService.Refresh(r.Context(), r.PostForm.Get("refresh_token"), client.ID)
+		switch err {
+		case nil:
+			auth.RespondWithJSON(w, http.StatusOK, &models.TokenResponse{
+				AccessToken:  authResponse.Token,
+				TokenType:    "Bearer",
+				ExpiresIn:    int64(time.Until(authResponse.ExpiresAt).Seconds()),
+				RefreshToken: authResponse.RefreshToken,
+			})
+		case auth.ErrInvalidRefreshToken, auth.ErrRefreshTokenReused:
+			respondWithTokenError(w, http.StatusBadRequest, "invalid_grant", err.Error())
+		default:
+			slog.ErrorContext(r.Context(), "Failed to refresh token", "error", err)
+			respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		}
+
+	default:
+		respondWithTokenError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+	}
+}
+
+// IntrospectHandler tells a resource server whether a token is active (RFC 7662).
+// Callers must authenticate as a confidential client, so that the endpoint cannot
+// be used to probe tokens anonymously.
+func (p *Provider) IntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		auth.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+
+	if err := r.ParseForm(); err != nil {
+		respondWithTokenError(w, http.StatusBadRequest, "invalid_request", "malformed form body")
+		return
+	}
+
+	client, err := p.authenticateClient(r)
+	if err != nil && err != ErrInvalidClient {
+		slog.ErrorContext(r.Context(), "Failed to authenticate OAuth client", "error", err)
+		respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	if err == ErrInvalidClient || !client.IsConfidential() {
+		w.Header().Set("WWW-Authenticate", `Basic realm="introspect"`)
+		respondWithTokenError(w, http.StatusUnauthorized, "invalid_client", "client authentication required")
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		respondWithTokenError(w, http.StatusBadRequest, "invalid_request", "token is required")
+		return
+	}
+
+	// token_type_hint is optional and ignored; only access tokens can be introspected
+	response, err := p.introspect(r.Context(), token)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Failed to introspect token", "error", err)
+		respondWithTokenError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	auth.RespondWithJSON(w, http.StatusOK, response)
+}
+
+// UserInfoHandler returns the claims of the user an access token was issued to,
+// limited to the scopes the user granted to the client. The token must have been
+// issued through OpenID Connect with the openid scope.
+func (p *Provider) UserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		auth.RespondWithError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" || token == r.Header.Get("Authorization") {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+		auth.RespondWithError(w, http.StatusUnauthorized, "Bearer token required")
+		return
+	}
+
+	user, claims, err := p.authService.ValidateToken(r.Context(), token)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		auth.RespondWithError(w, http.StatusUnauthorized, "Invalid or expired token")
+		return
+	}
+
+	if !claims.HasScope(ScopeOpenID) {
+		w.Header().Set("WWW-Authenticate", `Bearer error="insufficient_scope", scope="openid"`)
+		auth.RespondWithError(w, http.StatusForbidden, "Token was not granted the openid scope")
+		return
+	}
+
+	auth.RespondWithJSON(w, http.StatusOK, struct {
+		Subject string `json:"sub"`
+		UserClaims
+	}{
+		Subject:    user.ID,
+		UserClaims: userClaims(user, claims.Scope),
+	})
+}
+
+// respondWithTokenError sends an OAuth 2.0 error response (RFC 6749 section 5.2)
+func respondWithTokenError(w http.ResponseWriter, status int, code, description string) {
+	body := map[string]string{"error": code}
+	if description != "" {
+		body["error_description"] = description
+	}
+	auth.RespondWithJSON(w, status, body)
+}
+
+// SetupRoutes registers the OpenID Connect endpoints
+func (p *Provider) SetupRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/.well-known/openid-configuration", auth.EnableCORS(p.DiscoveryHandler))
+	// The authorize endpoint is a browser navigation, never a cross-origin fetch
+	mux.HandleFunc("/oauth/authorize", p.AuthorizeHandler)
+	mux.HandleFunc("/oauth/token", auth.EnableCORS(p.TokenHandler))
+	mux.HandleFunc("/oauth/userinfo", auth.EnableCORS(p.UserInfoHandler))
+	mux.HandleFunc("/oauth/introspect", p.IntrospectHandler)
+}
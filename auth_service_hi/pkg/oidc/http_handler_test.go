@@ -0,0 +1,169 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	testPassword = "correct horse battery staple"
+	testSecret   = "test-secret-that-is-long-enough-123"
+)
+
+// newTestProvider returns a provider with one registered client and one user who
+// signs in with testPassword, together with the store behind both
+func newTestProvider(t *testing.T) (*Provider, *models.OAuthClient, *database.MemoryStore) {
+	t.Helper()
+
+	store := database.NewMemoryStore()
+	tokenManager := utils.NewTokenManager(testSecret, "test", time.Minute)
+	authService := auth.NewAuthService(store, store, tokenManager)
+
+	client, err := NewClient("Test App", []string{"https://app.example.com/callback"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateOAuthClient(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if err := store.CreateUser(context.Background(), &models.User{
+		ID:            "customer_1",
+		Email:         "user@example.com",
+		PasswordHash:  string(hash),
+		Name:          "Test User",
+		Role:          models.RoleCustomer,
+		EmailVerified: true,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewProvider(authService, tokenManager, store, "https://auth.example.com"), client, store
+}
+
+// authorizeForm returns a valid authorization request with the user's credentials
+func authorizeForm(client *models.OAuthClient) url.Values {
+	return url.Values{
+		"client_id":             {client.ID},
+		"redirect_uri":          {"https://app.example.com/callback"},
+		"response_type":         {"code"},
+		"scope":                 {"openid"},
+		"state":                 {"state-123"},
+		"code_challenge":        {"E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"},
+		"code_challenge_method": {pkceMethodS256},
+		"email":                 {"user@example.com"},
+		"password":              {testPassword},
+	}
+}
+
+func TestAuthorizeLoginCSRF(t *testing.T) {
+	p, client, _ := newTestProvider(t)
+
+	// Render the login page to get the browser's CSRF cookie
+	page := httptest.NewRecorder()
+	p.AuthorizeHandler(page, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+authorizeForm(client).Encode(), nil))
+	if page.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want %d", page.Code, http.StatusOK)
+	}
+	var cookie *http.Cookie
+	for _, c := range page.Result().Cookies() {
+		if c.Name == csrfCookieName {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value == "" {
+		t.Fatal("login page did not set a CSRF cookie")
+	}
+	if !strings.Contains(page.Body.String(), `name="csrf_token" value="`+cookie.Value+`"`) {
+		t.Fatal("login form does not carry the CSRF token")
+	}
+
+	tests := []struct {
+		name       string
+		cookie     string
+		token      string
+		wantStatus int
+	}{
+		{"matching token", cookie.Value, cookie.Value, http.StatusFound},
+		{"no token", cookie.Value, "", http.StatusForbidden},
+		{"no cookie", "", cookie.Value, http.StatusForbidden},
+		{"cross-site form", cookie.Value, "attacker-token", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := authorizeForm(client)
+			if tt.token != "" {
+				form.Set(csrfFieldName, tt.token)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: tt.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			p.AuthorizeHandler(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("POST status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusFound {
+				if w.Header().Get("Location") != "" {
+					t.Error("rejected login redirected back to the client")
+				}
+				return
+			}
+
+			location, err := url.Parse(w.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if location.Query().Get("code") == "" {
+				t.Errorf("redirect %s has no authorization code", location)
+			}
+		})
+	}
+}
+
+func TestDiscoveryAuthMethods(t *testing.T) {
+	p, _, _ := newTestProvider(t)
+
+	w := httptest.NewRecorder()
+	p.DiscoveryHandler(w, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+	var metadata struct {
+		AuthMethods []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	// Public clients use none; service clients authenticate with their secret
+	for _, want := range []string{"none", "client_secret_basic", "client_secret_post"} {
+		found := false
+		for _, method := range metadata.AuthMethods {
+			found = found || method == want
+		}
+		if !found {
+			t.Errorf("token_endpoint_auth_methods_supported = %v, missing %s", metadata.AuthMethods, want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const (
+	// csrfCookieName holds the login form's CSRF token. The form posts the same
+	// token back in csrfFieldName; a cross-site form cannot read the cookie, and
+	// SameSite keeps the browser from sending it along with such a form at all.
+	csrfCookieName = "oidc_csrf"
+	csrfFieldName  = "csrf_token"
+)
+
+// hiddenField is an authorization parameter carried through the login form
+type hiddenField struct {
+	Name  string
+	Value string
+}
+
+// loginPage is the data rendered into the hosted login page
+type loginPage struct {
+	Client    *models.OAuthClient
+	Fields    []hiddenField
+	CSRFToken string
+	Email     string
+	ShowOTP   bool
+	Error     string
+}
+
+var loginTemplate = template.Must(template.New("login").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>Sign in - Herb Immortal</title>
+    <style>
+        * { margin: 0; padding: 0; box-sizing: border-box; font-family: Arial, sans-serif; }
+        body { background-color: #f5f5f5; padding: 20px; }
+        .container { max-width: 400px; margin: 40px auto; background-color: white; border-radius: 8px; padding: 30px; box-shadow: 0 2px 10px rgba(0, 0, 0, 0.1); }
+        h1 { text-align: center; margin-bottom: 10px; color: #2c3e50; }
+        .subtitle { text-align: center; margin-bottom: 20px; color: #7f8c8d; }
+        .form-group { margin-bottom: 15px; }
+        label { display: block; margin-bottom: 5px; color: #555; }
+        input { width: 100%; padding: 10px; border: 1px solid #ddd; border-radius: 4px; font-size: 16px; }
+        .btn { background-color: #3498db; color: white; border: none; padding: 10px 15px; border-radius: 4px; cursor: pointer; font-size: 16px; width: 100%; margin-top: 10px; }
+        .btn:hover { background-color: #2980b9; }
+        .message { margin-bottom: 15px; padding: 10px; border-radius: 4px; background-color: #f8d7da; color: #721c24; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        {{if .Client}}
+        <h1>Sign in</h1>
+        <p class="subtitle">to continue to {{.Client.Name}}</p>
+        {{if .Error}}<div class="message">{{.Error}}</div>{{end}}
+        <form method="POST" action="/oauth/authorize">
+            {{range .Fields}}<input type="hidden" name="{{.Name}}" value="{{.Value}}">
+            {{end}}<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+            <div class="form-group">
+                <label for="email">Email</label>
+                <input type="email" id="email" name="email" value="{{.Email}}" autocomplete="username" required {{if not .Email}}autofocus{{end}}>
+            </div>
+            <div class="form-group">
+                <label for="password">Password</label>
+                <input type="password" id="password" name="password" autocomplete="current-password" required {{if .Email}}autofocus{{end}}>
+            </div>
+            {{if .ShowOTP}}
+            <div class="form-group">
+                <label for="otp_code">Authentication Code</label>
+                <input type="text" id="otp_code" name="otp_code" inputmode="numeric" autocomplete="one-time-code" pattern="[0-9]{6}">
+            </div>
+            {{end}}
+            <button type="submit" class="btn">Sign in</button>
+        </form>
+        {{else}}
+        <h1>Sign in failed</h1>
+        <div class="message">{{.Error}}</div>
+        {{end}}
+    </div>
+</body>
+</html>
+`))
+
+// renderLogin writes the hosted login page
+func (p *Provider) renderLogin(w http.ResponseWriter, r *http.Request, status int, page *loginPage) {
+	if page.Client != nil {
+		token, err := csrfToken(w, r)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Failed to generate CSRF token", "error", err)
+			status = http.StatusInternalServerError
+			page = &loginPage{Error: "Something went wrong, please try again."}
+		}
+		page.CSRFToken = token
+	}
+
+	// The page collects credentials, so it must never be framed or cached
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+
+	if err := loginTemplate.Execute(w, page); err != nil {
+		slog.ErrorContext(r.Context(), "Failed to render login page", "error", err)
+	}
+}
+
+// csrfToken returns the browser's login CSRF token, setting a new one if it has none.
+// The token is kept for the browser session so that login forms open in several tabs
+// all stay valid.
+func csrfToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value, nil
+	}
+
+	token, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		HttpOnly: true,
+		Path:     "/oauth/authorize",
+		SameSite: http.SameSiteStrictMode,
+		Secure:   true,
+	})
+	return token, nil
+}
+
+// validCSRFToken reports whether a posted login form carries the browser's CSRF token
+func validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	posted := r.PostForm.Get(csrfFieldName)
+	return subtle.ConstantTimeCompare([]byte(posted), []byte(cookie.Value)) == 1
+}
+
+// renderError shows an error that cannot be sent back to the client
+func (p *Provider) renderError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	p.renderLogin(w, r, status, &loginPage{Error: message})
+}
@@ -0,0 +1,383 @@
+// Package oidc turns the auth service into a minimal OpenID Connect provider. Web
+// apps sign users in with the authorization code flow and PKCE: they send the
+// browser to the hosted login page at /oauth/authorize, exchange the returned code
+// at /oauth/token for an access token, a refresh token and an ID token, and can read
+// the user's claims from /oauth/userinfo.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/auth"
+	"github.com/herb-immortal/auth_service_hi/pkg/database"
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+	"github.com/herb-immortal/auth_service_hi/pkg/utils"
+)
+
+const (
+	// DefaultCodeTTL is how long an authorization code can be exchanged
+	DefaultCodeTTL = 5 * time.Minute
+
+	// pkceMethodS256 is the only PKCE method accepted; "plain" offers no protection
+	// against an intercepted code
+	pkceMethodS256 = "S256"
+)
+
+// Supported scopes. Other requested scopes are ignored.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopePhone   = "phone"
+)
+
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopePhone}
+
+var (
+	ErrUnknownClient       = errors.New("unknown client")
+	ErrInvalidRedirectURI  = errors.New("redirect_uri is not registered for this client")
+	ErrInvalidGrant        = errors.New("invalid or expired authorization grant")
+	ErrInvalidCodeVerifier = errors.New("code_verifier does not match code_challenge")
+	ErrInvalidClient       = errors.New("client authentication failed")
+	ErrInvalidScope        = errors.New("requested scope is not allowed for this client")
+)
+
+// Provider implements the OpenID Connect endpoints on top of an AuthService
+type Provider struct {
+	authService  *auth.AuthService
+	tokenManager *utils.TokenManager
+	store        database.OAuthStore
+	issuer       string
+	codeTTL      time.Duration
+}
+
+// NewProvider creates an OpenID Connect provider. The issuer must be the externally
+// reachable base URL of the service; it is the iss claim of ID tokens and the
+// prefix of every endpoint in the discovery document.
+func NewProvider(authService *auth.AuthService, tokenManager *utils.TokenManager, store database.OAuthStore, issuer string) *Provider {
+	return &Provider{
+		authService:  authService,
+		tokenManager: tokenManager,
+		store:        store,
+		issuer:       strings.TrimSuffix(issuer, "/"),
+		codeTTL:      DefaultCodeTTL,
+	}
+}
+
+// NewClient creates a client that may redirect to the given URIs. Redirect URIs must
+// be absolute and must not contain a fragment (RFC 6749 section 3.1.2).
+func NewClient(name string, redirectURIs []string) (*models.OAuthClient, error) {
+	if name == "" {
+		return nil, errors.New("client name is required")
+	}
+	if len(redirectURIs) == 0 {
+		return nil, errors.New("at least one redirect URI is required")
+	}
+	for _, uri := range redirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || u.Scheme == "" || u.Host == "" || u.Fragment != "" {
+			return nil, fmt.Errorf("invalid redirect URI %q: must be absolute and have no fragment", uri)
+		}
+	}
+
+	clientID, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.OAuthClient{
+		ID:           clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		GrantTypes:   []string{models.GrantAuthorizationCode, models.GrantRefreshToken},
+		CreatedAt:    time.Now(),
+	}, nil
+}
+
+// NewServiceClient creates a machine client that may request tokens with the given
+// scopes through the client credentials grant. It returns the client together with
+// its secret, which is only stored hashed and cannot be recovered later.
+func NewServiceClient(name string, scopes []string) (*models.OAuthClient, string, error) {
+	if name == "" {
+		return nil, "", errors.New("client name is required")
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("at least one scope is required")
+	}
+	for _, scope := range scopes {
+		if scope == "" || strings.ContainsAny(scope, " \t\"\\") {
+			return nil, "", fmt.Errorf("invalid scope %q", scope)
+		}
+	}
+
+	clientID, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := utils.GenerateSecureToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &models.OAuthClient{
+		ID:         clientID,
+		Name:       name,
+		SecretHash: utils.HashToken(secret),
+		Scopes:     scopes,
+		GrantTypes: []string{models.GrantClientCredentials},
+		CreatedAt:  time.Now(),
+	}, secret, nil
+}
+
+// lookupClient returns the client and checks that redirectURI is registered for it
+func (p *Provider) lookupClient(ctx context.Context, clientID, redirectURI string) (*models.OAuthClient, error) {
+	if clientID == "" {
+		return nil, ErrUnknownClient
+	}
+
+	client, err := p.store.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrUnknownClient
+	}
+
+	if !client.AllowsRedirectURI(redirectURI) {
+		return nil, ErrInvalidRedirectURI
+	}
+
+	return client, nil
+}
+
+// issueCode stores a new authorization code for the session and returns it
+func (p *Provider) issueCode(ctx context.Context, req *authorizeRequest, userID, sessionID string, authTime time.Time) (string, error) {
+	code, err := utils.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	record := &models.AuthorizationCode{
+		CodeHash:            utils.HashToken(code),
+		ClientID:            req.ClientID,
+		UserID:              userID,
+		SessionID:           sessionID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		Nonce:               req.Nonce,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		AuthTime:            authTime,
+		ExpiresAt:           now.Add(p.codeTTL),
+		CreatedAt:           now,
+	}
+	if err := p.store.SaveAuthorizationCode(ctx, record); err != nil {
+		return "", err
+	}
+
+	return code, nil
+}
+
+// exchangeCode redeems an authorization code and returns tokens for its session
+func (p *Provider) exchangeCode(ctx context.Context, code, clientID, redirectURI, codeVerifier string) (*models.TokenResponse, error) {
+	record, err := p.store.ConsumeAuthorizationCode(ctx, utils.HashToken(code))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.ExpiresAt.Before(time.Now()) {
+		return nil, ErrInvalidGrant
+	}
+	if record.ClientID != clientID || record.RedirectURI != redirectURI {
+		return nil, ErrInvalidGrant
+	}
+	if !verifyCodeChallenge(record.CodeChallenge, codeVerifier) {
+		return nil, ErrInvalidCodeVerifier
+	}
+
+	authResponse, err := p.authService.IssueSessionTokens(ctx, record.SessionID, clientID, record.Scope)
+	if err != nil {
+		if err == auth.ErrInvalidSession {
+			return nil, ErrInvalidGrant
+		}
+		return nil, err
+	}
+
+	idToken, err := p.idToken(&authResponse.User, clientID, record)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken:  authResponse.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(time.Until(authResponse.ExpiresAt).Seconds()),
+		RefreshToken: authResponse.RefreshToken,
+		IDToken:      idToken,
+		Scope:        record.Scope,
+	}, nil
+}
+
+// authenticateClient identifies the client making a token request. Confidential
+// clients authenticate with HTTP Basic (client_secret_basic) or with client_id and
+// client_secret form parameters (client_secret_post); public clients only send
+// their client_id.
+func (p *Provider) authenticateClient(r *http.Request) (*models.OAuthClient, error) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// Credentials in the Basic header are form-encoded (RFC 6749 section 2.3.1)
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return nil, ErrInvalidClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return nil, ErrInvalidClient
+		}
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	if clientID == "" {
+		return nil, ErrInvalidClient
+	}
+
+	client, err := p.store.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrInvalidClient
+	}
+
+	if client.IsConfidential() {
+		hash := utils.HashToken(secret)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash)) != 1 {
+			return nil, ErrInvalidClient
+		}
+	}
+
+	return client, nil
+}
+
+// clientCredentials issues a token to a machine client for the requested scopes, or
+// for every scope the client is allowed when none are requested
+func (p *Provider) clientCredentials(client *models.OAuthClient, requestedScope string) (*models.TokenResponse, error) {
+	scopes := strings.Fields(requestedScope)
+	if len(scopes) == 0 {
+		scopes = client.Scopes
+	}
+	for _, scope := range scopes {
+		if !client.AllowsScope(scope) {
+			return nil, ErrInvalidScope
+		}
+	}
+	scope := strings.Join(scopes, " ")
+
+	token, expiresAt, err := p.tokenManager.GenerateClientToken(client.ID, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(time.Until(expiresAt).Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// introspect reports whether an access token is active. User tokens are only active
+// while the session they were issued for exists; client tokens while the client is
+// still registered.
+func (p *Provider) introspect(ctx context.Context, token string) (*models.IntrospectionResponse, error) {
+	inactive := &models.IntrospectionResponse{Active: false}
+
+	claims, err := p.tokenManager.ValidateToken(token)
+	if err != nil {
+		return inactive, nil
+	}
+
+	if claims.IsClientToken() {
+		client, err := p.store.GetOAuthClient(ctx, claims.ClientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil {
+			return inactive, nil
+		}
+	} else {
+		// Check the backing session and user
+		_, _, err := p.authService.ValidateToken(ctx, token)
+		if err == auth.ErrInvalidSession {
+			return inactive, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	response := &models.IntrospectionResponse{
+		Active:    true,
+		Subject:   claims.UserID,
+		Role:      claims.Role,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: "Bearer",
+		Issuer:    claims.Issuer,
+		TokenID:   claims.ID,
+	}
+	if claims.ExpiresAt != nil {
+		response.ExpiresAt = claims.ExpiresAt.Unix()
+	}
+	if claims.IssuedAt != nil {
+		response.IssuedAt = claims.IssuedAt.Unix()
+	}
+
+	return response, nil
+}
+
+// verifyCodeChallenge checks a PKCE code_verifier against an S256 code_challenge
+// (RFC 7636 section 4.6)
+func verifyCodeChallenge(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// normalizeScope drops unsupported and duplicate scopes, keeping the request order
+func normalizeScope(scope string) string {
+	var granted []string
+	for _, s := range strings.Fields(scope) {
+		if containsScope(supportedScopes, s) && !containsScope(granted, s) {
+			granted = append(granted, s)
+		}
+	}
+	return strings.Join(granted, " ")
+}
+
+// hasScope reports whether a space-separated scope string contains scope
+func hasScope(scopes, scope string) bool {
+	return containsScope(strings.Fields(scopes), scope)
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
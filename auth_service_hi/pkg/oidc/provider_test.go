@@ -0,0 +1,71 @@
+package oidc
+
+import (
+	"context"
+	"testing"
+)
+
+func TestVerifyCodeChallenge(t *testing.T) {
+	// The example from RFC 7636 appendix B
+	const (
+		verifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+		challenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	)
+
+	tests := []struct {
+		name      string
+		challenge string
+		verifier  string
+		want      bool
+	}{
+		{"RFC 7636 example", challenge, verifier, true},
+		{"wrong verifier", challenge, "eBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk", false},
+		{"verifier sent as the challenge", challenge, challenge, false},
+		{"padded challenge", challenge + "=", verifier, false},
+		{"verifier too short", challenge, verifier[:42], false},
+		{"verifier too long", challenge, verifier + verifier + verifier, false},
+		{"empty", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := verifyCodeChallenge(tt.challenge, tt.verifier); got != tt.want {
+				t.Errorf("verifyCodeChallenge() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIntrospectClientToken(t *testing.T) {
+	p, _, _ := newTestProvider(t)
+	client, _, err := NewServiceClient("Order Service", []string{"bookings:read"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.store.CreateOAuthClient(context.Background(), client); err != nil {
+		t.Fatal(err)
+	}
+	token, err := p.clientCredentials(client, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	response, err := p.introspect(context.Background(), token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !response.Active || response.ClientID != client.ID {
+		t.Errorf("introspect() = %+v, want an active token for %s", response, client.ID)
+	}
+
+	if _, err := p.store.DeleteOAuthClient(context.Background(), client.ID); err != nil {
+		t.Fatal(err)
+	}
+	response, err = p.introspect(context.Background(), token.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Active {
+		t.Error("introspect() reports a deleted client's token as active")
+	}
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPeekBytes bounds how much of a request body is read to find the email address
+const maxPeekBytes = 64 << 10
+
+// Limiter applies rate limit rules to HTTP requests
+type Limiter struct {
+	store   Store
+	rules   []Rule
+	proxies TrustedProxies
+}
+
+// New creates a limiter that keeps its buckets in store. Client IPs are taken from
+// X-Forwarded-For only when the connection comes from one of proxies.
+func New(store Store, rules []Rule, proxies TrustedProxies) *Limiter {
+	return &Limiter{store: store, rules: rules, proxies: proxies}
+}
+
+// Middleware rejects requests that exceed any matching rule with 429 Too Many
+// Requests and a Retry-After header. Every matching rule spends a token, and a
+// request is only let through if all of them had one. If the store fails the
+// request is let through, so that an outage of a shared store does not take logins
+// down with it.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var wait time.Duration
+		limited := false
+		var email string
+		emailRead := false
+
+		for _, rule := range l.rules {
+			if !rule.matches(r) {
+				continue
+			}
+
+			var value string
+			switch rule.KeyBy {
+			case KeyByIP:
+				value = l.proxies.ClientIP(r)
+			case KeyByEmail:
+				if !emailRead {
+					email = requestEmail(r)
+					emailRead = true
+				}
+				value = email
+			}
+			if value == "" {
+				continue
+			}
+
+			// Buckets are per rule so that routes do not share a budget
+			key := rule.String() + "|" + value
+			allowed, retryAfter, err := l.store.Take(key, rule.Limit)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Rate limit store error, allowing request", "error", err)
+				continue
+			}
+			if !allowed {
+				limited = true
+				if retryAfter > wait {
+					wait = retryAfter
+				}
+			}
+		}
+
+		if limited {
+			TooManyRequests(w, wait)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TooManyRequests responds with 429 and a Retry-After header of wait, rounded up to
+// whole seconds
+func TooManyRequests(w http.ResponseWriter, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	response, _ := json.Marshal(map[string]string{"error": "Too many requests; try again later"})
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+	w.Write(response)
+}
+
+// requestEmail returns the lower-cased "email" field of a JSON or form-encoded
+// request body, or "" if there is none. The API handlers decode every body as JSON
+// whatever its Content-Type, so the body is read as JSON first; otherwise a login
+// sent as text/plain, without a Content-Type or mislabelled as a form would skip the
+// email buckets. Only a body that is not JSON is read as a form, and only if it is
+// labelled as one. The body is restored for the next handler.
+func requestEmail(r *http.Request) string {
+	if r.Body == nil {
+		return ""
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, maxPeekBytes))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	var email string
+	var body struct {
+		Email string `json:"email"`
+	}
+	if json.Unmarshal(peeked, &body) == nil {
+		email = body.Email
+	} else if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/x-www-form-urlencoded" {
+		values, err := url.ParseQuery(string(peeked))
+		if err != nil {
+			return ""
+		}
+		email = values.Get("email")
+	}
+
+	return strings.ToLower(strings.TrimSpace(email))
+}
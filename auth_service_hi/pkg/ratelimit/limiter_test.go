@@ -0,0 +1,135 @@
+package ratelimit
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequestEmail(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        string
+	}{
+		{"JSON", "application/json", `{"email":" Jane@Example.com ","password":"x"}`, "jane@example.com"},
+		{"JSON with charset", "application/json; charset=utf-8", `{"email":"jane@example.com"}`, "jane@example.com"},
+		{"JSON as text/plain", "text/plain", `{"email":"jane@example.com"}`, "jane@example.com"},
+		{"JSON without Content-Type", "", `{"email":"jane@example.com"}`, "jane@example.com"},
+		{"JSON labelled as a form", "application/x-www-form-urlencoded", `{"email":"jane@example.com"}`, "jane@example.com"},
+		{"form", "application/x-www-form-urlencoded", "email=Jane%40example.com&password=x", "jane@example.com"},
+		{"form without Content-Type", "", "email=jane%40example.com", ""},
+		{"no email", "application/json", `{"password":"x"}`, ""},
+		{"malformed JSON", "application/json", `{"email":`, ""},
+		{"empty body", "application/json", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/auth/login", strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				r.Header.Set("Content-Type", tt.contentType)
+			}
+
+			if got := requestEmail(r); got != tt.want {
+				t.Errorf("requestEmail() = %q, want %q", got, tt.want)
+			}
+
+			// The next handler must still see the whole body
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body after requestEmail() = %q, want %q", body, tt.body)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rules, err := ParseRules([]string{
+		"POST /api/auth/login ip 3/1m",
+		"POST /api/auth/login email 2/1m",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxies, err := ParseTrustedProxies([]string{"10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := New(NewMemoryStore(), rules, proxies)
+	handler := limiter.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// send makes a login request and returns the response
+	send := func(method, remoteAddr, forwardedFor, contentType, body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/auth/login", strings.NewReader(body))
+		r.RemoteAddr = remoteAddr
+		if forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", forwardedFor)
+		}
+		if contentType != "" {
+			r.Header.Set("Content-Type", contentType)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	t.Run("email buckets ignore the Content-Type", func(t *testing.T) {
+		body := `{"email":"jane@example.com"}`
+		if w := send(http.MethodPost, "192.0.2.1:1234", "", "application/json", body); w.Code != http.StatusOK {
+			t.Fatalf("first request status = %d", w.Code)
+		}
+		if w := send(http.MethodPost, "192.0.2.2:1234", "", "", body); w.Code != http.StatusOK {
+			t.Fatalf("second request status = %d", w.Code)
+		}
+
+		// A third address and a different Content-Type do not get around the limit
+		w := send(http.MethodPost, "192.0.2.3:1234", "", "text/plain", body)
+		if w.Code != http.StatusTooManyRequests {
+			t.Fatalf("third request for the same email status = %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+		if retryAfter := w.Header().Get("Retry-After"); retryAfter != "30" {
+			t.Errorf("Retry-After = %q, want 30", retryAfter)
+		}
+
+		if w := send(http.MethodPost, "192.0.2.4:1234", "", "", `{"email":"other@example.com"}`); w.Code != http.StatusOK {
+			t.Errorf("request for another email status = %d, want %d", w.Code, http.StatusOK)
+		}
+	})
+
+	t.Run("IP buckets", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			if w := send(http.MethodPost, "198.51.100.1:1234", "", "", `{}`); w.Code != http.StatusOK {
+				t.Fatalf("request %d status = %d", i+1, w.Code)
+			}
+		}
+		if w := send(http.MethodPost, "198.51.100.1:1234", "", "", `{}`); w.Code != http.StatusTooManyRequests {
+			t.Errorf("fourth request status = %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+
+		// Behind the trusted proxy every client has its own bucket, and a forged
+		// X-Forwarded-For from an untrusted address is ignored
+		if w := send(http.MethodPost, "10.0.0.1:1234", "198.51.100.2", "", `{}`); w.Code != http.StatusOK {
+			t.Errorf("request through the proxy status = %d, want %d", w.Code, http.StatusOK)
+		}
+		if w := send(http.MethodPost, "198.51.100.1:1234", "198.51.100.3", "", `{}`); w.Code != http.StatusTooManyRequests {
+			t.Errorf("request with a forged X-Forwarded-For status = %d, want %d", w.Code, http.StatusTooManyRequests)
+		}
+	})
+
+	t.Run("other methods are not limited", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			if w := send(http.MethodOptions, "198.51.100.1:1234", "", "", ""); w.Code != http.StatusOK {
+				t.Fatalf("OPTIONS request status = %d, want %d", w.Code, http.StatusOK)
+			}
+		}
+	})
+}
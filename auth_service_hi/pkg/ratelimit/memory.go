@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryEntry is a bucket together with the limit it was last used with, which
+// decides when it can be dropped
+type memoryEntry struct {
+	bucket Bucket
+	limit  Limit
+}
+
+// MemoryStore keeps token buckets in process. Limits are per instance, so behind a
+// load balancer each instance allows the full limit; use a shared Store there.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryEntry
+}
+
+// NewMemoryStore creates an empty in-process bucket store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryEntry)}
+}
+
+// Take removes a token from the bucket at key
+func (m *MemoryStore) Take(key string, limit Limit) (bool, time.Duration, error) {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.buckets[key]
+	if !ok {
+		entry = &memoryEntry{bucket: *NewBucket(limit, now)}
+		m.buckets[key] = entry
+	}
+	entry.limit = limit
+
+	allowed, wait := entry.bucket.Take(limit, now)
+	return allowed, wait, nil
+}
+
+// Cleanup drops buckets that have refilled completely and returns how many were dropped
+func (m *MemoryStore) Cleanup() int {
+	now := time.Now()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dropped := 0
+	for key, entry := range m.buckets {
+		if entry.bucket.Full(entry.limit, now) {
+			delete(m.buckets, key)
+			dropped++
+		}
+	}
+	return dropped
+}
+
+// Run drops refilled buckets every interval until ctx is cancelled, so that memory
+// does not grow with every client and email address ever seen
+func (m *MemoryStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Cleanup()
+		}
+	}
+}
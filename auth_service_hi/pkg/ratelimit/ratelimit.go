@@ -0,0 +1,95 @@
+// Package ratelimit throttles HTTP requests with token buckets. Each Rule matches a
+// route and keys its buckets by the client IP or by the email address in the request
+// body, so both a single client spraying many accounts and many clients guessing one
+// account's password are slowed down. Buckets live in a Store: MemoryStore keeps
+// them in process, and a shared implementation lets several instances enforce the
+// same limits.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Burst requests at once, refilled evenly at Burst per Period
+type Limit struct {
+	Burst  int
+	Period time.Duration
+}
+
+// ParseLimit parses a limit written as "<burst>/<period>", e.g. "5/1m"
+func ParseLimit(s string) (Limit, error) {
+	burst, period, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q: expected <requests>/<period>", s)
+	}
+
+	n, err := strconv.Atoi(burst)
+	if err != nil || n < 1 {
+		return Limit{}, fmt.Errorf("invalid limit %q: requests must be a positive integer", s)
+	}
+	d, err := time.ParseDuration(period)
+	if err != nil || d <= 0 {
+		return Limit{}, fmt.Errorf("invalid limit %q: period must be a positive duration", s)
+	}
+
+	return Limit{Burst: n, Period: d}, nil
+}
+
+// String renders the limit in the form accepted by ParseLimit
+func (l Limit) String() string {
+	return strconv.Itoa(l.Burst) + "/" + l.Period.String()
+}
+
+// interval returns the time it takes to refill one token
+func (l Limit) interval() time.Duration {
+	return l.Period / time.Duration(l.Burst)
+}
+
+// Bucket is the state of one token bucket. Stores persist it per key; a shared
+// store must load, Take and save it atomically.
+type Bucket struct {
+	Tokens  float64
+	Updated time.Time
+}
+
+// NewBucket returns a full bucket for limit
+func NewBucket(limit Limit, now time.Time) *Bucket {
+	return &Bucket{Tokens: float64(limit.Burst), Updated: now}
+}
+
+// Take refills the bucket for the time elapsed since it was last updated and removes
+// one token. If the bucket is empty it reports false and how long until a token
+// becomes available.
+func (b *Bucket) Take(limit Limit, now time.Time) (bool, time.Duration) {
+	if elapsed := now.Sub(b.Updated); elapsed > 0 {
+		b.Tokens = math.Min(float64(limit.Burst), b.Tokens+float64(elapsed)/float64(limit.interval()))
+		b.Updated = now
+	}
+
+	if b.Tokens >= 1 {
+		b.Tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.Tokens) * float64(limit.interval()))
+	return false, wait
+}
+
+// Full reports whether the bucket would be full at now, in which case forgetting it
+// is the same as keeping it
+func (b *Bucket) Full(limit Limit, now time.Time) bool {
+	return now.Sub(b.Updated) >= limit.Period
+}
+
+// Store holds the token buckets. Take must be atomic per key so that concurrent
+// requests cannot spend the same token.
+type Store interface {
+	// Take removes a token from the bucket at key, starting with a full bucket if
+	// there is none. If the bucket is empty it returns false and how long until a
+	// token becomes available.
+	Take(key string, limit Limit) (bool, time.Duration, error)
+}
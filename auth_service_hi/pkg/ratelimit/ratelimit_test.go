@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBucket(t *testing.T) {
+	limit := Limit{Burst: 2, Period: time.Minute}
+	now := time.Now()
+	bucket := NewBucket(limit, now)
+
+	for i := 0; i < 2; i++ {
+		if allowed, _ := bucket.Take(limit, now); !allowed {
+			t.Fatalf("Take() %d refused within the burst", i+1)
+		}
+	}
+	allowed, wait := bucket.Take(limit, now)
+	if allowed || wait != 30*time.Second {
+		t.Errorf("Take() on an empty bucket = %v, %s; want false, 30s", allowed, wait)
+	}
+
+	// One token refills every 30 seconds
+	if allowed, _ := bucket.Take(limit, now.Add(30*time.Second)); !allowed {
+		t.Error("Take() after a refill interval refused")
+	}
+	if bucket.Full(limit, now.Add(30*time.Second)) {
+		t.Error("Full() before a whole period has passed")
+	}
+	if !bucket.Full(limit, now.Add(90*time.Second)) {
+		t.Error("Full() false after a whole period")
+	}
+}
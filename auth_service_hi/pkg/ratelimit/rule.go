@@ -0,0 +1,90 @@
+package ratelimit
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// KeyBy selects what a rule's buckets are keyed by
+type KeyBy string
+
+const (
+	// KeyByIP gives every client IP its own bucket
+	KeyByIP KeyBy = "ip"
+	// KeyByEmail gives every email address in request bodies its own bucket.
+	// Requests without an email address are not limited by the rule.
+	KeyByEmail KeyBy = "email"
+)
+
+// Rule limits requests to one route
+type Rule struct {
+	Method string // Empty matches every method except OPTIONS
+	Path   string // Matched exactly
+	KeyBy  KeyBy
+	Limit  Limit
+}
+
+// ParseRule parses a rule written as "[METHOD] <path> <ip|email> <requests>/<period>",
+// e.g. "POST /api/auth/login email 5/1m"
+func ParseRule(s string) (Rule, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 4 {
+		if fields[0] != strings.ToUpper(fields[0]) || strings.HasPrefix(fields[0], "/") {
+			return Rule{}, fmt.Errorf("invalid rate limit rule %q: invalid method %q", s, fields[0])
+		}
+	} else if len(fields) == 3 {
+		fields = append([]string{""}, fields...)
+	} else {
+		return Rule{}, fmt.Errorf("invalid rate limit rule %q: expected [METHOD] <path> <ip|email> <requests>/<period>", s)
+	}
+
+	rule := Rule{Method: fields[0], Path: fields[1], KeyBy: KeyBy(fields[2])}
+	if !strings.HasPrefix(rule.Path, "/") {
+		return Rule{}, fmt.Errorf("invalid rate limit rule %q: path must start with /", s)
+	}
+	if rule.KeyBy != KeyByIP && rule.KeyBy != KeyByEmail {
+		return Rule{}, fmt.Errorf("invalid rate limit rule %q: key must be %q or %q", s, KeyByIP, KeyByEmail)
+	}
+
+	limit, err := ParseLimit(fields[3])
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rate limit rule %q: %w", s, err)
+	}
+	rule.Limit = limit
+
+	return rule, nil
+}
+
+// ParseRules parses each rule with ParseRule
+func ParseRules(rules []string) ([]Rule, error) {
+	parsed := make([]Rule, 0, len(rules))
+	for _, s := range rules {
+		rule, err := ParseRule(s)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+// String renders the rule in the form accepted by ParseRule
+func (r Rule) String() string {
+	s := r.Path + " " + string(r.KeyBy) + " " + r.Limit.String()
+	if r.Method != "" {
+		s = r.Method + " " + s
+	}
+	return s
+}
+
+// matches reports whether the rule applies to the request
+func (r Rule) matches(req *http.Request) bool {
+	if req.URL.Path != r.Path {
+		return false
+	}
+	if r.Method == "" {
+		return req.Method != http.MethodOptions
+	}
+	return req.Method == r.Method
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule    string
+		want    Rule
+		wantErr bool
+	}{
+		{rule: "POST /api/auth/login email 5/1m", want: Rule{Method: "POST", Path: "/api/auth/login", KeyBy: KeyByEmail, Limit: Limit{Burst: 5, Period: time.Minute}}},
+		{rule: "/oauth/token ip 20/10s", want: Rule{Path: "/oauth/token", KeyBy: KeyByIP, Limit: Limit{Burst: 20, Period: 10 * time.Second}}},
+		{rule: "post /api/auth/login ip 5/1m", wantErr: true},
+		{rule: "POST api/auth/login ip 5/1m", wantErr: true},
+		{rule: "POST /api/auth/login user 5/1m", wantErr: true},
+		{rule: "POST /api/auth/login ip 0/1m", wantErr: true},
+		{rule: "POST /api/auth/login ip 5/0s", wantErr: true},
+		{rule: "POST /api/auth/login ip 5", wantErr: true},
+		{rule: "/api/auth/login 5/1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			got, err := ParseRule(tt.rule)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("ParseRule() = %+v, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseRule() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseRule() = %+v, want %+v", got, tt.want)
+			}
+			if reparsed, err := ParseRule(got.String()); err != nil || reparsed != got {
+				t.Errorf("ParseRule(%q) = %+v, %v; want the same rule", got.String(), reparsed, err)
+			}
+		})
+	}
+}
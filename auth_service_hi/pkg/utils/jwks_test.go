@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"math/big"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// decodeB64 decodes unpadded base64url or fails the test
+func decodeB64(t *testing.T, s string) []byte {
+	t.Helper()
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestThumbprint(t *testing.T) {
+	tests := []struct {
+		name string
+		key  func(t *testing.T) *SigningKey
+		want string
+	}{
+		{
+			// RFC 7638 section 3.1
+			name: "RSA",
+			key: func(t *testing.T) *SigningKey {
+				n := decodeB64(t, "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+				pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+				return &SigningKey{Method: jwt.SigningMethodRS256, verifyKey: pub}
+			},
+			want: "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs",
+		},
+		{
+			// RFC 8037 appendix A.3
+			name: "Ed25519",
+			key: func(t *testing.T) *SigningKey {
+				pub := ed25519.PublicKey(decodeB64(t, "11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo"))
+				return &SigningKey{Method: jwt.SigningMethodEdDSA, verifyKey: pub}
+			},
+			want: "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.key(t).Thumbprint()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Thumbprint() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestThumbprintHMAC(t *testing.T) {
+	if _, err := NewHMACSigningKey("hs", []byte("secret")).Thumbprint(); err == nil {
+		t.Error("Thumbprint() of an HMAC key succeeded, want an error")
+	}
+}
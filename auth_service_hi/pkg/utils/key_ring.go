@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// KeyRing holds the keys a TokenManager works with: one active key that signs new
+// tokens, plus retired keys that only verify tokens issued before a rotation. It is
+// safe for concurrent use and can be swapped out at runtime with Replace.
+type KeyRing struct {
+	mu     sync.RWMutex
+	active *SigningKey
+	keys   map[string]*SigningKey
+
+	// Reloading on a miss; see SetReloader
+	reloadMu       sync.Mutex
+	reload         func() error
+	reloadInterval time.Duration
+	lastReload     time.Time
+}
+
+// NewKeyRing creates a key ring with an active key and optional verification-only keys
+func NewKeyRing(active *SigningKey, verifyOnly ...*SigningKey) *KeyRing {
+	ring := &KeyRing{}
+	ring.Replace(active, verifyOnly)
+	return ring
+}
+
+// Replace atomically swaps the contents of the ring
+func (r *KeyRing) Replace(active *SigningKey, verifyOnly []*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verifyOnly)+1)
+	for _, key := range verifyOnly {
+		keys[key.ID] = key
+	}
+	keys[active.ID] = active
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = active
+	r.keys = keys
+}
+
+// SetReloader makes Reload call reload, which should refresh the ring from wherever
+// its keys are stored, at most once per interval. The limit stops tokens with made-up
+// key IDs from triggering a reload each.
+func (r *KeyRing) SetReloader(reload func() error, interval time.Duration) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+	r.reload = reload
+	r.reloadInterval = interval
+}
+
+// Reload refreshes the ring with the function set by SetReloader, unless it has no
+// reloader or was reloaded less than the interval ago. Concurrent calls wait for a
+// single reload.
+func (r *KeyRing) Reload() error {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	if r.reload == nil || time.Since(r.lastReload) < r.reloadInterval {
+		return nil
+	}
+	r.lastReload = time.Now()
+	return r.reload()
+}
+
+// Active returns the key used to sign new tokens
+func (r *KeyRing) Active() *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.active
+}
+
+// Key returns the key with the given kid, or nil if the ring does not hold it
+func (r *KeyRing) Key(id string) *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.keys[id]
+}
+
+// Keys returns every key in the ring, active key first
+func (r *KeyRing) Keys() []*SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		if key != r.active {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+
+	return append([]*SigningKey{r.active}, keys...)
+}
@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// SigningKey is a key used to sign and verify JWTs, identified by its key ID (kid)
+type SigningKey struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{} // []byte for HMAC, otherwise a crypto.Signer
+	verifyKey interface{} // []byte for HMAC, otherwise the public key
+}
+
+// NewHMACSigningKey creates an HS256 key from a shared secret. Anyone holding the
+// secret can both verify and mint tokens, so HMAC keys are never published in JWKS.
+func NewHMACSigningKey(id string, secret []byte) *SigningKey {
+	return &SigningKey{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// NewSigningKey wraps an RSA, ECDSA or Ed25519 private key. The JWS algorithm is
+// chosen from the key type: RS256 for RSA, ES256/ES384/ES512 for P-256/P-384/P-521,
+// and EdDSA for Ed25519. If id is empty the RFC 7638 thumbprint is used.
+func NewSigningKey(id string, privateKey crypto.PrivateKey) (*SigningKey, error) {
+	key := &SigningKey{ID: id, signKey: privateKey}
+
+	switch k := privateKey.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA signing keys must be at least 2048 bits, got %d", k.N.BitLen())
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.verifyKey = &k.PublicKey
+	case *ecdsa.PrivateKey:
+		switch k.Curve {
+		case elliptic.P256():
+			key.Method = jwt.SigningMethodES256
+		case elliptic.P384():
+			key.Method = jwt.SigningMethodES384
+		case elliptic.P521():
+			key.Method = jwt.SigningMethodES512
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+		}
+		key.verifyKey = &k.PublicKey
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.verifyKey = k.Public()
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+	}
+
+	if key.ID == "" {
+		thumbprint, err := key.Thumbprint()
+		if err != nil {
+			return nil, err
+		}
+		key.ID = thumbprint
+	}
+
+	return key, nil
+}
+
+// GenerateSigningKey creates a new random key for the given JWS algorithm: "EdDSA",
+// "ES256", "ES384", "ES512" or "RS256". The key ID is its thumbprint.
+func GenerateSigningKey(algorithm string) (*SigningKey, error) {
+	var privateKey crypto.PrivateKey
+	var err error
+
+	switch algorithm {
+	case "EdDSA":
+		_, privateKey, err = ed25519.GenerateKey(rand.Reader)
+	case "ES256":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "ES384":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	case "ES512":
+		privateKey, err = ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	case "RS256":
+		privateKey, err = rsa.GenerateKey(rand.Reader, 3072)
+	default:
+		return nil, fmt.Errorf("unsupported signing algorithm %q", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate %s key: %w", algorithm, err)
+	}
+
+	return NewSigningKey("", privateKey)
+}
+
+// ParseSigningKeyPEM parses a PEM-encoded PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) private key
+func ParseSigningKeyPEM(id string, pemData []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(pemData)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var privateKey crypto.PrivateKey
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		privateKey, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		privateKey, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	return NewSigningKey(id, privateKey)
+}
+
+// LoadSigningKeyFile reads a PEM-encoded private key from a file
+func LoadSigningKeyFile(id, path string) (*SigningKey, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	key, err := ParseSigningKeyPEM(id, pemData)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// MarshalPEM encodes the private key of an asymmetric key as PKCS#8 PEM
+func (k *SigningKey) MarshalPEM() ([]byte, error) {
+	if k.IsSymmetric() {
+		return nil, errors.New("HMAC keys cannot be encoded as PEM")
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(k.signKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// IsSymmetric reports whether the key is a shared HMAC secret
+func (k *SigningKey) IsSymmetric() bool {
+	_, ok := k.signKey.([]byte)
+	return ok
+}
+
+// PublicKey returns the public half of an asymmetric key, or nil for HMAC keys
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	if k.IsSymmetric() {
+		return nil
+	}
+	return k.verifyKey
+}
@@ -1,44 +1,139 @@
 package utils
 
 import (
+	"crypto/rand"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v4"
 	"github.com/herb-immortal/auth_service_hi/pkg/models"
 )
 
-// JWTClaims represents the claims in a JWT token
+// JWTClaims represents the claims in a JWT token. User tokens carry a role and a
+// session, and the scopes granted to the OpenID Connect client they were issued
+// to, if any; client tokens, issued to machine clients with the client credentials
+// grant, carry the client_id as their subject and the granted scopes instead.
 type JWTClaims struct {
-	UserID string         `json:"sub"`
-	Role   models.UserRole `json:"role"`
+	UserID      string          `json:"sub"`
+	Role        models.UserRole `json:"role,omitempty"`
+	SessionID   string          `json:"sid,omitempty"`
+	ClientID    string          `json:"client_id,omitempty"`
+	Scope       string          `json:"scope,omitempty"` // Space-separated granted scopes
+	Permissions []string        `json:"perms,omitempty"` // Effective permissions of the user's role, if embedded
 	jwt.RegisteredClaims
 }
 
+// IsClientToken reports whether the token was issued to a machine client rather
+// than to a user
+func (c *JWTClaims) IsClientToken() bool {
+	return c.ClientID != ""
+}
+
+// HasPermission reports whether the permission is embedded in the token. Tokens only
+// carry permissions when the issuer is configured to embed them.
+func (c *JWTClaims) HasPermission(permission string) bool {
+	for _, p := range c.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the token was granted the given scope
+func (c *JWTClaims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 // TokenManager handles JWT token generation and validation
 type TokenManager struct {
-	secretKey []byte
-	issuer    string
-	tokenTTL  time.Duration
+	keys     *KeyRing
+	issuer   string
+	tokenTTL time.Duration
 }
 
-// NewTokenManager creates a new token manager
+// defaultHMACKeyID is the kid of the key created from a shared secret
+const defaultHMACKeyID = "hs256"
+
+// NewTokenManager creates a token manager that signs with a shared HS256 secret
 func NewTokenManager(secretKey string, issuer string, tokenTTL time.Duration) *TokenManager {
+	return NewTokenManagerWithKey(NewHMACSigningKey(defaultHMACKeyID, []byte(secretKey)), issuer, tokenTTL)
+}
+
+// NewTokenManagerWithKey creates a token manager that signs with the given key. Use an
+// asymmetric key so that other services can verify tokens from the public JWKS
+// without being able to mint them.
+func NewTokenManagerWithKey(signingKey *SigningKey, issuer string, tokenTTL time.Duration) *TokenManager {
+	return NewTokenManagerWithKeyRing(NewKeyRing(signingKey), issuer, tokenTTL)
+}
+
+// NewTokenManagerWithKeyRing creates a token manager that signs with the ring's active
+// key and verifies with any key in the ring, so keys can be rotated without
+// invalidating tokens that are already in circulation
+func NewTokenManagerWithKeyRing(keys *KeyRing, issuer string, tokenTTL time.Duration) *TokenManager {
 	return &TokenManager{
-		secretKey: []byte(secretKey),
-		issuer:    issuer,
-		tokenTTL:  tokenTTL,
+		keys:     keys,
+		issuer:   issuer,
+		tokenTTL: tokenTTL,
 	}
 }
 
-// GenerateToken creates a new JWT token for a user
-func (tm *TokenManager) GenerateToken(userID string, role models.UserRole) (string, time.Time, error) {
+// Issuer returns the iss claim placed in tokens
+func (tm *TokenManager) Issuer() string {
+	return tm.issuer
+}
+
+// TokenTTL returns the lifetime of issued tokens
+func (tm *TokenManager) TokenTTL() time.Duration {
+	return tm.tokenTTL
+}
+
+// KeyRing returns the keys used to sign and verify tokens
+func (tm *TokenManager) KeyRing() *KeyRing {
+	return tm.keys
+}
+
+// JWKS returns the public keys that verify tokens issued by this manager, including
+// retired keys that still verify. HMAC secrets are never included.
+func (tm *TokenManager) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, key := range tm.keys.Keys() {
+		if key.IsSymmetric() {
+			continue
+		}
+		if jwk, err := key.JWK(); err == nil {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+	return set
+}
+
+// GenerateToken creates a new JWT token for a user, bound to the given session. The
+// scope is what the user granted to an OpenID Connect client and is empty for
+// first-party logins. The permissions, if any, are embedded so that other services
+// can authorize requests without calling back to this service.
+func (tm *TokenManager) GenerateToken(userID string, role models.UserRole, sessionID, scope string, permissions ...string) (string, time.Time, error) {
 	expirationTime := time.Now().Add(tm.tokenTTL)
-	
+
+	tokenID, err := GenerateSecureToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
 	claims := &JWTClaims{
-		UserID: userID,
-		Role:   role,
+		UserID:      userID,
+		Role:        role,
+		SessionID:   sessionID,
+		Scope:       scope,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
@@ -46,41 +141,110 @@ func (tm *TokenManager) GenerateToken(userID string, role models.UserRole) (stri
 			Subject:   userID,
 		},
 	}
-	
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(tm.secretKey)
-	
+
+	tokenString, err := tm.SignClaims(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expirationTime, nil
+}
+
+// GenerateClientToken creates a JWT for a machine client with the given
+// space-separated scopes. It has no session, so it can only be revoked by deleting
+// the client; keep the token TTL short.
+func (tm *TokenManager) GenerateClientToken(clientID, scope string) (string, time.Time, error) {
+	expirationTime := time.Now().Add(tm.tokenTTL)
+
+	tokenID, err := GenerateSecureToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := &JWTClaims{
+		UserID:   clientID,
+		ClientID: clientID,
+		Scope:    scope,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenID,
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+			Issuer:    tm.issuer,
+			Subject:   clientID,
+		},
+	}
+
+	tokenString, err := tm.SignClaims(claims)
 	if err != nil {
 		return "", time.Time{}, err
 	}
-	
+
 	return tokenString, expirationTime, nil
 }
 
+// SignClaims signs arbitrary claims, such as an OpenID Connect ID token, with the
+// active key and sets the kid header
+func (tm *TokenManager) SignClaims(claims jwt.Claims) (string, error) {
+	signingKey := tm.keys.Active()
+	token := jwt.NewWithClaims(signingKey.Method, claims)
+	token.Header["kid"] = signingKey.ID
+	return token.SignedString(signingKey.signKey)
+}
+
 // ValidateToken checks if a token is valid and returns its claims
 func (tm *TokenManager) ValidateToken(tokenString string) (*JWTClaims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return tm.secretKey, nil
-	})
-	
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, tm.keyFunc)
+
 	if err != nil {
 		return nil, err
 	}
-	
+
 	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
 		return claims, nil
 	}
-	
+
 	return nil, fmt.Errorf("invalid token")
 }
 
-// GenerateUUID creates a new UUID with role prefix
+// keyFunc selects the verification key for a token. The alg header must match the
+// key's algorithm exactly, which rules out algorithm confusion attacks such as
+// presenting an HS256 token signed with a published RSA public key. An unknown kid
+// reloads the ring once, in case another instance has just rotated keys.
+func (tm *TokenManager) keyFunc(token *jwt.Token) (interface{}, error) {
+	var key *SigningKey
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = tm.keys.Key(kid)
+		if key == nil {
+			if err := tm.keys.Reload(); err != nil {
+				return nil, fmt.Errorf("unknown signing key %q: failed to reload keys: %w", kid, err)
+			}
+			key = tm.keys.Key(kid)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+	} else {
+		// Tokens issued before kid headers were introduced have none
+		key = tm.keys.Active()
+	}
+
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.verifyKey, nil
+}
+
+// GenerateUUID creates a random (version 4) UUID with a role prefix, such as
+// "session_3f6c1b9e-...". IDs are primary keys, so they must not collide even when
+// two are generated in the same clock tick.
 func GenerateUUID(role models.UserRole) string {
-	// In a production environment, use a proper UUID library like google/uuid
-	// This is a simplified example
-	timestamp := time.Now().UnixNano()
-	return fmt.Sprintf("%s_%d", role, timestamp)
-}
\ No newline at end of file
+	var b [16]byte
+	// crypto/rand.Read never fails; it crashes the program if the system's
+	// randomness source is unavailable
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+	return fmt.Sprintf("%s_%x-%x-%x-%x-%x", role, b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
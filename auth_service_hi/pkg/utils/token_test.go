@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/herb-immortal/auth_service_hi/pkg/models"
+)
+
+var uuidPattern = regexp.MustCompile(`^session_[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestGenerateUUID(t *testing.T) {
+	const workers, perWorker = 8, 500
+
+	// Concurrent logins generate session IDs within the same clock tick
+	var mu sync.Mutex
+	seen := make(map[string]bool, workers*perWorker)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perWorker; j++ {
+				id := GenerateUUID(models.UserRole("session"))
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("duplicate ID %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for id := range seen {
+		if !uuidPattern.MatchString(id) {
+			t.Fatalf("GenerateUUID() = %s, want a prefixed version 4 UUID", id)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// rfc6238Key is the SHA-1 seed used by the RFC 6238 Appendix B test vectors
+const rfc6238Key = "12345678901234567890"
+
+// rfc6238Vectors are the SHA-1 rows of RFC 6238 Appendix B, which use 8 digits
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "94287082"},
+	{1111111109, "07081804"},
+	{1111111111, "14050471"},
+	{1234567890, "89005924"},
+	{2000000000, "69279037"},
+	{20000000000, "65353130"},
+}
+
+func TestHOTPRFC6238Vectors(t *testing.T) {
+	for _, v := range rfc6238Vectors {
+		if got := hotp([]byte(rfc6238Key), uint64(v.unix/totpPeriod), 8); got != v.code {
+			t.Errorf("hotp at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestGenerateTOTPCodeRFC6238Vectors(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte(rfc6238Key))
+
+	for _, v := range rfc6238Vectors {
+		// Six-digit codes are the low digits of the eight-digit ones
+		want := v.code[len(v.code)-totpDigits:]
+		got, err := GenerateTOTPCode(secret, time.Unix(v.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("GenerateTOTPCode at %d = %s, want %s", v.unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte(rfc6238Key))
+	now := time.Unix(1111111111, 0)
+	step := now.Unix() / totpPeriod
+
+	code := func(t *testing.T, at time.Time) string {
+		t.Helper()
+		c, err := GenerateTOTPCode(secret, at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", code(t, now), 0, step, true},
+		{"previous step within skew", code(t, now.Add(-totpPeriod*time.Second)), 0, step - 1, true},
+		{"next step within skew", code(t, now.Add(totpPeriod*time.Second)), 0, step + 1, true},
+		{"outside skew", code(t, now.Add(-2*totpPeriod*time.Second)), 0, 0, false},
+		{"wrong code", "000000", 0, 0, false},
+		{"wrong length", "1234567", 0, 0, false},
+		{"replayed step", code(t, now), step, 0, false},
+		{"step before the last accepted", code(t, now.Add(-totpPeriod*time.Second)), step, 0, false},
+		{"step after the last accepted", code(t, now.Add(totpPeriod*time.Second)), step, step + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(secret, tt.code, now, tt.lastStep)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP() = (%d, %v), want (%d, %v)", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}